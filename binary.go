@@ -0,0 +1,127 @@
+package redirects
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// binaryMagic prefixes rules encoded by EncodeBinary. The last byte is the
+// format version.
+var binaryMagic = []byte{'R', 'D', 'R', 2}
+
+// binaryVersionNoFlags is the format version whose rules have no flags, it's
+// still decoded.
+const binaryVersionNoFlags = 1
+
+// Flags of a binary encoded rule.
+const binaryFlagForce = 1 << iota
+
+// EncodeBinary returns a compact binary encoding of the rules, suitable for
+// caching parsed rules and loading them again with DecodeBinary without
+// parsing the _redirects file.
+func (r Rules) EncodeBinary() []byte {
+	size := len(binaryMagic) + binary.MaxVarintLen64
+	for _, rule := range r {
+		size += len(rule.From) + len(rule.To) + 5*binary.MaxVarintLen64
+	}
+
+	b := make([]byte, 0, size)
+	b = append(b, binaryMagic...)
+	b = binary.AppendUvarint(b, uint64(len(r)))
+	for _, rule := range r {
+		b = appendString(b, rule.From)
+		b = appendString(b, rule.To)
+		b = binary.AppendUvarint(b, uint64(rule.Status))
+		var flags uint64
+		if rule.Force {
+			flags |= binaryFlagForce
+		}
+		b = binary.AppendUvarint(b, flags)
+		b = binary.AppendUvarint(b, uint64(rule.Line))
+	}
+	return b
+}
+
+// DecodeBinary replaces r with the rules encoded in data by EncodeBinary.
+func (r *Rules) DecodeBinary(data []byte) error {
+	if len(data) < len(binaryMagic) || string(data[:len(binaryMagic)-1]) != string(binaryMagic[:len(binaryMagic)-1]) {
+		return errors.New("not binary encoded rules")
+	}
+	v := data[len(binaryMagic)-1]
+	if v != binaryMagic[len(binaryMagic)-1] && v != binaryVersionNoFlags {
+		return fmt.Errorf("unsupported binary rules version %d", v)
+	}
+	d := decoder{data: data[len(binaryMagic):]}
+
+	n := d.uvarint()
+	// every rule takes at least four bytes, don't trust larger counts
+	if n > uint64(len(d.data))/4 {
+		return errors.New("invalid binary rules: rule count exceeds data")
+	}
+
+	rules := make(Rules, 0, n)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		rule := Rule{
+			From:   d.string(),
+			To:     d.string(),
+			Status: int(d.uvarint()),
+		}
+		if v != binaryVersionNoFlags {
+			flags := d.uvarint()
+			rule.Force = flags&binaryFlagForce != 0
+		}
+		rule.Line = int(d.uvarint())
+		if d.err == nil && !isValidStatusCode(rule.Status) {
+			return fmt.Errorf("invalid binary rules: status code %d is not supported", rule.Status)
+		}
+		rules = append(rules, rule)
+	}
+	if d.err != nil {
+		return fmt.Errorf("invalid binary rules: %w", d.err)
+	}
+	if len(d.data) != 0 {
+		return errors.New("invalid binary rules: trailing data")
+	}
+
+	*r = rules
+	return nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// decoder reads values from data, remembering the first error.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errors.New("malformed varint")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n > uint64(len(d.data)) {
+		d.err = errors.New("string exceeds data")
+		return ""
+	}
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	return s
+}
@@ -0,0 +1,82 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulesBinary(t *testing.T) {
+	rules := Rules(Must(ParseString(`
+	/home              /
+	/posts/:year/:slug /articles/:year/:slug 302
+	/api/*             https://api.example.com/:splat 200
+	/ą                 /ę 404
+	`)))
+
+	t.Run("round trip", func(t *testing.T) {
+		var decoded Rules
+		require.NoError(t, decoded.DecodeBinary(rules.EncodeBinary()))
+		require.Equal(t, rules, decoded)
+	})
+
+	t.Run("forced", func(t *testing.T) {
+		forced := Rules(Must(ParseString("/a /b 200!\n/c /d 302", WithAllowForced())))
+		var decoded Rules
+		require.NoError(t, decoded.DecodeBinary(forced.EncodeBinary()))
+		require.Equal(t, forced, decoded)
+		require.True(t, decoded[0].Force)
+	})
+
+	t.Run("version 1", func(t *testing.T) {
+		data := []byte{'R', 'D', 'R', 1, 1, 2, '/', 'a', 2, '/', 'b', 0xAD, 0x02, 3}
+		var decoded Rules
+		require.NoError(t, decoded.DecodeBinary(data))
+		require.Equal(t, Rules{{From: "/a", To: "/b", Status: 301, Line: 3}}, decoded)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		var decoded Rules
+		require.NoError(t, decoded.DecodeBinary(Rules(nil).EncodeBinary()))
+		require.Empty(t, decoded)
+	})
+
+	t.Run("not encoded rules", func(t *testing.T) {
+		var decoded Rules
+		require.ErrorContains(t, decoded.DecodeBinary([]byte("/home / 301")), "not binary encoded rules")
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		data := rules.EncodeBinary()
+		data[3] = 42
+
+		var decoded Rules
+		require.ErrorContains(t, decoded.DecodeBinary(data), "unsupported binary rules version 42")
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		data := rules.EncodeBinary()
+		for i := len(binaryMagic); i < len(data); i++ {
+			var decoded Rules
+			require.Error(t, decoded.DecodeBinary(data[:i]), "length %d", i)
+		}
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		var decoded Rules
+		require.ErrorContains(t, decoded.DecodeBinary(append(rules.EncodeBinary(), 0)), "trailing data")
+	})
+}
+
+func FuzzDecodeBinary(f *testing.F) {
+	f.Add(Rules(Must(ParseString("/a /b\n/c/* /d/:splat 200"))).EncodeBinary())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var rules Rules
+		if err := rules.DecodeBinary(data); err != nil {
+			return
+		}
+		var again Rules
+		require.NoError(t, again.DecodeBinary(rules.EncodeBinary()))
+		require.Equal(t, rules, again)
+	})
+}
@@ -0,0 +1,67 @@
+package redirects
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// WithCIDValidator makes Parse call validate with the CID of destinations
+// that are ipfs:// URLs or begin with /ipfs/, and reject the rule if it
+// returns an error, catching CIDs broken by copying and pasting. CIDs given
+// by placeholders aren't validated.
+//
+// The package doesn't depend on a CID implementation, with go-cid validate
+// can be:
+//
+//	func(s string) error {
+//		_, err := cid.Decode(s)
+//		return err
+//	}
+func WithCIDValidator(validate func(cid string) error) Option {
+	return func(c *config) {
+		c.validateCID = validate
+	}
+}
+
+// checkCID validates the CID of the destination to with validate.
+func checkCID(to string, validate func(string) error) error {
+	cid, ok := destinationCID(to)
+	if !ok {
+		return nil
+	}
+	if err := validate(cid); err != nil {
+		return fmt.Errorf("invalid CID %q: %w", cid, err)
+	}
+	return nil
+}
+
+// destinationCID returns the CID of the destination to if it's an ipfs://
+// URL, with the scheme in any case, or an /ipfs/ path, and the CID isn't a
+// placeholder.
+func destinationCID(to string) (string, bool) {
+	var rest string
+	switch {
+	case len(to) >= len("ipfs://") && strings.EqualFold(to[:len("ipfs://")], "ipfs://"):
+		rest = to[len("ipfs://"):]
+	case strings.HasPrefix(to, "/ipfs/"):
+		rest = to[len("/ipfs/"):]
+	default:
+		return "", false
+	}
+
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		rest = rest[:i]
+	}
+	if rest == "" || rest[0] == ':' {
+		return "", false
+	}
+	return rest, true
+}
+
+// isIPFSURL reports whether the destination to is an ipfs:// or ipns:// URL,
+// in any case.
+func isIPFSURL(to string) bool {
+	u, err := url.Parse(to)
+	return err == nil && (u.Scheme == "ipfs" || u.Scheme == "ipns")
+}
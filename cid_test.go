@@ -0,0 +1,53 @@
+package redirects
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestinationCID(t *testing.T) {
+	for to, want := range map[string]string{
+		"/ipfs/bafyabc":                    "bafyabc",
+		"/ipfs/bafyabc/a/b?c#d":            "bafyabc",
+		"ipfs://bafyabc/index.html":        "bafyabc",
+		"ipfs://QmAbc":                     "QmAbc",
+		"IPFS://bafyabc":                   "bafyabc",
+		"/ipfs/:cid/a":                     "",
+		"/ipfs/":                           "",
+		"/ipns/example.com":                "",
+		"/a/ipfs/bafyabc":                  "",
+		"https://example.com/ipfs/bafyabc": "",
+	} {
+		cid, ok := destinationCID(to)
+		require.Equal(t, want != "", ok, to)
+		require.Equal(t, want, cid, to)
+	}
+}
+
+func TestParseCIDValidator(t *testing.T) {
+	var validated []string
+	validate := func(cid string) error {
+		validated = append(validated, cid)
+		if !strings.HasPrefix(cid, "bafy") {
+			return errors.New("unknown multibase prefix")
+		}
+		return nil
+	}
+
+	rules, err := ParseString("/a /ipfs/bafyabc/a\n/b ipfs://bafydef\n/c/:cid /ipfs/:cid\n/d /ipfs/bafyabc/a", WithCIDValidator(validate))
+	require.NoError(t, err)
+	require.Len(t, rules, 4)
+	require.Equal(t, []string{"bafyabc", "bafydef"}, validated)
+
+	_, err = ParseString("/a /ipfs/afyabc", WithCIDValidator(validate))
+	require.EqualError(t, err, `line 1: parsing 'to': invalid CID "afyabc": unknown multibase prefix`)
+
+	_, err = ParseString("/a IPFS://afyabc", WithCIDValidator(validate))
+	require.EqualError(t, err, `line 1: parsing 'to': invalid CID "afyabc": unknown multibase prefix`)
+
+	_, err = ParseString("/a /ipfs/afyabc")
+	require.NoError(t, err, "CIDs are only validated with a validator")
+}
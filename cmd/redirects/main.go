@@ -0,0 +1,687 @@
+// Command redirects checks and converts _redirects files with the same parser
+// IPFS gateways use, so site authors can catch problems before publishing.
+//
+// Usage:
+//
+//	redirects validate [-strict] [-spec] [-allow-forced] [-car [-root cid]] [file]
+//	redirects lint [-strict] [-allow-forced] [-format text|github|json] [file]
+//	redirects fmt [-w] [-align] [-allow-forced] [-optimize [-splats]] [file]
+//	redirects test [-allow-forced] [-file file] url...
+//	redirects convert [-from format] [-to format] [file]
+//	redirects serve [-addr address] [-allow-forced] [dir]
+//
+// The file defaults to _redirects in the current directory, "-" reads it from
+// the standard input. With -car, validate reads the _redirects file of the
+// site in a CAR archive, to check it before pinning. Files are parsed like
+// gateways do by default, rejecting forced rules unless -allow-forced is
+// given.
+//
+// fmt rewrites the rules of the file with single spaces between their
+// fields, or aligned columns with -align, keeping its comments and empty
+// lines and the statuses it spells out. With -w, it replaces the file.
+//
+// convert reads and writes rules as text, JSON, in the binary format of the
+// package or as dag-cbor IPLD blocks, which default to text and JSON. It also
+// reads nginx configurations, firebase.json files, the routing rules of S3
+// websites and the aliases of the pages of static site generators as JSON,
+// reads and writes vercel.json files and the rule objects of the Netlify API,
+// and writes _redirects files for Netlify, the normalized rules of
+// netlify-redirect-parser, Caddyfile, nginx and Fastly VCL snippets and
+// CloudFront Functions, reporting what doesn't convert exactly on the
+// standard error.
+//
+// fmt -optimize removes the rules that don't change what any path does, and
+// proposes replacing groups of rules for single paths with a rule with a
+// placeholder, reporting the paths that would change, which -splats
+// applies.
+//
+// serve serves the site in dir, the current directory by default, applying
+// its _redirects file like a gateway does, to preview it before publishing.
+// The file is reloaded when it changes, edits apply within a second.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/gatewayutil"
+	"github.com/ipfs/go-ipfs-redirects-file/redirectsconv"
+	"github.com/ipfs/go-ipfs-redirects-file/redirectsipld"
+)
+
+// defaultFile is the file commands read when not given one.
+const defaultFile = "_redirects"
+
+// A command runs a subcommand with its arguments, returning the exit status.
+type command func(env *env, args []string) int
+
+var commands = map[string]command{
+	"validate": validate,
+	"lint":     lint,
+	"fmt":      format,
+	"test":     test,
+	"convert":  convert,
+	"serve":    serve,
+}
+
+// env holds the standard streams of a run.
+type env struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], &env{os.Stdin, os.Stdout, os.Stderr}))
+}
+
+func run(args []string, e *env) int {
+	if len(args) == 0 {
+		usage(e.stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(e.stderr, "redirects: unknown command %q\n", args[0])
+		usage(e.stderr)
+		return 2
+	}
+	return cmd(e, args[1:])
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "usage: redirects <command> [arguments]\n\ncommands: %v\n", names)
+}
+
+// newFlagSet returns the flag set of the subcommand name.
+func newFlagSet(e *env, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("redirects "+name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	return fs
+}
+
+// fileArg returns the file named by the arguments left after parsing flags.
+func fileArg(fs *flag.FlagSet) (string, error) {
+	switch fs.NArg() {
+	case 0:
+		return defaultFile, nil
+	case 1:
+		return fs.Arg(0), nil
+	}
+	return "", errors.New("too many arguments")
+}
+
+// readFile reads the file at path, or the standard input if path is "-".
+func readFile(e *env, path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(e.stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// parseFile reads and parses the file at path, reporting errors on stderr.
+func parseFile(e *env, path string, opts ...redirects.Option) (redirects.Rules, []byte, bool) {
+	src, err := readFile(e, path)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "redirects: %v\n", err)
+		return nil, nil, false
+	}
+	rules, err := redirects.ParseBytes(src, opts...)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "%s: %v\n", path, err)
+		return nil, nil, false
+	}
+	return rules, src, true
+}
+
+// parseCAR reads and parses the _redirects file of the site root in the CAR
+// archive at path, reporting errors on stderr.
+func parseCAR(e *env, path, root string, opts ...redirects.Option) (redirects.Rules, bool) {
+	var r io.Reader = e.stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "redirects: %v\n", err)
+			return nil, false
+		}
+		defer f.Close()
+		r = f
+	}
+	rules, err := gatewayutil.ParseCAR(r, root, opts...)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "%s: %v\n", path, err)
+		return nil, false
+	}
+	return rules, true
+}
+
+func validate(e *env, args []string) int {
+	fs := newFlagSet(e, "validate")
+	strict := fs.Bool("strict", false, "reject anything outside the documented grammar")
+	spec := fs.Bool("spec", false, "reject extensions to the _redirects specification of IPFS gateways")
+	car := fs.Bool("car", false, "read the _redirects file of the site in the CAR archive file")
+	root := fs.String("root", "", "with -car, the CID of the site, by default the root of the archive")
+	allowForced := fs.Bool("allow-forced", false, "accept forced rules, which gateways reject by default")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	path, err := fileArg(fs)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "redirects validate: %v\n", err)
+		return 2
+	}
+
+	opts := parseOptions(*allowForced)
+	if *strict {
+		opts = append(opts, redirects.WithStrict())
+	}
+	if *spec {
+		opts = append(opts, redirects.WithGatewaySpec())
+	}
+	var rules redirects.Rules
+	if *car {
+		var ok bool
+		if rules, ok = parseCAR(e, path, *root, opts...); !ok {
+			return 1
+		}
+	} else {
+		var ok bool
+		if rules, _, ok = parseFile(e, path, opts...); !ok {
+			return 1
+		}
+	}
+	fmt.Fprintf(e.stdout, "%s: %d rules\n", path, len(rules))
+	return 0
+}
+
+func lint(e *env, args []string) int {
+	fs := newFlagSet(e, "lint")
+	strict := fs.Bool("strict", false, "report problems that likely break a site as errors")
+	output := fs.String("format", "text", "output format: text, github or json")
+	allowForced := fs.Bool("allow-forced", false, "accept forced rules, which gateways reject by default")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	path, err := fileArg(fs)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "redirects lint: %v\n", err)
+		return 2
+	}
+
+	src, err := readFile(e, path)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "redirects: %v\n", err)
+		return 1
+	}
+	opts := append(parseOptions(*allowForced), redirects.WithSource(src))
+	if *strict {
+		opts = append(opts, redirects.WithStrict())
+	}
+
+	var diags []redirects.Diagnostic
+	if rules, err := redirects.ParseBytes(src, opts...); err != nil {
+		diags = []redirects.Diagnostic{redirects.ErrorDiagnostic(err)}
+	} else {
+		diags = redirects.Lint(rules, opts...)
+	}
+
+	switch *output {
+	case "text":
+		for _, d := range diags {
+			fmt.Fprintf(e.stdout, "%s:%s: %s: %s (%s)\n", path, position(d), d.Severity, d.Message, d.Code)
+		}
+	case "github":
+		err = redirects.WriteGitHubAnnotations(e.stdout, path, diags)
+	case "json":
+		if diags == nil {
+			diags = []redirects.Diagnostic{}
+		}
+		err = writeJSON(e.stdout, diags)
+	default:
+		fmt.Fprintf(e.stderr, "redirects lint: unknown format %q\n", *output)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(e.stderr, "redirects: %v\n", err)
+		return 1
+	}
+
+	for _, d := range diags {
+		if d.Severity == redirects.SeverityError {
+			return 1
+		}
+	}
+	return 0
+}
+
+// position returns the line and column of d, like "3:5".
+func position(d redirects.Diagnostic) string {
+	switch {
+	case d.Column > 0:
+		return fmt.Sprintf("%d:%d", d.Line, d.Column)
+	case d.Line > 0:
+		return fmt.Sprint(d.Line)
+	}
+	return fmt.Sprintf("rule %d", d.Rule+1)
+}
+
+func format(e *env, args []string) int {
+	fs := newFlagSet(e, "fmt")
+	write := fs.Bool("w", false, "write the result to the file instead of the standard output")
+	align := fs.Bool("align", false, "align the columns of the rules")
+	optimize := fs.Bool("optimize", false, "remove the rules that don't change what any path does")
+	splats := fs.Bool("splats", false, "with -optimize, replace groups of rules for single paths with a rule with a placeholder")
+	allowForced := fs.Bool("allow-forced", false, "accept forced rules, which gateways reject by default")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	path, err := fileArg(fs)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "redirects fmt: %v\n", err)
+		return 2
+	}
+
+	rules, src, ok := parseFile(e, path, parseOptions(*allowForced)...)
+	if !ok {
+		return 1
+	}
+	if *optimize {
+		rules = optimizeRules(e, path, rules, *splats)
+	}
+	out := formatSource(src, rules, *align)
+	if !*write || path == "-" {
+		if _, err := e.stdout.Write(out); err != nil {
+			fmt.Fprintf(e.stderr, "redirects: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if err := writeFileAtomic(path, out); err != nil {
+		fmt.Fprintf(e.stderr, "redirects: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseOptions returns the options commands parse files with, those of
+// gateways unless allowForced is true.
+func parseOptions(allowForced bool) []redirects.Option {
+	if allowForced {
+		return []redirects.Option{redirects.WithAllowForced()}
+	}
+	return nil
+}
+
+// formatSource returns src, the file rules were parsed from, with each rule
+// written with single spaces between its fields, or columns aligned if align
+// is true. Comments and empty lines are kept, trimmed, and the status only
+// written for rules that had one. The lines of rules missing from rules are
+// left out, and rules at the line of one they replace take its place. The
+// integrity pragma, if src has one, is updated for the new content.
+func formatSource(src []byte, rules redirects.Rules, align bool) []byte {
+	lines := bytes.Split(src, []byte{'\n'})
+	if n := len(lines); n > 0 && len(lines[n-1]) == 0 {
+		lines = lines[:n-1]
+	}
+	byLine := make(map[int]redirects.Rule, len(rules))
+	for _, rule := range rules {
+		byLine[rule.Line] = rule
+	}
+
+	var fromWidth, toWidth int
+	if align {
+		for _, rule := range rules {
+			if n := len(rule.From); n <= maxAlignWidth {
+				fromWidth = max(fromWidth, n)
+			}
+			if n := len(rule.To); n <= maxAlignWidth {
+				toWidth = max(toWidth, n)
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	pragma := false
+	for i, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			if i == 0 && isIntegrityPragma(line) {
+				pragma = true
+				continue
+			}
+			b.Write(line)
+			b.WriteByte('\n')
+			continue
+		}
+		rule, ok := byLine[i+1]
+		if !ok {
+			continue
+		}
+
+		fields := []string{rule.From, rule.To}
+		if len(bytes.Fields(line)) == 3 {
+			status := strconv.Itoa(rule.Status)
+			if rule.Force {
+				status += "!"
+			}
+			fields = append(fields, status)
+		}
+		for j, field := range fields {
+			if j > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(field)
+			if align && j < len(fields)-1 {
+				width := fromWidth
+				if j == 1 {
+					width = toWidth
+				}
+				b.WriteString(strings.Repeat(" ", max(width-len(field), 0)+1))
+			}
+		}
+		b.WriteByte('\n')
+	}
+
+	if pragma {
+		return redirects.AddIntegrity(b.Bytes())
+	}
+	return b.Bytes()
+}
+
+// maxAlignWidth is the widest a column fmt -align aligns gets, like for
+// Rules.Pretty.
+const maxAlignWidth = 40
+
+// isIntegrityPragma reports whether line, trimmed, is an integrity pragma.
+func isIntegrityPragma(line []byte) bool {
+	comment, ok := bytes.CutPrefix(line, []byte{'#'})
+	return ok && bytes.HasPrefix(bytes.TrimSpace(comment), []byte("integrity:"))
+}
+
+// writeFileAtomic replaces the file at path with data, writing it to a
+// temporary file in the same directory first so the file is never left
+// truncated.
+func writeFileAtomic(path string, data []byte) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(fi.Mode().Perm())
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// optimizeRules returns rules optimized, reporting the rules removed and the
+// splat proposals, applied if apply is true, on stderr.
+func optimizeRules(e *env, path string, rules redirects.Rules, apply bool) redirects.Rules {
+	rules, diags := redirects.Optimize(rules)
+	printLossy(e, path, diags)
+
+	proposals := redirects.ProposeSplats(rules)
+	for _, p := range proposals {
+		verb := "could replace"
+		if apply {
+			verb = "replaced"
+		}
+		fmt.Fprintf(e.stderr, "%s:%d: %s %d rules with %s %s %d\n", path, rules[p.First].Line, verb, p.Count, p.Rule.From, p.Rule.To, p.Rule.Status)
+		for _, c := range p.Changes {
+			fmt.Fprintf(e.stderr, "  %s: %s -> %s\n", c.Before.Request.Path, outcome(c.Before), outcome(c.After))
+		}
+	}
+	if apply {
+		rules = redirects.ApplySplats(rules, proposals)
+	}
+	return rules
+}
+
+// outcome describes the result of simulating a request.
+func outcome(r redirects.SimResult) string {
+	if !r.Matched {
+		return "no match"
+	}
+	return fmt.Sprintf("%d %s", r.Status, r.Destination)
+}
+
+func test(e *env, args []string) int {
+	fs := newFlagSet(e, "test")
+	path := fs.String("file", defaultFile, "the _redirects file")
+	allowForced := fs.Bool("allow-forced", false, "accept forced rules, which gateways reject by default")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(e.stderr, "redirects test: no URL given")
+		return 2
+	}
+
+	rules, _, ok := parseFile(e, *path, parseOptions(*allowForced)...)
+	if !ok {
+		return 1
+	}
+	for _, target := range fs.Args() {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "redirects test: %v\n", err)
+			return 2
+		}
+		res := redirects.SimulateRequest(rules, req)
+		fmt.Fprintf(e.stdout, "%s: %s\n", target, describe(res))
+	}
+	return 0
+}
+
+// describe returns a human-readable rendering of res.
+func describe(res redirects.SimResponse) string {
+	var outcome string
+	switch {
+	case res.Rule < 0:
+		return fmt.Sprintf("%d, no rule matches", res.Status)
+	case res.Location != "":
+		outcome = "Location: " + res.Location
+	case res.ProxyURL != "":
+		outcome = "proxy " + res.ProxyURL
+	case res.Path != "":
+		outcome = "serve " + res.Path
+	default:
+		outcome = "unsafe destination"
+	}
+	return fmt.Sprintf("%d %s (rule %d)", res.Status, outcome, res.Rule+1)
+}
+
+func convert(e *env, args []string) int {
+	fs := newFlagSet(e, "convert")
+	from := fs.String("from", "text", "input format: text, json, binary, dag-cbor, nginx, vercel, firebase, s3, aliases or netlify-api")
+	to := fs.String("to", "json", "output format: text, json, binary, dag-cbor, netlify, vercel, netlify-api, netlify-normalized, caddy, nginx, cloudfront or fastly")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	path, err := fileArg(fs)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "redirects convert: %v\n", err)
+		return 2
+	}
+
+	src, err := readFile(e, path)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "redirects: %v\n", err)
+		return 1
+	}
+
+	var rules redirects.Rules
+	var diags []redirects.Diagnostic
+	switch *from {
+	case "text":
+		rules, err = redirects.ParseBytes(src, redirects.WithAllowForced())
+	case "json":
+		err = json.Unmarshal(src, &rules)
+	case "binary":
+		err = rules.DecodeBinary(src)
+	case "dag-cbor":
+		rules, err = redirectsipld.Decode(src, redirects.WithAllowForced())
+	case "nginx":
+		rules, diags, err = redirectsconv.ImportNginx(bytes.NewReader(src))
+	case "vercel":
+		rules, diags, err = redirectsconv.ImportVercel(bytes.NewReader(src))
+	case "firebase":
+		rules, diags, err = redirectsconv.ImportFirebase(bytes.NewReader(src))
+	case "s3":
+		rules, diags, err = redirectsconv.ImportS3(bytes.NewReader(src))
+	case "aliases":
+		rules, diags, err = redirectsconv.ImportAliasesJSON(bytes.NewReader(src))
+	case "netlify-api":
+		rules, diags, err = redirectsconv.ImportNetlifyAPI(bytes.NewReader(src))
+	default:
+		fmt.Fprintf(e.stderr, "redirects convert: unknown format %q\n", *from)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(e.stderr, "%s: %v\n", path, err)
+		return 1
+	}
+	printLossy(e, path, diags)
+
+	switch *to {
+	case "text":
+		_, err = rules.WriteTo(e.stdout)
+	case "json":
+		err = writeJSON(e.stdout, rules)
+	case "binary":
+		_, err = e.stdout.Write(rules.EncodeBinary())
+	case "dag-cbor":
+		_, err = e.stdout.Write(redirectsipld.Encode(rules))
+	case "vercel":
+		config, diags := redirectsconv.ExportVercel(rules)
+		printLossy(e, path, diags)
+		err = writeJSON(e.stdout, config)
+	case "netlify":
+		var diags []redirects.Diagnostic
+		diags, err = redirectsconv.ExportNetlify(e.stdout, rules)
+		printLossy(e, path, diags)
+	case "netlify-api":
+		err = writeJSON(e.stdout, redirectsconv.ExportNetlifyAPI(rules))
+	case "netlify-normalized":
+		err = writeJSON(e.stdout, redirectsconv.NormalizeNetlify(rules))
+	case "caddy":
+		caddyfile, diags := redirectsconv.ExportCaddy(rules)
+		printLossy(e, path, diags)
+		_, err = e.stdout.Write(caddyfile)
+	case "cloudfront":
+		js, diags := redirectsconv.ExportCloudFront(rules)
+		printLossy(e, path, diags)
+		_, err = e.stdout.Write(js)
+	case "fastly":
+		vcl, diags := redirectsconv.ExportFastly(rules)
+		printLossy(e, path, diags)
+		_, err = e.stdout.Write(vcl)
+	case "nginx":
+		http, server, diags := redirectsconv.ExportNginx(rules)
+		printLossy(e, path, diags)
+		_, err = fmt.Fprintf(e.stdout, "# in the http block\n%s\n# in the server block\n%s", http, server)
+	default:
+		fmt.Fprintf(e.stderr, "redirects convert: unknown format %q\n", *to)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(e.stderr, "redirects: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printLossy reports on stderr the lossy conversions of the file at path.
+func printLossy(e *env, path string, diags []redirects.Diagnostic) {
+	for _, d := range diags {
+		if d.Line <= 0 && d.Rule < 0 {
+			// skipped entries of formats without lines
+			fmt.Fprintf(e.stderr, "%s: %s: %s\n", path, d.Severity, d.Message)
+			continue
+		}
+		fmt.Fprintf(e.stderr, "%s:%s: %s: %s\n", path, position(d), d.Severity, d.Message)
+	}
+}
+
+// writeJSON writes v to w as indented JSON.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func serve(e *env, args []string) int {
+	fs := newFlagSet(e, "serve")
+	addr := fs.String("addr", "localhost:8080", "the address to listen on")
+	allowForced := fs.Bool("allow-forced", false, "accept forced rules, which gateways reject by default")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	dir := "."
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		dir = fs.Arg(0)
+	default:
+		fmt.Fprintln(e.stderr, "redirects serve: too many arguments")
+		return 2
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		fmt.Fprintf(e.stderr, "redirects serve: %s isn't a directory\n", dir)
+		return 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := siteHandler(ctx, dir, func(err error) {
+		fmt.Fprintf(e.stderr, "%s: %v\n", filepath.Join(dir, defaultFile), err)
+	}, parseOptions(*allowForced)...)
+
+	fmt.Fprintf(e.stderr, "serving %s on http://%s\n", dir, *addr)
+	if err := http.ListenAndServe(*addr, h); err != nil {
+		fmt.Fprintf(e.stderr, "redirects: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// siteHandler returns the handler serving the site in dir with the rules of
+// its _redirects file parsed with opts, loaded and then reloaded when the
+// file changes until ctx is done. Errors reading or parsing the file are
+// passed to report, the last rules that parsed stay in use.
+func siteHandler(ctx context.Context, dir string, report func(error), opts ...redirects.Option) http.Handler {
+	name := filepath.Join(dir, defaultFile)
+	store := new(redirects.Store)
+	// Watch does the first load too
+	go store.Watch(ctx, name, nil, report, opts...)
+	return store.Handler(os.DirFS(dir))
+}
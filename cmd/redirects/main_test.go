@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/redirectstest"
+	"github.com/stretchr/testify/require"
+)
+
+// runCommand runs the command line args with stdin, returning the exit
+// status, the standard output and the standard error.
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, &env{strings.NewReader(stdin), &stdout, &stderr})
+	return status, stdout.String(), stderr.String()
+}
+
+func TestValidate(t *testing.T) {
+	status, stdout, _ := runCommand("/a /b\n/c /d 302\n", "validate", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "-: 2 rules\n", stdout)
+
+	status, _, stderr := runCommand("/a /b 999\n", "validate", "-")
+	require.Equal(t, 1, status)
+	require.Equal(t, "-: line 1: parsing status \"999\": status code 999 is not supported\n", stderr)
+
+	status, _, stderr = runCommand("/a /b 200!\n", "validate", "-")
+	require.Equal(t, 1, status)
+	require.Equal(t, "-: line 1: parsing status \"200!\": forced redirects (or \"shadowing\") are not allowed\n", stderr)
+
+	status, _, stderr = runCommand("/a /b 200!\n", "validate", "-allow-forced", "-spec", "-")
+	require.Equal(t, 1, status)
+	require.Equal(t, "-: line 1: parsing status \"200!\": forced redirects (or \"shadowing\") are not allowed\n", stderr)
+
+	status, stdout, _ = runCommand("/a /b 200!\n", "validate", "-allow-forced", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "-: 1 rules\n", stdout)
+}
+
+func TestValidateCAR(t *testing.T) {
+	site := redirectstest.NewSite("/a /b\n/c /d 302!\n", nil)
+	path := filepath.Join(t.TempDir(), "site.car")
+	require.NoError(t, os.WriteFile(path, site.CAR, 0o644))
+
+	status, stdout, _ := runCommand("", "validate", "-allow-forced", "-car", "-root", site.Root, path)
+	require.Equal(t, 0, status)
+	require.Equal(t, path+": 2 rules\n", stdout)
+
+	status, _, stderr := runCommand("", "validate", "-car", path)
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "forced redirects")
+
+	status, stdout, _ = runCommand(string(site.CAR), "validate", "-allow-forced", "-car", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "-: 2 rules\n", stdout)
+}
+
+func TestLint(t *testing.T) {
+	status, stdout, _ := runCommand("/a /b\n/a /c\n", "lint", "-")
+	require.Equal(t, 0, status)
+	require.Contains(t, stdout, "-:2:1: warning: ")
+
+	status, stdout, stderr := runCommand("/a /b\n/b /a\n", "lint", "-strict", "-format", "github", "-")
+	require.Equal(t, 1, status)
+	require.Empty(t, stderr)
+	require.Equal(t, "::error file=-,line=1,title=redirect-loop::redirect loop /a -> /b -> /a\n", stdout)
+
+	status, stdout, _ = runCommand("/a /b\n", "lint", "-format", "json", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "[]\n", stdout)
+
+	status, _, _ = runCommand("/a /b 302!\n", "lint", "-")
+	require.Equal(t, 1, status)
+	status, _, _ = runCommand("/a /b 302!\n", "lint", "-allow-forced", "-")
+	require.Equal(t, 0, status)
+}
+
+func TestFmt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "_redirects")
+	src := "# comment\n\n  /a   /b\n/c\t/d 302!  \n/e /f 0301\n"
+	require.NoError(t, os.WriteFile(path, []byte(src), 0o600))
+
+	status, _, stderr := runCommand("", "fmt", path)
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "forced redirects")
+
+	want := "# comment\n\n/a /b\n/c /d 302!\n/e /f 301\n"
+	status, stdout, _ := runCommand("", "fmt", "-allow-forced", path)
+	require.Equal(t, 0, status)
+	require.Equal(t, want, stdout)
+
+	status, _, _ = runCommand("", "fmt", "-allow-forced", "-w", path)
+	require.Equal(t, 0, status)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, want, string(b))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	status, stdout, _ = runCommand("# old paths\n/a /b\n/old/* /new/:splat 302\n", "fmt", "-align", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "# old paths\n/a      /b\n/old/*  /new/:splat  302\n", stdout)
+}
+
+func TestFmtIntegrity(t *testing.T) {
+	src := redirects.AddIntegrity([]byte("/a   /b\n"))
+	status, stdout, _ := runCommand(string(src), "fmt", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, string(redirects.AddIntegrity([]byte("/a /b\n"))), stdout)
+
+	_, err := redirects.ParseString(stdout)
+	require.NoError(t, err)
+}
+
+func TestFmtOptimize(t *testing.T) {
+	src := "# moved\n/a /b\n/a /c\n/posts/1 /p/1 302\n/posts/2 /p/2 302\n"
+	status, stdout, stderr := runCommand(src, "fmt", "-optimize", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "# moved\n/a /b\n/posts/1 /p/1 302\n/posts/2 /p/2 302\n", stdout)
+	require.Equal(t, "-:3: info: removed, the rule on line 2 has the same from\n-:4: could replace 2 rules with /posts/:x /p/:x 302\n  /posts/:x: no match -> 302 /p/:x\n", stderr)
+
+	status, stdout, _ = runCommand(src, "fmt", "-optimize", "-splats", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "# moved\n/a /b\n/posts/:x /p/:x 302\n", stdout)
+}
+
+func TestTest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "_redirects")
+	require.NoError(t, os.WriteFile(path, []byte("/old/* /new/:splat 302\n/* /index.html 200\n"), 0o644))
+
+	status, stdout, _ := runCommand("", "test", "-file", path, "/old/a", "/app")
+	require.Equal(t, 0, status)
+	require.Equal(t, "/old/a: 302 Location: /new/a (rule 1)\n/app: 200 serve /index.html (rule 2)\n", stdout)
+
+	require.NoError(t, os.WriteFile(path, []byte("/app /index.html 200!\n"), 0o644))
+	status, _, _ = runCommand("", "test", "-file", path, "/app")
+	require.Equal(t, 1, status)
+	status, stdout, _ = runCommand("", "test", "-allow-forced", "-file", path, "/app")
+	require.Equal(t, 0, status)
+	require.Equal(t, "/app: 200 serve /index.html (rule 1)\n", stdout)
+}
+
+func TestConvert(t *testing.T) {
+	status, stdout, _ := runCommand("/a /b\n", "convert", "-")
+	require.Equal(t, 0, status)
+	require.JSONEq(t, `[{"From": "/a", "To": "/b", "Status": 301}]`, stdout)
+
+	status, stdout, _ = runCommand(stdout, "convert", "-from", "json", "-to", "binary", "-")
+	require.Equal(t, 0, status)
+
+	status, stdout, _ = runCommand(stdout, "convert", "-from", "binary", "-to", "text", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "/a /b 301\n", stdout)
+}
+
+func TestConvertDagCBOR(t *testing.T) {
+	status, stdout, _ := runCommand("/a /b 302!\n", "convert", "-to", "dag-cbor", "-")
+	require.Equal(t, 0, status)
+
+	status, stdout, _ = runCommand(stdout, "convert", "-from", "dag-cbor", "-to", "text", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "/a /b 302!\n", stdout)
+}
+
+func TestConvertNginx(t *testing.T) {
+	status, stdout, stderr := runCommand("location = /a { return 301 /b; }\nrewrite ^/c$ $uri;\n", "convert", "-from", "nginx", "-to", "text", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "/a /b 301\n", stdout)
+	require.Equal(t, "-:2: warning: skipped rewrite: variable $uri can't be represented\n", stderr)
+}
+
+func TestConvertNetlify(t *testing.T) {
+	status, stdout, stderr := runCommand(`[{"from": "/a", "to": "/b", "status": 301}, {"from": "/c", "to": "ipns://example.com", "status": 302}]`, "convert", "-from", "json", "-to", "netlify", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "/a /b 301\n# dropped, ipns destinations are only supported by IPFS gateways\n# /c ipns://example.com 302\n", stdout)
+	require.Equal(t, "-:rule 2: warning: dropped, ipns destinations are only supported by IPFS gateways\n", stderr)
+}
+
+func TestConvertS3(t *testing.T) {
+	status, stdout, stderr := runCommand(`[{"Condition": {"KeyPrefixEquals": "docs/"}, "Redirect": {"ReplaceKeyPrefixWith": "documents/"}}]`, "convert", "-from", "s3", "-to", "text", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "/docs/* /documents/:splat 301\n", stdout)
+	require.Equal(t, "-:rule 1: warning: RoutingRules[0]: it no longer applies to paths with content\n", stderr)
+}
+
+func TestConvertAliases(t *testing.T) {
+	status, stdout, stderr := runCommand(`{"/new/": ["/old", "https://example.com/"]}`, "convert", "-from", "aliases", "-to", "text", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "/old /new/ 301\n", stdout)
+	require.Equal(t, "-: warning: page \"/new/\": skipped alias \"https://example.com/\": only paths of the site can redirect\n", stderr)
+}
+
+func TestConvertVercel(t *testing.T) {
+	status, stdout, stderr := runCommand("/a /b 302\n/c /d 404\n", "convert", "-to", "vercel", "-")
+	require.Equal(t, 0, status)
+	require.JSONEq(t, `{"redirects": [{"source": "/a", "destination": "/b", "statusCode": 302}]}`, stdout)
+	require.Equal(t, "-:2: warning: skipped, status 404 can't be represented\n", stderr)
+}
+
+func TestConvertNetlifyAPI(t *testing.T) {
+	status, stdout, _ := runCommand(`[{"from": "/a", "to": "/b", "status": 302, "force": true}]`, "convert", "-from", "netlify-api", "-to", "text", "-")
+	require.Equal(t, 0, status)
+	require.Equal(t, "/a /b 302!\n", stdout)
+}
+
+func TestUnknownCommand(t *testing.T) {
+	status, _, stderr := runCommand("", "frobnicate")
+	require.Equal(t, 2, status)
+	require.Contains(t, stderr, `unknown command "frobnicate"`)
+}
+
+func TestServe(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "_redirects"), []byte("/old /new 301\n/* /404.html 404\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "404.html"), []byte("not found"), 0o644))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var errs []error
+	var mu sync.Mutex
+	h := siteHandler(ctx, dir, func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+
+	require.Eventually(t, func() bool {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/old", nil))
+		return rec.Code == 301 && rec.Header().Get("Location") == "/new"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/missing", nil))
+	require.Equal(t, 404, rec.Code)
+	require.Equal(t, "not found", rec.Body.String())
+
+	// edits apply without restarting, forced rules are rejected like on
+	// gateways and the last rules that parsed stay in use
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "_redirects"), []byte("/old /newer 302 \n"), 0o644))
+	require.Eventually(t, func() bool {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/old", nil))
+		return rec.Code == 302 && rec.Header().Get("Location") == "/newer"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "_redirects"), []byte("/old /forced 302!\n"), 0o644))
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.ErrorContains(t, errs[0], "forced redirects")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/old", nil))
+	require.Equal(t, "/newer", rec.Header().Get("Location"))
+
+	// errors in the file served from the start are reported once
+	broken := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(broken, "_redirects"), []byte("/old\n"), 0o644))
+	reported := make(chan error, 2)
+	siteHandler(ctx, broken, func(err error) { reported <- err })
+	require.Error(t, <-reported)
+	select {
+	case err := <-reported:
+		t.Fatalf("reported again: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	status, _, stderr := runCommand("", "serve", filepath.Join(dir, "404.html"))
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "isn't a directory")
+}
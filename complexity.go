@@ -0,0 +1,81 @@
+package redirects
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// A ComplexityReport scores how expensive rules are to match and expand, in
+// relative units where a rule matching a single exact path scores 1.
+type ComplexityReport struct {
+	// Rules holds the score of each rule.
+	Rules []int
+
+	// Total is the score of the whole set of rules.
+	Total int
+}
+
+// Complexity scores rules, so gateways serving many sites can budget how
+// expensive a site's rules may be and spot the expensive ones.
+//
+// Rules matching a single exact path are looked up at once and score 1.
+// Other rules are tried in order for every request they might match, they
+// score 4, plus 1 per path segment compared, 2 per placeholder captured, 4
+// for a splat and 1 per placeholder expanded in To.
+func Complexity(rules Rules) ComplexityReport {
+	report := ComplexityReport{Rules: make([]int, len(rules))}
+	for i, rule := range rules {
+		score := ruleComplexity(rule)
+		report.Rules[i] = score
+		report.Total += score
+	}
+	return report
+}
+
+func ruleComplexity(r Rule) int {
+	p := urlpattern.Compile(r.From)
+	if _, ok := urlpattern.Static(p); ok {
+		return 1
+	}
+
+	score := 4 + len(p.Segments)
+	for _, seg := range p.Segments {
+		if seg.IsParam {
+			score += 2
+		}
+	}
+	if p.Trailing {
+		score += 4
+	}
+	for _, part := range compileTemplate(r.To, p).parts {
+		if part.placeholder {
+			score++
+		}
+	}
+	return score
+}
+
+// WithMaxComplexity makes Parse reject files whose rules score more than
+// max, as computed by Complexity. Zero, the default, disables the limit.
+func WithMaxComplexity(max int) Option {
+	return func(c *config) {
+		c.maxComplexity = max
+	}
+}
+
+// checkComplexity makes sure rules don't score more than max.
+func checkComplexity(rules Rules, max int) error {
+	total := 0
+	for _, rule := range rules {
+		total += ruleComplexity(rule)
+		if total > max {
+			return &ParseError{
+				Line: rule.Line,
+				Code: CodeTooComplex,
+				Err:  fmt.Errorf("rules are too complex, the score exceeds %d from this rule on", max),
+			}
+		}
+	}
+	return nil
+}
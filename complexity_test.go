@@ -0,0 +1,33 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComplexity(t *testing.T) {
+	rules := Must(ParseString(`
+	/a                 /b
+	/posts/:id         /articles/:id
+	/docs/*            /docs/v2/:splat  302
+	/:lang/:page/*     /:page?lang=:lang  200
+	`))
+
+	report := Complexity(rules)
+	require.Equal(t, []int{1, 4 + 3 + 2 + 1, 4 + 2 + 4 + 1, 4 + 3 + 4 + 4 + 2}, report.Rules)
+	require.Equal(t, 1+10+11+17, report.Total)
+
+	require.Equal(t, ComplexityReport{Rules: []int{}}, Complexity(nil))
+}
+
+func TestParseMaxComplexity(t *testing.T) {
+	src := "/a /b\n/posts/:id /articles/:id\n/docs/* /docs/v2/:splat"
+
+	_, err := ParseString(src, WithMaxComplexity(22))
+	require.NoError(t, err)
+
+	_, err = ParseString(src, WithMaxComplexity(21))
+	require.EqualError(t, err, "line 3: rules are too complex, the score exceeds 21 from this rule on")
+	require.Equal(t, CodeTooComplex, ErrorDiagnostic(err).Code)
+}
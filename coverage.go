@@ -0,0 +1,57 @@
+package redirects
+
+// A CoverageReport describes what a set of rules does to the paths of a site.
+type CoverageReport struct {
+	// UnusedRules holds the indexes of the rules that are the first match of
+	// none of the paths.
+	UnusedRules []int
+
+	// Rewritten holds the paths served by a 200 rewrite.
+	Rewritten []string
+
+	// Redirected holds the paths answered with a 3xx redirect.
+	Redirected []string
+
+	// NotFound holds the paths falling through to a 4xx rule, such as a
+	// custom 404 page.
+	NotFound []string
+
+	// Unmatched holds the paths no rule matches.
+	Unmatched []string
+}
+
+// Coverage matches each of paths, e.g. taken from a sitemap or a walk of the
+// site's DAG, against rules and reports what the rules do to them.
+//
+// Gateways only apply rules to paths without content, so for paths of
+// existing content the report tells what would happen if it went missing.
+func Coverage(rules Rules, paths []string) CoverageReport {
+	set := Compile(rules)
+	used := make([]bool, len(rules))
+
+	var report CoverageReport
+	for _, path := range paths {
+		i, rule, ok := set.match(path)
+		if !ok {
+			report.Unmatched = append(report.Unmatched, path)
+			continue
+		}
+		used[i] = true
+
+		switch {
+		case rule.Status == 200:
+			report.Rewritten = append(report.Rewritten, path)
+		case rule.Status >= 300 && rule.Status < 400:
+			report.Redirected = append(report.Redirected, path)
+		default:
+			report.NotFound = append(report.NotFound, path)
+		}
+	}
+
+	for i, u := range used {
+		if !u {
+			report.UnusedRules = append(report.UnusedRules, i)
+		}
+	}
+	return report
+}
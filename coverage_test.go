@@ -0,0 +1,34 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverage(t *testing.T) {
+	rules := Must(ParseString(`
+	/old/*        /new/:splat
+	/app/*        /app/index.html  200
+	/never        /used
+	/gone/*       /410.html        410
+	/*            /404.html        404
+	/after-catch-all  /x
+	`))
+
+	report := Coverage(rules, []string{
+		"/old/a",
+		"/app/settings",
+		"/gone/x",
+		"/whatever",
+		"",
+	})
+
+	require.Equal(t, CoverageReport{
+		UnusedRules: []int{2, 5},
+		Rewritten:   []string{"/app/settings"},
+		Redirected:  []string{"/old/a"},
+		NotFound:    []string{"/gone/x", "/whatever"},
+		Unmatched:   []string{""},
+	}, report)
+}
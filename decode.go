@@ -0,0 +1,55 @@
+package redirects
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ErrDoubleEncoded is wrapped by the errors reporting a path that is still
+// percent-encoded after being decoded once, like /%252e%252e/ decoding to
+// /%2e%2e/, which a second decoding would turn into a dot-segment.
+var ErrDoubleEncoded = errors.New("path is percent-encoded more than once")
+
+// DecodePath decodes the percent-encoded path escaped once, like the path of
+// a request URL, and returns an error wrapping ErrDoubleEncoded if the result
+// is still percent-encoded. Gateways checking the decoded path for
+// traversals can then rely on no layer decoding it again.
+func DecodePath(escaped string) (string, error) {
+	p, err := url.PathUnescape(escaped)
+	if err != nil {
+		return "", err
+	}
+	if percentEncoded(p) {
+		return "", fmt.Errorf("%w: %q", ErrDoubleEncoded, escaped)
+	}
+	return p, nil
+}
+
+// WithSingleDecoding makes a compiled RuleSet reject paths that are still
+// percent-encoded, like /%2e%2e/, which must have been encoded more than once
+// since the paths it matches are decoded already. Match reports no match for
+// them and Resolve returns an error wrapping ErrDoubleEncoded, so placeholders
+// can't carry encoded dot-segments or slashes past the traversal checks of a
+// gateway into destinations decoded again downstream.
+func WithSingleDecoding() Option {
+	return func(c *config) {
+		c.singleDecoding = true
+	}
+}
+
+// percentEncoded reports whether s has a percent-encoded byte, a '%' followed
+// by two hex digits.
+func percentEncoded(s string) bool {
+	for i := 0; i+2 < len(s); i++ {
+		if s[i] == '%' && isHex(s[i+1]) && isHex(s[i+2]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHex reports whether c is a hex digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
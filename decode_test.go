@@ -0,0 +1,48 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodePath(t *testing.T) {
+	for _, tc := range []struct {
+		escaped, path, err string
+	}{
+		{"/a%20b/", "/a b/", ""},
+		{"/100%25", "/100%", ""},
+		{"/%2e%2e/secret", "/../secret", ""},
+		{"/%252e%252e/secret", "", `path is percent-encoded more than once: "/%252e%252e/secret"`},
+		{"/a%252Fb", "", `path is percent-encoded more than once: "/a%252Fb"`},
+		{"/a%zz", "", `invalid URL escape "%zz"`},
+	} {
+		path, err := DecodePath(tc.escaped)
+		if tc.err != "" {
+			require.EqualError(t, err, tc.err, tc.escaped)
+			continue
+		}
+		require.NoError(t, err, tc.escaped)
+		require.Equal(t, tc.path, path)
+	}
+}
+
+func TestRuleSetSingleDecoding(t *testing.T) {
+	rules := []Rule{{From: "/files/*", To: "https://backend.example.com/:splat", Status: 200}}
+
+	set := Compile(rules)
+	rule, ok := set.Match("/files/%2e%2e/admin")
+	require.True(t, ok)
+	require.Equal(t, "https://backend.example.com/%2e%2e/admin", rule.To)
+
+	set = Compile(rules, WithSingleDecoding())
+	_, ok = set.Match("/files/%2e%2e/admin")
+	require.False(t, ok)
+	_, ok, err := set.Resolve("/files/%2e%2e/admin")
+	require.False(t, ok)
+	require.ErrorIs(t, err, ErrDoubleEncoded)
+
+	rule, ok = set.Match("/files/100%/a")
+	require.True(t, ok)
+	require.Equal(t, "https://backend.example.com/100%/a", rule.To)
+}
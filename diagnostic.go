@@ -0,0 +1,314 @@
+package redirects
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Severity is how serious a Diagnostic is.
+type Severity int
+
+const (
+	// SeverityInfo marks harmless oddities worth cleaning up.
+	SeverityInfo Severity = iota - 1
+
+	// SeverityWarning marks likely mistakes, the rules still work.
+	SeverityWarning
+
+	// SeverityError marks problems that make Parse fail, some of them only
+	// in strict mode.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText encodes s as its name, like String.
+func (s Severity) MarshalText() ([]byte, error) {
+	switch s {
+	case SeverityInfo, SeverityWarning, SeverityError:
+		return []byte(s.String()), nil
+	}
+	return nil, fmt.Errorf("invalid severity %d", int(s))
+}
+
+// UnmarshalText decodes a severity encoded by MarshalText.
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "info":
+		*s = SeverityInfo
+	case "warning":
+		*s = SeverityWarning
+	case "error":
+		*s = SeverityError
+	default:
+		return fmt.Errorf("invalid severity %q", text)
+	}
+	return nil
+}
+
+// Codes identify the kind of problem a Diagnostic reports. They are stable,
+// for tools to filter and suppress diagnostics by.
+const (
+	// Reported by Parse.
+	CodeFileTooLarge        = "file-too-large"
+	CodeLineTooLong         = "line-too-long"
+	CodeUnsupportedEncoding = "unsupported-encoding"
+	CodeInvalidUTF8         = "invalid-utf8"
+	CodeInvalidIntegrity    = "invalid-integrity"
+	CodeUnverified          = "unverified"
+	CodeMissingTo           = "missing-to"
+	CodeTooManyFields       = "too-many-fields"
+	CodeInvalidFrom         = "invalid-from"
+	CodeInvalidTo           = "invalid-to"
+	CodeInvalidCID          = "invalid-cid"
+	CodeDisallowedTo        = "disallowed-to"
+	CodeInvalidStatus       = "invalid-status"
+	CodeTooManyPlaceholders = "too-many-placeholders"
+	CodeTooManyRules        = "too-many-rules"
+	CodeTooComplex          = "too-complex"
+	CodeDeprecatedStatus    = "deprecated-status"
+
+	// Reported by Lint, and by Parse in strict mode.
+	CodeUndefinedPlaceholder = "undefined-placeholder"
+	CodeUnusedPlaceholder    = "unused-placeholder"
+	CodeReservedPlaceholder  = "reserved-placeholder"
+	CodeDuplicatePlaceholder = "duplicate-placeholder"
+	CodeDuplicateRule        = "duplicate-rule"
+	CodeConflictingRule      = "conflicting-rule"
+	CodeUnreachableRule      = "unreachable-rule"
+	CodeRulesAfterCatchAll   = "rules-after-catch-all"
+	CodeRedirectLoop         = "redirect-loop"
+	CodeRedundantStatus      = "redundant-status"
+	CodeRedundantRule        = "redundant-rule"
+
+	// Reported by Compliance, and by Parse with WithGatewaySpec.
+	CodeNonStandard = "non-standard"
+
+	// Reported by ValidateDestinations.
+	CodeMissingDestination = "missing-destination"
+
+	// Reported by the importers of other configuration formats.
+	CodeLossyConversion = "lossy-conversion"
+)
+
+// A Diagnostic describes a problem found in a rule.
+type Diagnostic struct {
+	Severity Severity
+
+	// Code identifies the kind of problem, it's one of the Code constants.
+	Code string
+
+	// Rule is the index of the offending rule, or -1 if the problem isn't
+	// with a rule.
+	Rule int
+
+	// Line is the line of the offending rule, or zero if unknown.
+	Line int
+
+	// Column is the 1-based byte column where the offending token starts in
+	// the line, and EndColumn the column just past it. They are zero when
+	// unknown, Lint and ValidateDestinations only know them with WithSource.
+	Column, EndColumn int
+
+	// Related is the index of another rule involved in the problem, such as
+	// the rule shadowing the offending one, or -1.
+	Related int
+
+	// Message describes the problem.
+	Message string
+
+	// Replacement is the text the offending token should be replaced with,
+	// if there's a definite fix.
+	Replacement string
+
+	// strict is true if the problem is an error in strict mode.
+	strict bool
+
+	// field and token are the field and, within it, the token the problem
+	// is with, for locate.
+	field int
+	token string
+}
+
+func (d Diagnostic) String() string {
+	switch {
+	case d.Line > 0:
+		return fmt.Sprintf("line %d: %s", d.Line, d.Message)
+	case d.Rule >= 0:
+		return fmt.Sprintf("rule %d: %s", d.Rule+1, d.Message)
+	}
+	return d.Message
+}
+
+// ErrorDiagnostic returns the Diagnostic describing err, an error returned
+// by Parse, so parse errors can be reported along with Lint's diagnostics.
+func ErrorDiagnostic(err error) Diagnostic {
+	d := Diagnostic{Severity: SeverityError, Rule: -1, Related: -1, Message: err.Error()}
+
+	var parseErr *ParseError
+	var tooLong *LineTooLongError
+	switch {
+	case errors.As(err, &parseErr):
+		d.Code = parseErr.Code
+		d.Line = parseErr.Line
+		d.Column, d.EndColumn = parseErr.Column, parseErr.EndColumn
+		d.Message = parseErr.Err.Error()
+	case errors.As(err, &tooLong):
+		d.Code = CodeLineTooLong
+		d.Line = tooLong.Line
+		d.Message = fmt.Sprintf("line exceeds maximum line length of %d bytes", tooLong.Max)
+	case errors.Is(err, ErrFileTooLarge):
+		d.Code = CodeFileTooLarge
+	case errors.Is(err, ErrUnverified):
+		d.Code = CodeUnverified
+	}
+	return d
+}
+
+// WithSuppressed drops diagnostics with the given codes from the results of
+// Lint and ValidateDestinations and from deprecation warnings. Parse in
+// strict mode doesn't reject rules for the suppressed problems either.
+func WithSuppressed(codes ...string) Option {
+	return func(c *config) {
+		c.suppressed = append(c.suppressed, codes...)
+	}
+}
+
+// suppresses reports whether diagnostics with code are suppressed.
+func (c *config) suppresses(code string) bool {
+	return slices.Contains(c.suppressed, code)
+}
+
+// suppress removes the diagnostics suppressed by c from diags.
+func suppress(diags []Diagnostic, c *config) []Diagnostic {
+	if len(c.suppressed) == 0 {
+		return diags
+	}
+	return slices.DeleteFunc(diags, func(d Diagnostic) bool {
+		return c.suppresses(d.Code)
+	})
+}
+
+// diagnosticJSON is the JSON encoding of a Diagnostic. Its field names are
+// those used by common linters' JSON reports and are stable, unknown values
+// are left out.
+type diagnosticJSON struct {
+	Severity    Severity `json:"severity"`
+	Code        string   `json:"code"`
+	Message     string   `json:"message"`
+	Rule        *int     `json:"rule,omitempty"`
+	Line        int      `json:"line,omitempty"`
+	Column      int      `json:"column,omitempty"`
+	EndColumn   int      `json:"endColumn,omitempty"`
+	Related     *int     `json:"related,omitempty"`
+	Replacement string   `json:"replacement,omitempty"`
+}
+
+// MarshalJSON encodes d as an object with the fields severity ("info",
+// "warning" or "error"), code and message, and when known the fields rule
+// and related (0-based rule indexes), line, column and endColumn (1-based)
+// and replacement.
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	v := diagnosticJSON{
+		Severity:    d.Severity,
+		Code:        d.Code,
+		Message:     d.Message,
+		Line:        d.Line,
+		Column:      d.Column,
+		EndColumn:   d.EndColumn,
+		Replacement: d.Replacement,
+	}
+	if d.Rule >= 0 {
+		v.Rule = &d.Rule
+	}
+	if d.Related >= 0 {
+		v.Related = &d.Related
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a diagnostic encoded by MarshalJSON.
+func (d *Diagnostic) UnmarshalJSON(data []byte) error {
+	var v diagnosticJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*d = Diagnostic{
+		Severity:    v.Severity,
+		Code:        v.Code,
+		Rule:        -1,
+		Line:        v.Line,
+		Column:      v.Column,
+		EndColumn:   v.EndColumn,
+		Related:     -1,
+		Message:     v.Message,
+		Replacement: v.Replacement,
+	}
+	if v.Rule != nil {
+		d.Rule = *v.Rule
+	}
+	if v.Related != nil {
+		d.Related = *v.Related
+	}
+	return nil
+}
+
+// WriteGitHubAnnotations writes diags as GitHub Actions workflow commands,
+// which GitHub shows as annotations of the file at path in pull requests.
+func WriteGitHubAnnotations(w io.Writer, path string, diags []Diagnostic) error {
+	for _, d := range diags {
+		level := "warning"
+		switch d.Severity {
+		case SeverityInfo:
+			level = "notice"
+		case SeverityError:
+			level = "error"
+		}
+
+		props := []string{"file=" + escapeProperty(path)}
+		if d.Line > 0 {
+			props = append(props, fmt.Sprintf("line=%d", d.Line))
+		}
+		if d.Column > 0 {
+			props = append(props, fmt.Sprintf("col=%d,endColumn=%d", d.Column, d.EndColumn))
+		}
+		if d.Code != "" {
+			props = append(props, "title="+escapeProperty(d.Code))
+		}
+
+		_, err := fmt.Fprintf(w, "::%s %s::%s\n", level, strings.Join(props, ","), escapeData(d.Message))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	dataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	propertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return dataEscaper.Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command.
+func escapeProperty(s string) string {
+	return propertyEscaper.Replace(s)
+}
@@ -0,0 +1,103 @@
+package redirects
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorDiagnostic(t *testing.T) {
+	_, err := ParseString("/a /b\n/c ftp://d")
+	require.Equal(t, Diagnostic{
+		Severity:  SeverityError,
+		Code:      CodeInvalidTo,
+		Rule:      -1,
+		Line:      2,
+		Column:    4,
+		EndColumn: 11,
+		Related:   -1,
+		Message:   "parsing 'to': invalid URL scheme",
+	}, ErrorDiagnostic(err))
+	require.Equal(t, err.Error(), ErrorDiagnostic(err).String())
+
+	_, err = ParseString("/a /b\n/c /"+strings.Repeat("d", 20), WithMaxLineLength(10))
+	d := ErrorDiagnostic(err)
+	require.Equal(t, CodeLineTooLong, d.Code)
+	require.Equal(t, "line 2: line exceeds maximum line length of 10 bytes", d.String())
+
+	_, err = ParseString(strings.Repeat("/a /b\n", MaxFileSizeInBytes))
+	d = ErrorDiagnostic(err)
+	require.Equal(t, CodeFileTooLarge, d.Code)
+	require.Equal(t, ErrFileTooLarge.Error(), d.String())
+
+	_, err = ParseString("/a /a", WithStrict())
+	require.Equal(t, CodeRedirectLoop, ErrorDiagnostic(err).Code)
+}
+
+func TestSeverityString(t *testing.T) {
+	require.Equal(t, "info", SeverityInfo.String())
+	require.Equal(t, "warning", SeverityWarning.String())
+	require.Equal(t, "error", SeverityError.String())
+	require.Equal(t, "Severity(7)", Severity(7).String())
+}
+
+func TestWithSuppressed(t *testing.T) {
+	rules := Must(ParseString("/a /a\n/b/:x /c\n/b/:x /c"))
+	require.Len(t, Lint(rules), 4)
+
+	diags := Lint(rules, WithSuppressed(CodeRedirectLoop, CodeDuplicateRule))
+	require.Len(t, diags, 2)
+	for _, d := range diags {
+		require.Equal(t, CodeUnusedPlaceholder, d.Code)
+	}
+
+	_, err := ParseString("/a /a", WithStrict(), WithSuppressed(CodeRedirectLoop))
+	require.NoError(t, err)
+
+	_, err = ParseString("/a /b 0301", WithSuppressed(CodeDeprecatedStatus), WithDeprecationWarnings(func(Diagnostic) {
+		t.Fatal("deprecation warning is suppressed")
+	}))
+	require.NoError(t, err)
+
+	diags = ValidateDestinations(rules, func(string) bool { return false }, WithSuppressed(CodeMissingDestination))
+	require.Empty(t, diags)
+}
+
+func TestDiagnosticJSON(t *testing.T) {
+	src := []byte("/a /b 0301\n/c/:x /d\n/c/:x /d\n")
+	rules := Must(ParseBytes(src))
+	diags := Lint(rules, WithSource(src))
+
+	b, err := json.Marshal(diags)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"severity": "warning", "code": "unused-placeholder", "message": "placeholder \":x\" is not used in 'to'", "rule": 1, "line": 2, "column": 4, "endColumn": 6},
+		{"severity": "warning", "code": "unused-placeholder", "message": "placeholder \":x\" is not used in 'to'", "rule": 2, "line": 3, "column": 4, "endColumn": 6},
+		{"severity": "info", "code": "duplicate-rule", "message": "duplicate of the rule on line 2", "rule": 2, "line": 3, "column": 1, "endColumn": 6, "related": 1}
+	]`, string(b))
+
+	var decoded []Diagnostic
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, diags, decoded)
+
+	_, err = ParseString("/a /b 0301", WithStrict())
+	b, err = json.Marshal(ErrorDiagnostic(err))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"severity": "error", "code": "invalid-status", "message": "parsing status \"0301\": status must be three digits", "line": 1, "column": 7, "endColumn": 11}`, string(b))
+
+	require.Error(t, json.Unmarshal([]byte(`{"severity": "fatal"}`), &decoded[0]))
+}
+
+func TestWriteGitHubAnnotations(t *testing.T) {
+	diags := []Diagnostic{
+		{Severity: SeverityError, Code: CodeRedirectLoop, Rule: 0, Line: 2, Column: 4, EndColumn: 6, Related: -1, Message: "redirect loop /a -> /b -> /a"},
+		{Severity: SeverityInfo, Code: CodeDuplicateRule, Rule: 1, Related: 0, Message: "100% duplicate\nof rule 1"},
+	}
+
+	var b strings.Builder
+	require.NoError(t, WriteGitHubAnnotations(&b, "site/_redirects,v2", diags))
+	require.Equal(t, "::error file=site/_redirects%2Cv2,line=2,col=4,endColumn=6,title=redirect-loop::redirect loop /a -> /b -> /a\n"+
+		"::notice file=site/_redirects%2Cv2,title=duplicate-rule::100%25 duplicate%0Aof rule 1\n", b.String())
+}
@@ -0,0 +1,65 @@
+package redirects
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A DryRunChange is a request whose outcome differs between two rule sets.
+type DryRunChange struct {
+	Before SimResult
+	After  SimResult
+}
+
+// A DryRunReport compares the outcome of requests between two rule sets.
+type DryRunReport struct {
+	// Changes holds the requests that change destination or status, in the
+	// order of the requests.
+	Changes []DryRunChange
+
+	// Total is the number of requests evaluated.
+	Total int
+}
+
+// DryRun evaluates requests against the current and the updated rules and
+// reports the requests that would change destination or status, so updates to
+// a production site's _redirects can be reviewed before publishing.
+func DryRun(current, updated Rules, requests []SimRequest) DryRunReport {
+	before := Simulate(current, requests)
+	after := Simulate(updated, requests)
+
+	report := DryRunReport{Total: len(requests)}
+	for i := range requests {
+		b, a := before[i], after[i]
+		if b.Matched == a.Matched && b.Destination == a.Destination && b.Status == a.Status {
+			continue
+		}
+		report.Changes = append(report.Changes, DryRunChange{Before: b, After: a})
+	}
+	return report
+}
+
+// WriteTo writes a human-readable rendering of the report to w, one line per
+// change followed by a summary:
+//
+//	/old: 301 /new -> 302 /newer
+//	/gone: 301 /elsewhere -> no match
+//	1 of 10 requests change
+func (r DryRunReport) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, c := range r.Changes {
+		fmt.Fprintf(&b, "%s: %s -> %s\n", c.Before.Request.Path, describeOutcome(c.Before), describeOutcome(c.After))
+	}
+	fmt.Fprintf(&b, "%d of %d requests change\n", len(r.Changes), r.Total)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func describeOutcome(r SimResult) string {
+	if !r.Matched {
+		return "no match"
+	}
+	return fmt.Sprintf("%d %s", r.Status, r.Destination)
+}
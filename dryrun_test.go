@@ -0,0 +1,42 @@
+package redirects
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+	current := Must(ParseString(`
+	/old        /new
+	/gone       /elsewhere
+	/same/*     /same-target/:splat
+	`))
+	updated := Must(ParseString(`
+	/moved      /new-place
+	/old        /newer  302
+	/same/*     /same-target/:splat
+	/*          /index.html  200
+	`))
+
+	report := DryRun(current, updated, []SimRequest{
+		{Path: "/old"},
+		{Path: "/gone"},
+		{Path: "/same/a"},
+		{Path: "/moved"},
+	})
+	require.Equal(t, 4, report.Total)
+	require.Len(t, report.Changes, 3)
+	require.Equal(t, "/old", report.Changes[0].Before.Request.Path)
+	require.Equal(t, 302, report.Changes[0].After.Status)
+
+	var b strings.Builder
+	_, err := report.WriteTo(&b)
+	require.NoError(t, err)
+	require.Equal(t, `/old: 301 /new -> 302 /newer
+/gone: 301 /elsewhere -> 200 /index.html
+/moved: no match -> 301 /new-place
+3 of 4 requests change
+`, b.String())
+}
@@ -0,0 +1,187 @@
+package redirects
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ucarion/urlpath"
+)
+
+// An EvalTrace records how a RuleSet evaluated a path: the rules with
+// placeholders or a splat it considered, in order, and the first rule
+// matching the path exactly, if no rule before it matched. Rules without
+// placeholders or splat are looked up by path, they're only considered if
+// they match.
+type EvalTrace struct {
+	// Path is the path evaluated.
+	Path string
+
+	// Steps are the steps of the evaluation.
+	Steps []EvalStep
+
+	// Rule is the index of the matching rule, or -1.
+	Rule int
+}
+
+// An EvalStep is a rule considered while evaluating a path.
+type EvalStep struct {
+	// Rule is the index of the rule, or -1 for the lookup of the rules
+	// matching the path exactly when none does.
+	Rule int
+
+	// Matched is true if the rule matched the path.
+	Matched bool
+
+	// Captures holds the values captured by the placeholders of the rule,
+	// with the splat under "splat", if it matched.
+	Captures map[string]string
+
+	// Reason tells why the rule didn't match, or why its destination is
+	// unsafe if it matched, or is empty.
+	Reason string
+}
+
+// String returns the trace on a single line, for a debug header or a log
+// record, like:
+//
+//	/a/b: rule 2: segment 1 is "a", not "x"; rule 3: matched, splat="b"
+func (t EvalTrace) String() string {
+	var b strings.Builder
+	b.WriteString(t.Path)
+	b.WriteString(": ")
+	if len(t.Steps) == 0 {
+		b.WriteString("no rules")
+	}
+	for n, step := range t.Steps {
+		if n > 0 {
+			b.WriteString("; ")
+		}
+		if step.Rule >= 0 {
+			fmt.Fprintf(&b, "rule %d: ", step.Rule+1)
+		}
+		if !step.Matched {
+			b.WriteString(step.Reason)
+			continue
+		}
+		b.WriteString("matched")
+		names := make([]string, 0, len(step.Captures))
+		for name := range step.Captures {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			sep := " "
+			if i == 0 {
+				sep = ", "
+			}
+			fmt.Fprintf(&b, "%s%s=%q", sep, name, step.Captures[name])
+		}
+		if step.Reason != "" {
+			b.WriteString(", but ")
+			b.WriteString(step.Reason)
+		}
+	}
+	return b.String()
+}
+
+// WithEvaluationTrace makes the RuleSets compiled with it call record with
+// the trace of each evaluation of a path, for gateways to log how a request
+// was handled or attach it to a debug header. Traces are recorded in a pass
+// of their own after the evaluation, which doesn't change its result, so
+// RuleSets compiled without it don't do any more work.
+func WithEvaluationTrace(record func(EvalTrace)) Option {
+	return func(c *config) {
+		c.evalTrace = record
+	}
+}
+
+// trace returns the trace of the evaluation of urlPath.
+func (s *RuleSet) trace(urlPath string) EvalTrace {
+	t := EvalTrace{Path: urlPath, Rule: -1}
+
+	first, static := s.static[urlPath]
+	if !static && len(s.static) > 0 {
+		t.Steps = append(t.Steps, EvalStep{Rule: -1, Reason: "no rule matches the path exactly"})
+	}
+
+	var buf [maxStackSlashes]int
+	slashes := slashOffsets(urlPath, s.segments, buf[:0])
+	for _, i := range s.dynamic {
+		if static && i > first {
+			break
+		}
+		to, matched := s.matchAt(i, urlPath, slashes)
+		if !matched {
+			t.Steps = append(t.Steps, EvalStep{Rule: i, Reason: s.mismatch(i, urlPath)})
+			continue
+		}
+		t.Steps = append(t.Steps, s.matchedStep(i, urlPath, to))
+		t.Rule = i
+		return t
+	}
+
+	if static {
+		t.Steps = append(t.Steps, s.matchedStep(first, urlPath, s.templates[first].expand(nil, "")))
+		t.Rule = first
+	}
+	return t
+}
+
+// matchedStep returns the step of the i-th rule matching urlPath, expanding
+// its To into to.
+func (s *RuleSet) matchedStep(i int, urlPath, to string) EvalStep {
+	step := EvalStep{Rule: i, Matched: true, Captures: s.captures(i, urlPath)}
+	if unsafeDestination(s.rules[i].To, to) {
+		step.Reason = fmt.Sprintf("the destination %q is unsafe", to)
+	}
+	return step
+}
+
+// mismatch returns why the i-th rule doesn't match urlPath.
+func (s *RuleSet) mismatch(i int, urlPath string) string {
+	p := s.patterns[i]
+	if reason := segmentMismatch(p, urlPath); reason != "" {
+		return reason
+	}
+	captures, trailing, _ := matchPath(p, urlPath, nil)
+	if s.maxSplat > 0 && len(trailing) > s.maxSplat {
+		return fmt.Sprintf("the splat is longer than %d bytes", s.maxSplat)
+	}
+	if s.templates[i].expandedLen(captures, trailing) > maxDestinationLength {
+		return "the destination would be longer than 64 KiB"
+	}
+	return "no match"
+}
+
+// segmentMismatch returns why p doesn't match the segments of s, like
+// matchSlashes decides it, or "" if it does.
+func segmentMismatch(p *urlpath.Path, s string) string {
+	segments := strings.Split(s, "/")
+	for n, seg := range p.Segments {
+		if n == len(segments) {
+			break
+		}
+		if !seg.IsParam && segments[n] != seg.Const {
+			return fmt.Sprintf("segment %d is %q, not %q", n, segments[n], seg.Const)
+		}
+	}
+
+	// the leading slash makes an empty first segment
+	have, want := len(segments)-1, len(p.Segments)-1
+	switch {
+	case p.Trailing && have <= want:
+		return fmt.Sprintf("the path has %s, the splat needs more", segmentCount(have))
+	case !p.Trailing && have != want:
+		return fmt.Sprintf("the path has %s, not %d", segmentCount(have), want)
+	}
+	return ""
+}
+
+// segmentCount returns n segments in words.
+func segmentCount(n int) string {
+	if n == 1 {
+		return "1 segment"
+	}
+	return fmt.Sprintf("%d segments", n)
+}
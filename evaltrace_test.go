@@ -0,0 +1,62 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluationTrace(t *testing.T) {
+	var traces []EvalTrace
+	set := Compile(Must(ParseString(`
+/a         /b
+/x/:id     /y/:id
+/docs/*    /d/:splat
+/go/*      /:splat
+/a/b       /c
+/*         /404.html  404
+`)), WithEvaluationTrace(func(t EvalTrace) {
+		traces = append(traces, t)
+	}))
+
+	for _, path := range []string{"/a", "/a/b", "/x/1", "/docs/a/b", "/x/1/2", "/go//example.com"} {
+		set.Match(path)
+	}
+	var got []string
+	for _, trace := range traces {
+		got = append(got, trace.String())
+	}
+	require.Equal(t, []string{
+		`/a: rule 1: matched`,
+		`/a/b: rule 2: segment 1 is "a", not "x"; rule 3: segment 1 is "a", not "docs"; rule 4: segment 1 is "a", not "go"; rule 5: matched`,
+		`/x/1: no rule matches the path exactly; rule 2: matched, id="1"`,
+		`/docs/a/b: no rule matches the path exactly; rule 2: segment 1 is "docs", not "x"; rule 3: matched, splat="a/b"`,
+		`/x/1/2: no rule matches the path exactly; rule 2: the path has 3 segments, not 2; rule 3: segment 1 is "x", not "docs"; rule 4: segment 1 is "x", not "go"; rule 6: matched, splat="x/1/2"`,
+		`/go//example.com: no rule matches the path exactly; rule 2: segment 1 is "go", not "x"; rule 3: segment 1 is "go", not "docs"; rule 4: matched, splat="/example.com", but the destination "//example.com" is unsafe`,
+	}, got)
+
+	require.Equal(t, EvalTrace{
+		Path: "/x/1",
+		Steps: []EvalStep{
+			{Rule: -1, Reason: "no rule matches the path exactly"},
+			{Rule: 1, Matched: true, Captures: map[string]string{"id": "1"}},
+		},
+		Rule: 1,
+	}, traces[2])
+	require.Equal(t, 3, traces[5].Rule, "the trace has the rule matched, although Match doesn't return it")
+}
+
+func TestEvaluationTraceResult(t *testing.T) {
+	// traces agree with the evaluation, also with a cache and a splat limit
+	rules := Must(ParseString("/a/:b/c /x\n/a/* /y\n/a/b/c /z\n/:p /w/:p\n"))
+	var trace EvalTrace
+	set := Compile(rules, WithMatchCache(8), WithMaxSplatLength(3), WithEvaluationTrace(func(t EvalTrace) {
+		trace = t
+	}))
+	for _, path := range []string{"/a/b/c", "/a/b", "/a/bcde", "/b", "/b/c", "/", "/a/b/c"} {
+		i, _, _ := set.match(path)
+		require.Equal(t, i, trace.Rule, path)
+		require.Equal(t, path, trace.Path)
+	}
+	require.Equal(t, `/a/bcde: no rule matches the path exactly; rule 1: the path has 2 segments, not 3; rule 2: the splat is longer than 3 bytes; rule 4: the path has 2 segments, not 1`, set.trace("/a/bcde").String())
+}
@@ -0,0 +1,49 @@
+package redirects
+
+import (
+	"net/url"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// An ExternalTarget is a destination of a rule that's off the site.
+type ExternalTarget struct {
+	// Rule is the index of the rule, and Line its line in the parsed file
+	// or zero.
+	Rule, Line int
+
+	// Scheme is the scheme of the destination, "http", "https", "ipfs" or
+	// "ipns", or empty for a protocol-relative URL.
+	Scheme string
+
+	// Host is the host of the destination without the port, for ipfs://
+	// and ipns:// URLs the CID or name.
+	Host string
+
+	// To is the rule's destination.
+	To string
+}
+
+// ExternalTargets returns the destinations of r that are absolute or
+// protocol-relative URLs, in order, so reviews can list where a site may
+// proxy or redirect users off-site.
+func (r Rules) ExternalTargets() []ExternalTarget {
+	var targets []ExternalTarget
+	for i, rule := range r {
+		if urlpattern.IsRelative(rule.To) {
+			continue
+		}
+		u, err := url.Parse(rule.To)
+		if err != nil || u.Scheme == "" && u.Host == "" {
+			continue
+		}
+		targets = append(targets, ExternalTarget{
+			Rule:   i,
+			Line:   rule.Line,
+			Scheme: u.Scheme,
+			Host:   u.Hostname(),
+			To:     rule.To,
+		})
+	}
+	return targets
+}
@@ -0,0 +1,28 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalTargets(t *testing.T) {
+	rules := Rules(Must(ParseString(`
+/a /b
+/api/* https://api.example.com:8443/:splat 200
+/old http://example.org/new 302
+/cdn/* //cdn.example.net/:splat 200
+/docs ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi
+/blog ipns://blog.example.com/
+`)))
+
+	require.Equal(t, []ExternalTarget{
+		{Rule: 1, Line: 3, Scheme: "https", Host: "api.example.com", To: "https://api.example.com:8443/:splat"},
+		{Rule: 2, Line: 4, Scheme: "http", Host: "example.org", To: "http://example.org/new"},
+		{Rule: 3, Line: 5, Scheme: "", Host: "cdn.example.net", To: "//cdn.example.net/:splat"},
+		{Rule: 4, Line: 6, Scheme: "ipfs", Host: "bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi", To: "ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"},
+		{Rule: 5, Line: 7, Scheme: "ipns", Host: "blog.example.com", To: "ipns://blog.example.com/"},
+	}, rules.ExternalTargets())
+
+	require.Empty(t, Rules(Must(ParseString("/a /b\n/c /d 200"))).ExternalTargets())
+}
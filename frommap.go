@@ -0,0 +1,67 @@
+package redirects
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// FromMap returns a rule redirecting each path of m to its new path with
+// status, or 301 if status is 0, for migration scripts moving pages. The
+// rules are sorted by From, the paths without placeholders or a splat
+// first, so they aren't shadowed by the patterns of m. It returns an error
+// for the first pair, in that order, that isn't a valid rule.
+func FromMap(m map[string]string, status int) (Rules, error) {
+	pairs := make([][2]string, 0, len(m))
+	for from, to := range m {
+		pairs = append(pairs, [2]string{from, to})
+	}
+	slices.SortFunc(pairs, func(a, b [2]string) int {
+		_, aStatic := urlpattern.Static(urlpattern.Compile(a[0]))
+		_, bStatic := urlpattern.Static(urlpattern.Compile(b[0]))
+		switch {
+		case aStatic && !bStatic:
+			return -1
+		case !aStatic && bStatic:
+			return 1
+		}
+		return strings.Compare(a[0], b[0])
+	})
+	return FromPairs(pairs, status)
+}
+
+// FromPairs is FromMap keeping the order of pairs, old path first, for
+// mappings whose order matters, like paths before the patterns matching
+// them too.
+func FromPairs(pairs [][2]string, status int) (Rules, error) {
+	suffix := ""
+	if status != 0 {
+		suffix = " " + strconv.Itoa(status)
+	}
+
+	rules := make(Rules, 0, len(pairs))
+	for _, p := range pairs {
+		from, to := p[0], p[1]
+		if len(strings.Fields(from)) != 1 || len(strings.Fields(to)) != 1 {
+			return nil, fmt.Errorf("invalid rule %q -> %q: paths can't be empty or contain spaces", from, to)
+		}
+		parsed, err := ParseString(from + " " + to + suffix)
+		if err != nil {
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) {
+				err = parseErr.Err
+			}
+			return nil, fmt.Errorf("invalid rule %q -> %q: %w", from, to, err)
+		}
+		if len(parsed) != 1 {
+			return nil, fmt.Errorf("invalid rule %q -> %q: from can't start with #", from, to)
+		}
+		parsed[0].Line = 0
+		rules = append(rules, parsed[0])
+	}
+	return rules, nil
+}
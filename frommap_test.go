@@ -0,0 +1,44 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromMap(t *testing.T) {
+	rules, err := FromMap(map[string]string{
+		"/docs/*":  "/guide/:splat",
+		"/old":     "/new",
+		"/docs/a":  "/guide/intro",
+		"/blog/:y": "https://blog.example.com/:y",
+	}, 0)
+	require.NoError(t, err)
+	require.Equal(t, Rules{
+		{From: "/docs/a", To: "/guide/intro", Status: 301},
+		{From: "/old", To: "/new", Status: 301},
+		{From: "/blog/:y", To: "https://blog.example.com/:y", Status: 301},
+		{From: "/docs/*", To: "/guide/:splat", Status: 301},
+	}, rules)
+
+	rules, err = FromMap(map[string]string{"/a": "/b"}, 302)
+	require.NoError(t, err)
+	require.Equal(t, Rules{{From: "/a", To: "/b", Status: 302}}, rules)
+
+	_, err = FromMap(map[string]string{"/a": "/b"}, 999)
+	require.ErrorContains(t, err, `invalid rule "/a" -> "/b": `)
+
+	_, err = FromMap(map[string]string{"a": "/b"}, 0)
+	require.ErrorContains(t, err, `invalid rule "a" -> "/b": `)
+}
+
+func TestFromPairs(t *testing.T) {
+	rules, err := FromPairs([][2]string{{"/b", "/c"}, {"/a", "/c"}}, 0)
+	require.NoError(t, err)
+	require.Equal(t, Rules{{From: "/b", To: "/c", Status: 301}, {From: "/a", To: "/c", Status: 301}}, rules)
+
+	for _, p := range [][2]string{{"/a b", "/c"}, {"", "/c"}, {"#a", "/c"}} {
+		_, err = FromPairs([][2]string{p}, 0)
+		require.Error(t, err, p)
+	}
+}
@@ -0,0 +1,50 @@
+package gatewayutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/unixfs"
+)
+
+// ParseCAR reads the _redirects file of the site with root CID root from the
+// CARv1 or CARv2 archive r, and returns its rules parsed with opts, for
+// checking sites offline, before they're published or pinned. If root is
+// empty, the site is the only root of the archive. It returns no rules if the
+// site has no _redirects file.
+//
+// The file is found like a gateway does, in plain or HAMT sharded
+// directories, and the blocks read are checked against their CIDs. Files
+// larger than MaxFileSizeInBytes are rejected from their UnixFS size, before
+// reading their content, with an error matching ErrFileTooLarge.
+//
+// If r is an io.ReaderAt, like an *os.File, blocks can be in any order.
+// Otherwise they must come after the blocks linking to them, like in the
+// depth-first order of kubo and most tools.
+func ParseCAR(r io.Reader, root string, opts ...redirects.Option) (redirects.Rules, error) {
+	var cid []byte
+	if root != "" {
+		var err error
+		if cid, err = unixfs.ParseCID(root); err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := unixfs.ReadFile(r, cid, FileName, redirects.MaxFileSizeInBytes)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case errors.Is(err, unixfs.ErrTooLarge):
+		return nil, redirects.ErrFileTooLarge
+	case err != nil:
+		return nil, err
+	}
+	rules, err := redirects.ParseBytes(b, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s file: %w", FileName, err)
+	}
+	return rules, nil
+}
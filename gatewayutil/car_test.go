@@ -0,0 +1,59 @@
+package gatewayutil
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/redirectstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCAR(t *testing.T) {
+	site := redirectstest.NewSite("/old /new 301\n/app/* /index.html 200!\n", map[string]string{"index.html": "home"})
+
+	rules, err := ParseCAR(bytes.NewReader(site.CAR), "", redirects.WithAllowForced())
+	require.NoError(t, err)
+	require.Equal(t, redirects.Rules{
+		{From: "/old", To: "/new", Status: 301, Line: 1},
+		{From: "/app/*", To: "/index.html", Status: 200, Force: true, Line: 2},
+	}, rules)
+
+	rules, err = ParseCAR(bytes.NewReader(site.CAR), site.Root, redirects.WithAllowForced())
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	_, err = ParseCAR(bytes.NewReader(site.CAR), site.Root)
+	require.ErrorContains(t, err, "invalid _redirects file: line 2")
+
+	_, err = ParseCAR(bytes.NewReader(site.CAR), "not a CID")
+	require.ErrorContains(t, err, "invalid CID")
+
+	empty := redirectstest.NewSite("", map[string]string{"index.html": "home"})
+	rules, err = ParseCAR(bytes.NewReader(empty.CAR), "")
+	require.NoError(t, err)
+	require.Nil(t, rules)
+
+	large := redirectstest.NewSite(strings.Repeat("/a /b\n", redirects.MaxFileSizeInBytes/6+1), nil)
+	_, err = ParseCAR(bytes.NewReader(large.CAR), "")
+	require.ErrorIs(t, err, redirects.ErrFileTooLarge)
+}
+
+// stream hides the io.ReaderAt of a reader, like a network stream.
+type stream struct{ io.Reader }
+
+func FuzzParseCAR(f *testing.F) {
+	f.Add(redirectstest.NewSite("/old /new 301\n/app/* /index.html 200\n", map[string]string{"index.html": "home"}).CAR)
+	f.Add(redirectstest.NewSite("", nil).CAR)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rules, err := ParseCAR(bytes.NewReader(data), "")
+		streamed, streamErr := ParseCAR(stream{bytes.NewReader(data)}, "")
+		// streams need blocks in order, so only fail more often
+		if streamErr == nil {
+			require.NoError(t, err)
+			require.Equal(t, rules, streamed)
+		}
+	})
+}
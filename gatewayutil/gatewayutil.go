@@ -0,0 +1,89 @@
+// Package gatewayutil provides the operations IPFS gateways need to apply the
+// _redirects file of a site: locating it under the UnixFS root, checking its
+// size before fetching it, parsing and compiling it, and evaluating its rules
+// against the content of the site. ParseCAR does the same offline, from a CAR
+// archive of the site.
+package gatewayutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+)
+
+// FileName is the name of the _redirects file, at the root of a site.
+const FileName = "_redirects"
+
+// A Getter gets the files of a UnixFS directory, the root of a site. Names
+// are relative to the root, like "_redirects".
+type Getter interface {
+	// Size returns the size of the file at name, from the UnixFS metadata of
+	// the root of its DAG, without fetching the rest. It returns an error
+	// matching fs.ErrNotExist if there is no file at name.
+	Size(ctx context.Context, name string) (int64, error)
+
+	// Open returns the content of the file at name.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// Load gets the _redirects file of the site g gets, and returns its rules
+// parsed and compiled with opts. It returns a nil RuleSet, which matches
+// nothing, if there is no _redirects file.
+//
+// Files larger than MaxFileSizeInBytes are rejected from their UnixFS size,
+// before fetching them, with an error matching ErrFileTooLarge. Parse checks
+// the lower limits of opts, if any.
+func Load(ctx context.Context, g Getter, opts ...redirects.Option) (*redirects.RuleSet, error) {
+	size, err := g.Size(ctx, FileName)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case size > redirects.MaxFileSizeInBytes:
+		return nil, redirects.ErrFileTooLarge
+	}
+
+	f, err := g.Open(ctx, FileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// the size the DAG claims isn't trusted, the read stops past the limit
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(io.LimitReader(f, redirects.MaxFileSizeInBytes+1)); err != nil {
+		return nil, err
+	}
+	rules, err := redirects.ParseBytes(b.Bytes(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s file: %w", FileName, err)
+	}
+	return redirects.Compile(rules, opts...), nil
+}
+
+// Evaluate returns the rule of set applying to urlPath, with its placeholders
+// expanded, and true. Rules that aren't forced only apply to paths without
+// content, exists reports whether there is content at urlPath. It's only
+// called for those rules, so gateways can skip the lookup otherwise.
+//
+// It returns false if no rule applies, and the errors of exists and
+// RuleSet.Resolve.
+func Evaluate(set *redirects.RuleSet, urlPath string, exists func(urlPath string) (bool, error)) (redirects.Rule, bool, error) {
+	rule, ok, err := set.Resolve(urlPath)
+	if err != nil || !ok {
+		return redirects.Rule{}, false, err
+	}
+	if !rule.Force {
+		found, err := exists(urlPath)
+		if err != nil || found {
+			return redirects.Rule{}, false, err
+		}
+	}
+	return rule, true, nil
+}
@@ -0,0 +1,101 @@
+package gatewayutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+// mapGetter gets the files of a map, claiming size as their size if set.
+type mapGetter struct {
+	files  map[string]string
+	size   int64
+	opened bool
+}
+
+func (g *mapGetter) Size(ctx context.Context, name string) (int64, error) {
+	f, ok := g.files[name]
+	if !ok {
+		return 0, fs.ErrNotExist
+	}
+	if g.size != 0 {
+		return g.size, nil
+	}
+	return int64(len(f)), nil
+}
+
+func (g *mapGetter) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	g.opened = true
+	return io.NopCloser(strings.NewReader(g.files[name])), nil
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.Background()
+
+	set, err := Load(ctx, &mapGetter{files: map[string]string{
+		"_redirects": "/old /new 301\n/home / 302!\n",
+	}}, redirects.WithAllowForced())
+	require.NoError(t, err)
+	require.Equal(t, 2, set.Len())
+
+	set, err = Load(ctx, &mapGetter{})
+	require.NoError(t, err)
+	require.Nil(t, set)
+
+	_, err = Load(ctx, &mapGetter{files: map[string]string{"_redirects": "/old /new 999\n"}})
+	require.ErrorContains(t, err, "invalid _redirects file")
+
+	t.Run("size limit", func(t *testing.T) {
+		g := &mapGetter{files: map[string]string{"_redirects": "/old /new\n"}, size: redirects.MaxFileSizeInBytes + 1}
+		_, err := Load(ctx, g)
+		require.ErrorIs(t, err, redirects.ErrFileTooLarge)
+		require.False(t, g.opened)
+
+		// a DAG claiming a smaller size than its content
+		g = &mapGetter{files: map[string]string{"_redirects": strings.Repeat("#\n", redirects.MaxFileSizeInBytes)}, size: 1}
+		_, err = Load(ctx, g)
+		require.ErrorIs(t, err, redirects.ErrFileTooLarge)
+	})
+}
+
+func TestEvaluate(t *testing.T) {
+	rules, err := redirects.ParseString("/home / 302!\n/old /new 301\n/blog/* /posts/:splat 302\n", redirects.WithAllowForced())
+	require.NoError(t, err)
+	set := redirects.Compile(rules)
+
+	content := map[string]bool{"/home": true, "/old": true}
+	var lookups []string
+	exists := func(urlPath string) (bool, error) {
+		lookups = append(lookups, urlPath)
+		return content[urlPath], nil
+	}
+
+	rule, ok, err := Evaluate(set, "/home", exists)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "/", rule.To)
+
+	_, ok, err = Evaluate(set, "/old", exists)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	rule, ok, err = Evaluate(set, "/blog/hello", exists)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "/posts/hello", rule.To)
+
+	_, ok, err = Evaluate(set, "/missing", exists)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, []string{"/old", "/blog/hello"}, lookups)
+
+	failed := errors.New("failed")
+	_, _, err = Evaluate(set, "/old", func(string) (bool, error) { return false, failed })
+	require.ErrorIs(t, err, failed)
+}
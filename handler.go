@@ -0,0 +1,175 @@
+//go:build !tinygo
+
+package redirects
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Handler returns an http.Handler serving the files of fsys and applying the
+// rules of the _redirects file at its root, if any, like a gateway does, for
+// previewing sites locally:
+//
+//   - rules only apply to paths without content, unless they're forced
+//   - redirects respond with the rule's status and To as Location
+//   - rewrites and 4xx rules serve the content at To with the rule's status
+//
+// Rewrites to URLs respond 501 Not Implemented, the handler doesn't proxy
+// requests. If the _redirects file doesn't parse with opts, every request
+// responds 500 Internal Server Error with the parse error.
+//
+// Handler isn't available with TinyGo, whose net/http is incomplete.
+func Handler(fsys fs.FS, opts ...Option) http.Handler {
+	h := &fsHandler{fsys: fsys}
+	b, err := fs.ReadFile(fsys, "_redirects")
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		h.err = err
+	default:
+		rules, err := ParseBytes(b, opts...)
+		h.set, h.err = Compile(rules, opts...), err
+	}
+	return h
+}
+
+// Handler returns an http.Handler serving the files of fsys like the one
+// Handler returns, applying the current RuleSet of s instead of reading the
+// _redirects file of fsys once, so rules reloaded with Watch apply to the
+// next requests.
+func (s *Store) Handler(fsys fs.FS) http.Handler {
+	return &fsHandler{fsys: fsys, store: s}
+}
+
+type fsHandler struct {
+	fsys fs.FS
+	set  *RuleSet
+
+	// store, if not nil, holds the RuleSet to use instead of set.
+	store *Store
+
+	// err is the error reading or parsing the _redirects file.
+	err error
+}
+
+func (h *fsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.err != nil {
+		http.Error(w, fmt.Sprintf("invalid _redirects file: %v", h.err), http.StatusInternalServerError)
+		return
+	}
+
+	set := h.set
+	if h.store != nil {
+		set = h.store.Load()
+	}
+	exists := h.exists(r.URL.Path)
+	i, rule, ok, err := set.resolve(r.URL.Path)
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ok && (rule.Force || !exists):
+		h.apply(w, r, rule, set.rules[i])
+	case exists:
+		h.serve(w, r, r.URL.Path, http.StatusOK)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// apply responds to r according to rule, orig with its placeholders
+// expanded.
+func (h *fsHandler) apply(w http.ResponseWriter, r *http.Request, rule, orig Rule) {
+	res := simulateRule(rule)
+	switch {
+	case res.Location != "":
+		http.Redirect(w, r, res.Location, res.Status)
+	case res.ProxyURL != "":
+		http.Error(w, fmt.Sprintf("proxying to %s is not supported", res.ProxyURL), http.StatusNotImplemented)
+	default:
+		p, ok := decodedDestination(orig, r.URL.Path)
+		if !ok || !h.exists(p) {
+			http.NotFound(w, r)
+			return
+		}
+		h.serve(w, r, p, res.Status)
+	}
+}
+
+// decodedDestination returns the path the rewrite or 4xx rule serves for
+// urlPath, decoded. Only the literal parts of its To are percent-decoded, the
+// values captured from urlPath are decoded already: with /a/:x /b/:x 200,
+// /a/%2541 serves /b/%41, not /b/A.
+func decodedDestination(rule Rule, urlPath string) (string, bool) {
+	// decoded colons stay literal, not the start of placeholders
+	to := colonEscapes.Replace(destinationPath(rule.To))
+	to, err := url.PathUnescape(to)
+	if err != nil {
+		return "", false
+	}
+	rule.To = to
+	return rule.To, rule.MatchAndExpandPlaceholders(urlPath)
+}
+
+// colonEscapes replaces the percent-encoded colons of a To with escaped ones.
+var colonEscapes = strings.NewReplacer("%3A", "::", "%3a", "::")
+
+// exists reports whether there is content at urlPath, a file or a directory
+// with an index.html file.
+func (h *fsHandler) exists(urlPath string) bool {
+	name := fsName(urlPath)
+	fi, err := fs.Stat(h.fsys, name)
+	if err == nil && fi.IsDir() {
+		_, err = fs.Stat(h.fsys, path.Join(name, "index.html"))
+	}
+	return err == nil
+}
+
+// serve responds to r with the file at urlPath, or the index.html file of
+// the directory at urlPath, and status.
+func (h *fsHandler) serve(w http.ResponseWriter, r *http.Request, urlPath string, status int) {
+	name := fsName(urlPath)
+	fi, err := fs.Stat(h.fsys, name)
+	if err == nil && fi.IsDir() {
+		name = path.Join(name, "index.html")
+		fi, err = fs.Stat(h.fsys, name)
+	}
+	var b []byte
+	if err == nil {
+		b, err = fs.ReadFile(h.fsys, name)
+	}
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if status == http.StatusOK {
+		http.ServeContent(w, r, name, fi.ModTime(), bytes.NewReader(b))
+		return
+	}
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype == "" {
+		ctype = http.DetectContentType(b)
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(b)
+	}
+}
+
+// fsName returns the name in an fs.FS of the content at urlPath.
+func fsName(urlPath string) string {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
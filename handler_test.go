@@ -0,0 +1,109 @@
+//go:build !tinygo
+
+package redirects
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	fsys := fstest.MapFS{
+		"_redirects": {Data: []byte(`
+/old          /new            301
+/exists       /elsewhere      302
+/forced       /new            302!
+/app/*        /app/index.html 200
+/api/*        https://api.example.com/:splat 200
+/gone/*       /404.html       404
+`)},
+		"index.html":     {Data: []byte("home")},
+		"new":            {Data: []byte("new")},
+		"exists":         {Data: []byte("exists")},
+		"forced":         {Data: []byte("shadowed")},
+		"app/index.html": {Data: []byte("<html>app</html>")},
+		"404.html":       {Data: []byte("<html>not found</html>")},
+	}
+	h := Handler(fsys, WithAllowForced())
+
+	for _, tc := range []struct {
+		path     string
+		status   int
+		location string
+		body     string
+	}{
+		{"/", http.StatusOK, "", "home"},
+		{"/new", http.StatusOK, "", "new"},
+		{"/old", http.StatusMovedPermanently, "/new", ""},
+		{"/exists", http.StatusOK, "", "exists"},
+		{"/forced", http.StatusFound, "/new", ""},
+		{"/app/some/route", http.StatusOK, "", "<html>app</html>"},
+		{"/api/users", http.StatusNotImplemented, "", ""},
+		{"/gone/page", http.StatusNotFound, "", "<html>not found</html>"},
+		{"/missing", http.StatusNotFound, "", ""},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		require.Equal(t, tc.status, rec.Code, tc.path)
+		require.Equal(t, tc.location, rec.Header().Get("Location"), tc.path)
+		if tc.body != "" {
+			require.Equal(t, tc.body, rec.Body.String(), tc.path)
+		}
+	}
+
+	// an invalid file fails every request
+	h = Handler(fstest.MapFS{"_redirects": {Data: []byte("/a /b 999")}, "a": {}})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a", nil))
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	// sites without a _redirects file are served as they are
+	h = Handler(fstest.MapFS{"a": {Data: []byte("a")}})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a", nil))
+	require.Equal(t, "a", rec.Body.String())
+}
+
+func TestHandlerDecoding(t *testing.T) {
+	fsys := fstest.MapFS{
+		"_redirects": {Data: []byte("/a/:x /b/:x 200\n/c /caf%C3%A9 200\n/d /x%3Ay 200\n")},
+		"b/%41":      {Data: []byte("encoded")},
+		"b/A":        {Data: []byte("decoded twice")},
+		"café":       {Data: []byte("café")},
+		"x:y":        {Data: []byte("colon")},
+	}
+	h := Handler(fsys)
+
+	for path, body := range map[string]string{
+		// captured values are decoded once, with the request path
+		"/a/%2541": "encoded",
+		"/a/A":     "decoded twice",
+		// literal parts of To are decoded
+		"/c": "café",
+		"/d": "colon",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+		require.Equal(t, body, rec.Body.String(), path)
+	}
+}
+
+func TestStoreHandler(t *testing.T) {
+	var store Store
+	h := store.Handler(fstest.MapFS{"new.html": {Data: []byte("new")}})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/old", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	store.Swap(Must(ParseString("/old /new.html 302")))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/old", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	require.Equal(t, "/new.html", rec.Header().Get("Location"))
+}
@@ -0,0 +1,51 @@
+package redirects
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strconv"
+)
+
+// WriteTo writes the rules to w in their canonical _redirects form: one rule
+// per line, fields separated by a single space and the status always present.
+// Parsing the output returns the same rules, with WithAllowForced if some are
+// forced.
+func (r Rules) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, rule := range r {
+		bw.WriteString(rule.From)
+		bw.WriteByte(' ')
+		bw.WriteString(rule.To)
+		bw.WriteByte(' ')
+		bw.WriteString(strconv.Itoa(rule.Status))
+		if rule.Force {
+			bw.WriteByte('!')
+		}
+		bw.WriteByte('\n')
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Hash returns a hex encoded SHA-256 digest of the rules in their canonical
+// form. Files differing only in comments, whitespace or implicit statuses
+// have the same hash, so it can be used for cache keys and ETags.
+func (r Rules) Hash() string {
+	h := sha256.New()
+	r.WriteTo(h)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
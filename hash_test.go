@@ -0,0 +1,42 @@
+package redirects
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulesWriteTo(t *testing.T) {
+	rules := Rules(Must(ParseString(`
+	# comment
+	/home     /
+	/posts/:slug    /articles/:slug   302
+	/*  /index.html  200
+	`)))
+
+	var b strings.Builder
+	n, err := rules.WriteTo(&b)
+	require.NoError(t, err)
+	require.Equal(t, int64(b.Len()), n)
+	require.Equal(t, "/home / 301\n/posts/:slug /articles/:slug 302\n/* /index.html 200\n", b.String())
+
+	again, err := ParseString(b.String())
+	require.NoError(t, err)
+	for i := range again {
+		require.Equal(t, rules[i].From, again[i].From)
+		require.Equal(t, rules[i].To, again[i].To)
+		require.Equal(t, rules[i].Status, again[i].Status)
+	}
+}
+
+func TestRulesHash(t *testing.T) {
+	a := Rules(Must(ParseString("/a /b\n/c /d 302\n")))
+	b := Rules(Must(ParseString("# same rules\r\n\r\n/a   /b   301\r\n\t/c /d 302")))
+	c := Rules(Must(ParseString("/c /d 302\n/a /b\n")))
+
+	require.Len(t, a.Hash(), 64)
+	require.Equal(t, a.Hash(), b.Hash())
+	require.NotEqual(t, a.Hash(), c.Hash(), "order matters")
+	require.NotEqual(t, a.Hash(), Rules(nil).Hash())
+}
@@ -0,0 +1,326 @@
+package redirects
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// maxHostLength is the longest hostname DNS can resolve, without the
+// trailing dot.
+const maxHostLength = 253
+
+// maxLabelLength is the longest label of a hostname.
+const maxLabelLength = 63
+
+// isWebURL reports whether u is an http or https URL, or a protocol-relative
+// one, which browsers and proxies resolve to one of those.
+func isWebURL(u *url.URL) bool {
+	return u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "" && u.Host != ""
+}
+
+// isHTTPURL reports whether the destination to is a cleartext http:// URL.
+func isHTTPURL(to string) bool {
+	u, err := url.Parse(to)
+	return err == nil && u.Scheme == "http"
+}
+
+// normalizeProxyURL validates the host of the absolute or protocol-relative
+// http or https URL s, parsed as u, and returns s with an internationalized
+// host converted to punycode, so proxy targets that can't possibly resolve
+// are rejected when the file is parsed rather than when a request is proxied.
+func normalizeProxyURL(s string, u *url.URL) (string, error) {
+	host := u.Hostname()
+	if host == "" {
+		return "", fmt.Errorf("URL must have a host")
+	}
+
+	// IPv6 literals are the only hosts in brackets
+	if strings.HasPrefix(u.Host, "[") {
+		if _, err := netip.ParseAddr(host); err != nil {
+			return "", fmt.Errorf("invalid IPv6 address %q", host)
+		}
+		return s, nil
+	}
+
+	ascii, err := toASCIIHost(host)
+	if err != nil {
+		return "", err
+	}
+
+	// the raw host is the same unless it's percent-encoded or has non-ASCII
+	// characters, then it's replaced in place so the rest of s is unchanged
+	start := strings.Index(s, "//") + 2
+	end := start + strings.IndexAny(s[start:]+"/", "/?#")
+	authority := s[start:end]
+	if i := strings.LastIndexByte(authority, '@'); i >= 0 {
+		start += i + 1
+		authority = authority[i+1:]
+	}
+	if authority == ascii || strings.HasPrefix(authority, ascii+":") {
+		return s, nil
+	}
+	if port := u.Port(); port != "" {
+		ascii += ":" + port
+	}
+	return s[:start] + ascii + s[end:], nil
+}
+
+// toASCIIHost validates the hostname host and returns it with its
+// internationalized labels lowercased and converted to punycode. ASCII labels
+// are returned as is.
+func toASCIIHost(host string) (string, error) {
+	if len(strings.TrimSuffix(host, ".")) > maxHostLength {
+		return "", fmt.Errorf("host %q is longer than %d bytes", host, maxHostLength)
+	}
+
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		// a fully qualified host ends with a dot
+		if label == "" && i == len(labels)-1 && i > 0 {
+			continue
+		}
+
+		ascii := isASCII(label)
+		if !ascii {
+			label = strings.ToLower(label)
+		}
+		if err := checkLabel(label); err != nil {
+			return "", fmt.Errorf("invalid host %q: %w", host, err)
+		}
+		if !ascii {
+			labels[i] = "xn--" + punycode(label)
+		}
+		if len(labels[i]) > maxLabelLength {
+			return "", fmt.Errorf("invalid host %q: label %q is longer than %d bytes", host, labels[i], maxLabelLength)
+		}
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// checkLabel checks that label is made of letters, digits and inner hyphens.
+func checkLabel(label string) error {
+	if label == "" {
+		return fmt.Errorf("empty label")
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return fmt.Errorf("label %q begins or ends with a hyphen", label)
+	}
+	for _, r := range label {
+		switch {
+		case r == '-':
+		case r < utf8.RuneSelf:
+			if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+				return fmt.Errorf("label %q has invalid character %q", label, r)
+			}
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r):
+			return fmt.Errorf("label %q has invalid character %q", label, r)
+		}
+	}
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Punycode parameters, see RFC 3492 section 5.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punycode encodes label as described in RFC 3492, without the ACE prefix.
+// Labels are at most a few hundred bytes long, far from overflowing.
+func punycode(label string) string {
+	runes := []rune(label)
+
+	var b strings.Builder
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+		}
+	}
+	basic := b.Len()
+	if basic > 0 {
+		b.WriteByte('-')
+	}
+
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for h := basic; h < len(runes); {
+		// the smallest code point not handled yet
+		m := rune(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += int(m-n) * (h + 1)
+		n = m
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+				if q < t {
+					break
+				}
+				b.WriteByte(punyDigit(t + (q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			b.WriteByte(punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == basic)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return b.String()
+}
+
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+
+	k := 0
+	for delta > (punyBase-punyTMin)*punyTMax/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// WithProxyHosts makes Parse reject rules whose To is an http, https or
+// protocol-relative URL with a host other than hosts. A host starting with
+// "*." allows all the subdomains of the rest, but not the rest itself, so
+// "*.example.com" allows "a.example.com" and "a.b.example.com".
+// Internationalized hosts can be given in Unicode or punycode.
+func WithProxyHosts(hosts ...string) Option {
+	return func(c *config) {
+		// allowing no host at all still rejects every proxy
+		if c.proxyHosts == nil {
+			c.proxyHosts = []string{}
+		}
+		for _, h := range hosts {
+			c.proxyHosts = append(c.proxyHosts, normalizeHostPattern(h))
+		}
+	}
+}
+
+// WithDynamicProxyHosts lets rules fill the host of a URL destination, its
+// user info or port with placeholders, like
+// "/:tenant/* https://:tenant.example.com/:splat 200". Requests then choose
+// where they are redirected or proxied to, so it's rejected by default, and
+// along with WithProxyHosts or WithoutPrivateProxies since such hosts can't
+// be checked when parsing. Gateways should check the expanded hosts instead.
+func WithDynamicProxyHosts() Option {
+	return func(c *config) {
+		c.dynamicProxyHosts = true
+	}
+}
+
+// fillHostPlaceholders returns the destination to with the placeholders of
+// the rule's from filled in with a valid label in its authority, and true if
+// it has any there.
+func fillHostPlaceholders(to []byte, from string) (string, bool) {
+	start, end := urlpattern.Authority(to)
+	if bytes.IndexByte(to[start:end], ':') < 0 {
+		return "", false
+	}
+	names := urlpattern.Placeholders(urlpattern.Compile(from))
+
+	var b strings.Builder
+	literal := 0
+	for i := start; i < end; i++ {
+		if to[i] != ':' {
+			continue
+		}
+		ph, ok := urlpattern.At(string(to[i+1:end]), names)
+		if !ok {
+			continue
+		}
+		b.Write(to[literal:i])
+		b.WriteString("x")
+		i += len(ph.Name)
+		literal = i + 1
+	}
+	if b.Len() == 0 {
+		return "", false
+	}
+	b.Write(to[literal:])
+	return b.String(), true
+}
+
+// normalizeHostPattern returns pattern lowercased, in punycode and without a
+// trailing dot or IPv6 brackets, like hosts are compared.
+func normalizeHostPattern(pattern string) string {
+	wildcard := strings.HasPrefix(pattern, "*.")
+	host := strings.TrimPrefix(pattern, "*.")
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if ascii, err := toASCIIHost(host); err == nil {
+		host = ascii
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if wildcard {
+		return "*." + host
+	}
+	return host
+}
+
+// checkProxyHost makes sure the host of the http, https or protocol-relative
+// URL to is one of the allowed host patterns.
+func checkProxyHost(to string, allowed []string) error {
+	u, err := url.Parse(to)
+	if err != nil || !isWebURL(u) {
+		return nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, pattern := range allowed {
+		if host == pattern {
+			return nil
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q is not allowed", u.Hostname())
+}
@@ -0,0 +1,107 @@
+package redirects
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPunycode(t *testing.T) {
+	// examples from RFC 3492 section 7.1 and common IDNs
+	for label, want := range map[string]string{
+		"münchen":           "mnchen-3ya",
+		"bücher":            "bcher-kva",
+		"ü":                 "tda",
+		"例え":                "r8jz45g",
+		"ليهمابتكلموشعربي؟": "egbpdaj6bu4bxfgehfvwxn",
+		"他们为什么不说中文":         "ihqwcrb4cv8a8dqg056pqjye",
+		"3年b組金八先生":          "3b-ww4c5e180e575a65lsy2b",
+	} {
+		require.Equal(t, want, punycode(label), label)
+	}
+}
+
+func TestToASCIIHost(t *testing.T) {
+	host, err := toASCIIHost("Bücher.Example.")
+	require.NoError(t, err)
+	require.Equal(t, "xn--bcher-kva.Example.", host)
+
+	_, err = toASCIIHost("a b.com")
+	require.EqualError(t, err, `invalid host "a b.com": label "a b" has invalid character ' '`)
+
+	_, err = toASCIIHost("\u00fc\u200b.com")
+	require.Error(t, err)
+}
+
+func TestParseProxyHosts(t *testing.T) {
+	allow := WithProxyHosts("example.com", "*.example.net", "Bücher.example.", "[::1]")
+	for _, to := range []string{
+		"https://example.com/a",
+		"https://EXAMPLE.com./a",
+		"http://a.example.net",
+		"http://a.b.example.net:8080/",
+		"https://bücher.example/",
+		"https://xn--bcher-kva.example/",
+		"/relative",
+		"ipfs://bafy",
+		"http://[::1]:8080/",
+		"//a.example.net/a",
+	} {
+		_, err := ParseString("/a "+to, allow)
+		require.NoError(t, err, to)
+	}
+
+	for _, to := range []string{
+		"https://example.org",
+		"https://a.example.com",
+		"https://example.net",
+		"https://evilexample.net",
+		"http://[::2]/",
+		"//example.org/a",
+	} {
+		_, err := ParseString("/a "+to, allow)
+		require.ErrorContains(t, err, "is not allowed", to)
+		require.Equal(t, CodeDisallowedTo, ErrorDiagnostic(err).Code)
+	}
+
+	_, err := ParseString("/a https://example.com", WithProxyHosts())
+	require.EqualError(t, err, `line 1: parsing 'to': host "example.com" is not allowed`)
+}
+
+func TestParseDynamicProxyHosts(t *testing.T) {
+	for _, file := range []string{
+		"/:tenant/* https://:tenant.example.com/:splat 200",
+		"/:user/* https://:user@example.com/:splat 200",
+		"/* //:splat",
+		"/:cid/* ipfs://:cid/:splat 200",
+	} {
+		_, err := ParseString(file)
+		require.Error(t, err, file)
+
+		rules, err := ParseString(file, WithDynamicProxyHosts())
+		require.NoError(t, err, file)
+		require.Equal(t, strings.Fields(file)[1], rules[0].To)
+
+		_, err = ParseString(file, WithDynamicProxyHosts(), WithProxyHosts("*.example.com"))
+		require.EqualError(t, err, "line 1: parsing 'to': placeholders are not allowed in the host")
+		require.Equal(t, CodeDisallowedTo, ErrorDiagnostic(err).Code)
+	}
+
+	// without a matching placeholder in 'from' it's literal, also when the
+	// same destination was parsed with one before
+	rules, err := ParseString("/a https://:user@example.com/\n/:user https://:user@example.com/", WithDynamicProxyHosts())
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	_, err = ParseString("/a https://:user@example.com/\n/:user https://:user@example.com/")
+	require.EqualError(t, err, "line 2: parsing 'to': placeholders are not allowed in the host")
+
+	_, err = ParseString("/:tenant https://:tenant.exa_mple.com/", WithDynamicProxyHosts())
+	require.ErrorContains(t, err, "invalid host")
+
+	set := Compile(Must(ParseString("/:tenant/* https://:tenant.example.com/:splat 200", WithDynamicProxyHosts())))
+	to, ok, err := set.Resolve("/docs/a/b")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "https://docs.example.com/a/b", to.To)
+}
@@ -0,0 +1,77 @@
+package redirects
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// ErrIntegrityMismatch is wrapped by the error Parse returns when the content
+// of a file doesn't match its integrity pragma.
+var ErrIntegrityMismatch = errors.New("content does not match the integrity pragma")
+
+// integrityPrefix starts the integrity pragma, after the comment's '#'.
+var integrityPrefix = []byte("integrity:")
+
+// parseIntegrity returns the hash and the digest of the integrity pragma in
+// comment, the trimmed first line of a file, or a nil hash if the comment
+// isn't a pragma.
+//
+// The pragma is written like a subresource integrity value, e.g.
+// "# integrity: sha256-<base64 digest>", with the algorithm one of sha256,
+// sha384 and sha512. The digest covers the rest of the file, from the byte
+// following the pragma's line terminator.
+func parseIntegrity(line, comment []byte) (hash.Hash, []byte, error) {
+	value, ok := bytes.CutPrefix(bytes.TrimSpace(comment[1:]), integrityPrefix)
+	if !ok {
+		return nil, nil, nil
+	}
+	// the comment is trimmed already, trimming the value with TrimSpace
+	// could lose its offset in line
+	value = value[len(value)-len(bytes.TrimLeft(value, " \t")):]
+
+	alg, encoded, _ := bytes.Cut(value, []byte{'-'})
+	var h hash.Hash
+	switch string(alg) {
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return nil, nil, fieldError(line, value, 1, CodeInvalidIntegrity, fmt.Errorf("integrity pragma: unsupported algorithm %q", alg))
+	}
+
+	digest, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil || len(digest) != h.Size() {
+		return nil, nil, fieldError(line, value, 1, CodeInvalidIntegrity, fmt.Errorf("integrity pragma: invalid %s digest", alg))
+	}
+	return h, digest, nil
+}
+
+// AddIntegrity returns src with an integrity pragma for the rest of it as
+// its first line, replacing the pragma src already has, so pipelines
+// generating _redirects files can have Parse detect when one was truncated
+// or modified before being published.
+func AddIntegrity(src []byte) []byte {
+	src = bytes.TrimPrefix(src, utf8BOM)
+
+	first, rest, found := bytes.Cut(src, []byte{'\n'})
+	if comment := bytes.TrimSpace(first); len(comment) > 0 && comment[0] == '#' {
+		if bytes.HasPrefix(bytes.TrimSpace(comment[1:]), integrityPrefix) {
+			src = nil
+			if found {
+				src = rest
+			}
+		}
+	}
+
+	sum := sha256.Sum256(src)
+	pragma := "# integrity: sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "\n"
+	return append([]byte(pragma), src...)
+}
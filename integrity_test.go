@@ -0,0 +1,68 @@
+package redirects
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIntegrity(t *testing.T) {
+	signed := AddIntegrity([]byte("# generated\n/a /b\n/c /d 302\n"))
+	require.Equal(t, "# integrity: sha256-", string(signed[:20]))
+
+	rules, err := ParseBytes(signed)
+	require.NoError(t, err)
+	require.Equal(t, []Rule{
+		{From: "/a", To: "/b", Status: 301, Line: 3},
+		{From: "/c", To: "/d", Status: 302, Line: 4},
+	}, rules)
+
+	// signing again replaces the pragma
+	require.Equal(t, signed, AddIntegrity(signed))
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := ParseBytes(signed[:len(signed)-7])
+		require.EqualError(t, err, "line 1: content does not match the integrity pragma")
+		require.True(t, errors.Is(err, ErrIntegrityMismatch))
+		require.Equal(t, CodeInvalidIntegrity, ErrorDiagnostic(err).Code)
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		modified := append([]byte(nil), signed...)
+		modified[len(modified)-2] = '1'
+		_, err := ParseBytes(modified)
+		require.ErrorIs(t, err, ErrIntegrityMismatch)
+	})
+
+	t.Run("sha512", func(t *testing.T) {
+		file := "# integrity: sha512-z4PhNX7vuL3xVChQ1m2AB9Yg5AULVxXcg/SpIdNs6c5H0NE8XYXysP+DGNKHfuwvY7kxvUdBeoGlODJ6+SfaPg==\n"
+		rules, err := ParseString(file)
+		require.NoError(t, err)
+		require.Empty(t, rules)
+	})
+
+	t.Run("not on the first line", func(t *testing.T) {
+		_, err := ParseString("/a /b\n# integrity: sha256-invalid\n")
+		require.NoError(t, err)
+	})
+
+	for _, tc := range []struct {
+		pragma, err string
+		column      int
+	}{
+		{"# integrity: md5-1B2M2Y8AsgTpgAmY7PhCfg==", `line 1: integrity pragma: unsupported algorithm "md5"`, 14},
+		{"# integrity:", `line 1: integrity pragma: unsupported algorithm ""`, 13},
+		{"# integrity: sha256-!!", "line 1: integrity pragma: invalid sha256 digest", 14},
+		{"# integrity: sha256-1B2M2Y8AsgTpgAmY7PhCfg==", "line 1: integrity pragma: invalid sha256 digest", 14},
+	} {
+		t.Run(tc.pragma, func(t *testing.T) {
+			_, err := ParseString(tc.pragma + "\n/a /b\n")
+			require.EqualError(t, err, tc.err)
+
+			var parseErr *ParseError
+			require.ErrorAs(t, err, &parseErr)
+			require.Equal(t, tc.column, parseErr.Column)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package cbor implements the subset of dag-cbor the module reads and
+// writes: unsigned integers, byte and text strings, lists, maps, tags and
+// booleans, with definite lengths in their shortest form. It's shared by the
+// CAR reader of internal/unixfs and the codec of redirectsipld.
+package cbor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Major types.
+const (
+	MajorUint   = 0
+	MajorBytes  = 2
+	MajorString = 3
+	MajorList   = 4
+	MajorMap    = 5
+	MajorTag    = 6
+)
+
+// Simple values.
+const (
+	False = 0xf4
+	True  = 0xf5
+)
+
+// AppendHead appends the head of an item of major type major with argument
+// n, in its shortest form.
+func AppendHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= 0xff:
+		return append(b, major|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), n)
+}
+
+// AppendString appends the text string s.
+func AppendString(b []byte, s string) []byte {
+	return append(AppendHead(b, MajorString, uint64(len(s))), s...)
+}
+
+// A Decoder reads the items of Data, keeping the first error in Err. Once it
+// failed, reads return zero values.
+type Decoder struct {
+	Data []byte
+	Err  error
+}
+
+// Fail records an error, unless there's one already.
+func (d *Decoder) Fail(format string, args ...any) {
+	if d.Err == nil {
+		d.Err = fmt.Errorf(format, args...)
+	}
+}
+
+// Head reads the head of an item of major type major and returns its
+// argument. dag-cbor only allows definite lengths in their shortest form.
+func (d *Decoder) Head(major byte) uint64 {
+	if d.Err != nil {
+		return 0
+	}
+	if len(d.Data) == 0 {
+		d.Fail("unexpected end of data")
+		return 0
+	}
+	if got := d.Data[0] >> 5; got != major {
+		d.Fail("got CBOR major type %d, want %d", got, major)
+		return 0
+	}
+	info := d.Data[0] & 0x1f
+	d.Data = d.Data[1:]
+	if info < 24 {
+		return uint64(info)
+	}
+	if info > 27 {
+		d.Fail("unsupported CBOR additional information %d", info)
+		return 0
+	}
+	size := 1 << (info - 24)
+	if len(d.Data) < size {
+		d.Fail("unexpected end of data")
+		return 0
+	}
+	var n uint64
+	for _, c := range d.Data[:size] {
+		n = n<<8 | uint64(c)
+	}
+	d.Data = d.Data[size:]
+	if n < 24 || size > 1 && n < 1<<(8*size/2) {
+		d.Fail("integer %d not in its shortest form", n)
+	}
+	return n
+}
+
+// Bytes reads a byte string, sharing the memory of Data.
+func (d *Decoder) Bytes() []byte {
+	return d.read(MajorBytes)
+}
+
+// Text reads a text string, which must be valid UTF-8.
+func (d *Decoder) Text() string {
+	b := d.read(MajorString)
+	if d.Err == nil && !utf8.Valid(b) {
+		d.Fail("text string is not valid UTF-8")
+		return ""
+	}
+	return string(b)
+}
+
+// read reads the content of a string of major type major.
+func (d *Decoder) read(major byte) []byte {
+	n := d.Head(major)
+	if d.Err == nil && n > uint64(len(d.Data)) {
+		d.Fail("unexpected end of data")
+	}
+	if d.Err != nil {
+		return nil
+	}
+	b := d.Data[:n]
+	d.Data = d.Data[n:]
+	return b
+}
+
+// Bool reads a boolean.
+func (d *Decoder) Bool() bool {
+	if d.Err != nil {
+		return false
+	}
+	if len(d.Data) == 0 {
+		d.Fail("unexpected end of data")
+		return false
+	}
+	c := d.Data[0]
+	d.Data = d.Data[1:]
+	if c != False && c != True {
+		d.Fail("got CBOR item 0x%02x, want a boolean", c)
+	}
+	return c == True
+}
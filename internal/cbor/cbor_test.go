@@ -0,0 +1,66 @@
+package cbor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, n := range []uint64{0, 23, 24, 0xff, 0x100, 0xffff, 0x10000, 0xffffffff, 0x100000000} {
+		d := Decoder{Data: AppendHead(nil, MajorUint, n)}
+		require.Equal(t, n, d.Head(MajorUint))
+		require.NoError(t, d.Err)
+		require.Empty(t, d.Data)
+	}
+
+	d := Decoder{Data: append(AppendString(nil, "héllo"), True)}
+	require.Equal(t, "héllo", d.Text())
+	require.True(t, d.Bool())
+	require.NoError(t, d.Err)
+}
+
+func TestDecoderErrors(t *testing.T) {
+	for data, err := range map[string]string{
+		"":             "unexpected end of data",
+		"\x18\x17":     "integer 23 not in its shortest form",
+		"\x19\x00\xff": "integer 255 not in its shortest form",
+		"\x1c":         "unsupported CBOR additional information 28",
+		"\x62a":        "got CBOR major type 3, want 0",
+	} {
+		d := Decoder{Data: []byte(data)}
+		d.Head(MajorUint)
+		require.EqualError(t, d.Err, err, "%q", data)
+	}
+
+	d := Decoder{Data: []byte("\x62a")}
+	d.Text()
+	require.EqualError(t, d.Err, "unexpected end of data")
+
+	d = Decoder{Data: []byte("\x62\xff\xfe")}
+	d.Text()
+	require.EqualError(t, d.Err, "text string is not valid UTF-8")
+}
+
+func FuzzDecoder(f *testing.F) {
+	f.Add([]byte("\x62to\xf5\x19\x01\x2d"))
+	f.Add([]byte("\x1b\x00\x00\x00\x01\x00\x00\x00\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d := Decoder{Data: data}
+		for d.Err == nil && len(d.Data) > 0 {
+			switch d.Data[0] >> 5 {
+			case MajorBytes:
+				d.Bytes()
+			case MajorString:
+				d.Text()
+			case 7:
+				d.Bool()
+			default:
+				d.Head(d.Data[0] >> 5)
+			}
+		}
+		if d.Err == nil {
+			require.Empty(t, d.Data)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package unixfs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// hamtHash returns the hash HAMT sharded directories place name with, the
+// first 64 bits of its murmur3 x64 128-bit hash with seed 0, like the
+// murmur3-x64-64 multihash. Each level of shards takes the next bits of it,
+// the most significant first.
+func hamtHash(name string) uint64 {
+	const c1, c2 = 0x87c37b91114253d5, 0x4cf5ad432745937f
+	var h1, h2 uint64
+	b := []byte(name)
+	for ; len(b) >= 16; b = b[16:] {
+		k1 := binary.LittleEndian.Uint64(b)
+		k2 := binary.LittleEndian.Uint64(b[8:])
+		h1 ^= bits.RotateLeft64(k1*c1, 31) * c2
+		h1 = (bits.RotateLeft64(h1, 27)+h2)*5 + 0x52dce729
+		h2 ^= bits.RotateLeft64(k2*c2, 33) * c1
+		h2 = (bits.RotateLeft64(h2, 31)+h1)*5 + 0x38495ab5
+	}
+
+	var k1, k2 uint64
+	for i := len(b) - 1; i >= 8; i-- {
+		k2 = k2<<8 | uint64(b[i])
+	}
+	for i := min(len(b), 8) - 1; i >= 0; i-- {
+		k1 = k1<<8 | uint64(b[i])
+	}
+	if len(b) > 8 {
+		h2 ^= bits.RotateLeft64(k2*c2, 33) * c1
+	}
+	if len(b) > 0 {
+		h1 ^= bits.RotateLeft64(k1*c1, 31) * c2
+	}
+
+	h1 ^= uint64(len(name))
+	h2 ^= uint64(len(name))
+	h1 += h2
+	h2 += h1
+	h1, h2 = fmix64(h1), fmix64(h2)
+	return h1 + h2
+}
+
+// fmix64 is the finalization mix of murmur3.
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
+
+// hamtBucket returns the name prefix of the links of a shard with fanout
+// that can lead to the entry with hash, and the number of bits of hash the
+// shards above used, with the bits it uses added.
+func hamtBucket(hash uint64, used int, fanout uint64) (prefix string, next int, err error) {
+	width := bits.TrailingZeros64(fanout)
+	if used+width > 64 {
+		return "", 0, fmt.Errorf("HAMT deeper than its hash")
+	}
+	index := hash << used >> (64 - width)
+	digits := len(fmt.Sprintf("%X", fanout-1))
+	return fmt.Sprintf("%0*X", digits, index), used + width, nil
+}
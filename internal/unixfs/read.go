@@ -0,0 +1,652 @@
+package unixfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/cbor"
+)
+
+// More multicodec codes and UnixFS data types, for reading.
+const (
+	hashIdentity = 0x00
+
+	typeRaw       = 0
+	typeFile      = 2
+	typeHAMTShard = 5
+)
+
+// ErrTooLarge is returned by ReadFile for files larger than the limit.
+var ErrTooLarge = errors.New("file too large")
+
+// ParseCID returns the binary form of the CID s, a CIDv0 or a CIDv1 in
+// base32 or base58btc.
+func ParseCID(s string) ([]byte, error) {
+	var cid []byte
+	var err error
+	switch {
+	case len(s) == 46 && strings.HasPrefix(s, "Qm"):
+		cid, err = decodeBase58(s)
+	case strings.HasPrefix(s, "b"):
+		cid, err = base32Encoding.DecodeString(s[1:])
+	case strings.HasPrefix(s, "B"):
+		cid, err = base32Encoding.DecodeString(strings.ToLower(s[1:]))
+	case strings.HasPrefix(s, "z"):
+		cid, err = decodeBase58(s[1:])
+	default:
+		return nil, fmt.Errorf("invalid CID %q: unsupported encoding", s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CID %q: %w", s, err)
+	}
+	if n, err := cidLen(cid); err != nil || n != len(cid) {
+		return nil, fmt.Errorf("invalid CID %q", s)
+	}
+	return cid, nil
+}
+
+// base58Alphabet is the alphabet of base58btc.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// decodeBase58 decodes the base58btc string s.
+func decodeBase58(s string) ([]byte, error) {
+	n := new(big.Int)
+	zeros := 0
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(base58Alphabet, s[i])
+		if d < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", s[i])
+		}
+		if d == 0 && i == zeros {
+			zeros++
+		}
+		n.Mul(n, big.NewInt(58))
+		n.Add(n, big.NewInt(int64(d)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// cidLen returns the length of the binary CID b starts with.
+func cidLen(b []byte) (int, error) {
+	// CIDv0 are sha2-256 multihashes
+	if len(b) >= 2 && b[0] == hashSHA2_256 && b[1] == 32 {
+		if len(b) < 34 {
+			return 0, errors.New("truncated CID")
+		}
+		return 34, nil
+	}
+	n := 0
+	for i := 0; i < 4; i++ {
+		v, size := binary.Uvarint(b[n:])
+		if size <= 0 {
+			return 0, errors.New("truncated CID")
+		}
+		n += size
+		switch {
+		case i == 0 && v != 1:
+			return 0, fmt.Errorf("unsupported CID version %d", v)
+		case i == 3:
+			if v > uint64(len(b)-n) {
+				return 0, errors.New("truncated CID")
+			}
+			n += int(v)
+		}
+	}
+	return n, nil
+}
+
+// cidCodec returns the codec of the binary CID cid and its multihash.
+func cidCodec(cid []byte) (codec uint64, hash []byte) {
+	if cid[0] == hashSHA2_256 {
+		return codecDagPB, cid
+	}
+	_, n := binary.Uvarint(cid)
+	codec, m := binary.Uvarint(cid[n:])
+	return codec, cid[n+m:]
+}
+
+// verify checks that data is the content of the block with CID cid.
+func verify(cid, data []byte) error {
+	_, mh := cidCodec(cid)
+	code, n := binary.Uvarint(mh)
+	_, m := binary.Uvarint(mh[n:])
+	digest := mh[n+m:]
+	switch code {
+	case hashSHA2_256:
+		if sum := sha256.Sum256(data); bytes.Equal(sum[:], digest) {
+			return nil
+		}
+	case hashIdentity:
+		if bytes.Equal(data, digest) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("block %s: unsupported hash function 0x%x", CIDString(cid), code)
+	}
+	return fmt.Errorf("block %s: content doesn't match its CID", CIDString(cid))
+}
+
+// ReadFile returns the content of the file at name, relative to the UnixFS
+// directory root, from the CARv1 or CARv2 archive r. If root is nil, it's the
+// only root of the archive. It returns an error matching fs.ErrNotExist if
+// there is no file at name and ErrTooLarge if the file is larger than limit,
+// from its UnixFS size before reading its content. Directories may be HAMT
+// sharded, names can't have slashes.
+//
+// The blocks used are checked against their CIDs. If r is an io.ReaderAt,
+// like a file, it's read from its start and blocks can be in any order.
+// Otherwise, like a network stream, blocks must come after the blocks linking
+// to them, like in the depth-first order of kubo and most tools.
+func ReadFile(r io.Reader, root []byte, name string, limit int64) ([]byte, error) {
+	ra, _ := r.(io.ReaderAt)
+	cr := &carReader{r: bufio.NewReader(r)}
+	roots, err := cr.header()
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		if len(roots) != 1 {
+			return nil, fmt.Errorf("CAR archive has %d roots, want one", len(roots))
+		}
+		root = roots[0]
+	}
+
+	w := &walker{
+		name:   name,
+		hash:   hamtHash(name),
+		limit:  limit,
+		wanted: make(map[string]role),
+		blocks: make(map[string][]byte),
+		seen:   make(map[string]span),
+		used:   make(map[string]int),
+	}
+	if err := w.want(root, roleDir); err != nil {
+		return nil, err
+	}
+	for {
+		// blocks seen before being wanted are read again
+		for ra != nil && w.err == nil && len(w.late) > 0 {
+			cid := w.late[0]
+			w.late = w.late[1:]
+			s := w.seen[string(cid)]
+			data := make([]byte, s.size)
+			if _, err := ra.ReadAt(data, s.off); err != nil {
+				return nil, err
+			}
+			w.receive(cid, data)
+		}
+		if w.err != nil || w.done() {
+			break
+		}
+
+		cid, data, off, err := cr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		key := string(cid)
+		if _, ok := w.wanted[key]; ok {
+			w.receive(cid, data)
+		} else if _, ok := w.seen[key]; !ok {
+			w.seen[key] = span{off, int64(len(data))}
+		}
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	for key := range w.wanted {
+		if _, ok := w.seen[key]; ok && ra == nil {
+			return nil, fmt.Errorf("block %s comes before the block linking to it, the CAR archive must be in depth-first order", CIDString([]byte(key)))
+		}
+		return nil, fmt.Errorf("block %s is missing from the CAR archive", CIDString([]byte(key)))
+	}
+	if w.file == nil {
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	}
+	var content bytes.Buffer
+	if err := w.content(&content, w.file); err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
+
+// A span is the offset and size of a block in an archive.
+type span struct {
+	off, size int64
+}
+
+// A role is what a block is to the walk.
+type role int
+
+const (
+	roleDir   role = iota // the root, or a shard of it
+	roleFile              // the root of the file
+	roleChunk             // a block of the file
+)
+
+// walker finds the blocks of the file at name in the directory, as they are
+// read.
+type walker struct {
+	name  string
+	limit int64
+
+	// hash is the HAMT hash of name, and used the number of its bits the
+	// shards above a shard used, keyed by the CID of the shard.
+	hash uint64
+	used map[string]int
+
+	// wanted are the blocks needed, not read yet.
+	wanted map[string]role
+
+	// blocks are the blocks of the file read.
+	blocks map[string][]byte
+
+	// size is the size of the content of the file read, and overhead the
+	// size of the rest of its blocks.
+	size, overhead int64
+
+	// seen are the offsets of the blocks not wanted when read, and late
+	// the blocks wanted after that.
+	seen map[string]span
+	late [][]byte
+
+	// file is the CID of the file, once found.
+	file []byte
+
+	err error
+}
+
+// done reports whether all the blocks needed were read.
+func (w *walker) done() bool {
+	return len(w.wanted) == 0 && len(w.late) == 0
+}
+
+// want adds the block cid with role to the blocks needed.
+func (w *walker) want(cid []byte, r role) error {
+	if _, err := cidLen(cid); err != nil {
+		return err
+	}
+	key := string(cid)
+	// identity CIDs hold their content
+	if _, mh := cidCodec(cid); mh[0] == hashIdentity {
+		_, n := binary.Uvarint(mh[1:])
+		w.wanted[key] = r
+		w.receive(cid, mh[1+n:])
+		return w.err
+	}
+	if _, ok := w.wanted[key]; ok {
+		return nil
+	}
+	if _, ok := w.blocks[key]; ok {
+		return nil
+	}
+	w.wanted[key] = r
+	if _, ok := w.seen[key]; ok {
+		w.late = append(w.late, cid)
+	}
+	return nil
+}
+
+func (w *walker) fail(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// maxOverhead returns how many bytes of the blocks of a file of at most
+// limit bytes can be structure rather than content: dag-pb nodes take tens
+// of bytes per chunk they link to, files larger than a chunk have a few.
+func maxOverhead(limit int64) int64 {
+	return limit/8 + 1024
+}
+
+// receive processes the wanted block cid with content data.
+func (w *walker) receive(cid, data []byte) {
+	key := string(cid)
+	r := w.wanted[key]
+	delete(w.wanted, key)
+	if err := verify(cid, data); err != nil {
+		w.fail(err)
+		return
+	}
+
+	codec, _ := cidCodec(cid)
+	switch {
+	case codec == codecRaw && r == roleDir:
+		w.fail(fmt.Errorf("block %s: not a directory", CIDString(cid)))
+		return
+	case codec == codecRaw:
+		w.add(key, data, len(data))
+		return
+	case codec != codecDagPB:
+		w.fail(fmt.Errorf("block %s: unsupported codec 0x%x", CIDString(cid), codec))
+		return
+	}
+
+	node, err := decodeNode(data)
+	if err != nil {
+		w.fail(fmt.Errorf("block %s: %w", CIDString(cid), err))
+		return
+	}
+	switch {
+	case r == roleDir && node.typ == typeDirectory:
+		for _, l := range node.links {
+			if l.Name == w.name {
+				w.found(l.CID)
+			}
+		}
+	case r == roleDir && node.typ == typeHAMTShard:
+		// only the bucket of the name can lead to it
+		prefix, used, err := hamtBucket(w.hash, w.used[key], node.fanout)
+		if err != nil {
+			w.fail(fmt.Errorf("block %s: %w", CIDString(cid), err))
+			return
+		}
+		for _, l := range node.links {
+			switch {
+			case !strings.HasPrefix(l.Name, prefix):
+			case len(l.Name) == len(prefix):
+				w.used[string(l.CID)] = used
+				w.fail(w.want(l.CID, roleDir))
+			case l.Name[len(prefix):] == w.name:
+				w.found(l.CID)
+			}
+		}
+	case r == roleDir:
+		w.fail(fmt.Errorf("block %s: not a directory", CIDString(cid)))
+	case node.typ != typeFile && node.typ != typeRaw:
+		w.fail(fmt.Errorf("%s: not a file", w.name))
+	case r == roleFile && int64(node.fileSize) > w.limit:
+		w.fail(ErrTooLarge)
+	default:
+		if w.add(key, data, len(node.data)); w.err != nil {
+			return
+		}
+		for _, l := range node.links {
+			w.fail(w.want(l.CID, roleChunk))
+		}
+	}
+}
+
+// add keeps the block cid of the file, with content bytes of content of its
+// data, failing with ErrTooLarge once the file is larger than the limit.
+func (w *walker) add(key string, data []byte, content int) {
+	w.size += int64(content)
+	w.overhead += int64(len(data) - content)
+	if w.size > w.limit || w.overhead > maxOverhead(w.limit) {
+		w.fail(ErrTooLarge)
+		return
+	}
+	w.blocks[key] = data
+}
+
+// found records the CID of the file.
+func (w *walker) found(cid []byte) {
+	if w.file != nil {
+		return
+	}
+	w.file = cid
+	w.fail(w.want(cid, roleFile))
+}
+
+// content writes the content of the file block cid and its children to b.
+func (w *walker) content(b *bytes.Buffer, cid []byte) error {
+	data := w.blocks[string(cid)]
+	if codec, _ := cidCodec(cid); codec == codecRaw {
+		b.Write(data)
+	} else {
+		node, err := decodeNode(data)
+		if err != nil {
+			return err
+		}
+		b.Write(node.data)
+		for _, l := range node.links {
+			if err := w.content(b, l.CID); err != nil {
+				return err
+			}
+		}
+	}
+	if int64(b.Len()) > w.limit {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// node is a dag-pb node with UnixFS data.
+type node struct {
+	links    []Link
+	typ      uint64
+	data     []byte
+	fileSize uint64
+	fanout   uint64
+}
+
+// decodeNode decodes the dag-pb node b.
+func decodeNode(b []byte) (node, error) {
+	var n node
+	var unixfsData []byte
+	hasData := false
+	err := fields(b, func(num int, v uint64, field []byte) error {
+		switch num {
+		case 1:
+			unixfsData, hasData = field, true
+		case 2:
+			var l Link
+			err := fields(field, func(num int, v uint64, field []byte) error {
+				switch num {
+				case 1:
+					l.CID = field
+				case 2:
+					l.Name = string(field)
+				case 3:
+					l.Size = v
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := cidLen(l.CID); err != nil {
+				return err
+			}
+			n.links = append(n.links, l)
+		}
+		return nil
+	})
+	if err != nil {
+		return node{}, err
+	}
+	if !hasData {
+		return node{}, errors.New("dag-pb node without UnixFS data")
+	}
+
+	err = fields(unixfsData, func(num int, v uint64, field []byte) error {
+		switch num {
+		case 1:
+			n.typ = v
+		case 2:
+			n.data = field
+		case 3:
+			n.fileSize = v
+		case 6:
+			n.fanout = v
+		}
+		return nil
+	})
+	if err != nil {
+		return node{}, err
+	}
+	if n.typ == typeHAMTShard && (n.fanout < 2 || n.fanout > 1024 || n.fanout&(n.fanout-1) != 0) {
+		return node{}, fmt.Errorf("invalid HAMT fanout %d", n.fanout)
+	}
+	return n, nil
+}
+
+// fields calls fn with the number and value of the varint and
+// length-delimited fields of the protobuf message b.
+func fields(b []byte, fn func(num int, v uint64, field []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 || key>>3 == 0 || key>>3 > 1<<29 {
+			return errors.New("invalid protobuf field")
+		}
+		b = b[n:]
+		num := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errors.New("invalid protobuf varint")
+			}
+			b = b[n:]
+			if err := fn(num, v, nil); err != nil {
+				return err
+			}
+		case 2:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return errors.New("invalid protobuf length")
+			}
+			field := b[n : n+int(size)]
+			b = b[n+int(size):]
+			if err := fn(num, 0, field); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", key&7)
+		}
+	}
+	return nil
+}
+
+// maxBlockSize bounds the sections of archives, blocks are at most a few
+// MiB in practice.
+const maxBlockSize = 8 << 20
+
+// carReader reads the sections of a CAR archive.
+type carReader struct {
+	r *bufio.Reader
+
+	// off is the offset in the archive of the next byte of r, and end the
+	// offset of the end of the CARv1 data, for CARv2 archives.
+	off, end int64
+}
+
+// v2Pragma starts CARv2 archives: the length of {"version": 2} then it.
+var v2Pragma = []byte{0x0a, 0xa1, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x02}
+
+// header reads the header of the archive and returns its roots.
+func (cr *carReader) header() ([][]byte, error) {
+	if b, err := cr.r.Peek(len(v2Pragma)); err == nil && bytes.Equal(b, v2Pragma) {
+		h := make([]byte, len(v2Pragma)+40)
+		if _, err := io.ReadFull(cr.r, h); err != nil {
+			return nil, fmt.Errorf("invalid CARv2 header: %w", err)
+		}
+		// the characteristics, then the offset and size of the CARv1 data
+		offset := binary.LittleEndian.Uint64(h[len(v2Pragma)+16:])
+		size := binary.LittleEndian.Uint64(h[len(v2Pragma)+24:])
+		if offset < uint64(len(h)) || offset > 1<<62 || size > 1<<62 {
+			return nil, errors.New("invalid CARv2 header")
+		}
+		if _, err := cr.r.Discard(int(offset) - len(h)); err != nil {
+			return nil, fmt.Errorf("invalid CARv2 header: %w", err)
+		}
+		cr.off, cr.end = int64(offset), int64(offset+size)
+	}
+
+	b, err := cr.section()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CAR header: %w", err)
+	}
+	roots, version, err := decodeHeader(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CAR header: %w", err)
+	}
+	if version != 1 {
+		return nil, fmt.Errorf("unsupported CAR version %d", version)
+	}
+	return roots, nil
+}
+
+// section reads a section, its length then its content.
+func (cr *carReader) section() ([]byte, error) {
+	if cr.end > 0 && cr.off >= cr.end {
+		return nil, io.EOF
+	}
+	n, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxBlockSize {
+		return nil, fmt.Errorf("section of %d bytes too large", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(cr.r, b); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	cr.off += int64(n)
+	return b, nil
+}
+
+// ReadByte reads a byte of the archive.
+func (cr *carReader) ReadByte() (byte, error) {
+	c, err := cr.r.ReadByte()
+	if err == nil {
+		cr.off++
+	}
+	return c, err
+}
+
+// next reads the next block and returns its CID, content and the offset of
+// the content in the archive.
+func (cr *carReader) next() (cid, data []byte, off int64, err error) {
+	b, err := cr.section()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	n, err := cidLen(b)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("invalid CAR block: %w", err)
+	}
+	return b[:n], b[n:], cr.off - int64(len(b)-n), nil
+}
+
+// decodeHeader decodes the dag-cbor CAR header b, {"roots": [...],
+// "version": 1}.
+func decodeHeader(b []byte) (roots [][]byte, version uint64, err error) {
+	d := cbor.Decoder{Data: b}
+	n := d.Head(cbor.MajorMap)
+	for i := uint64(0); i < n && d.Err == nil; i++ {
+		switch key := d.Text(); key {
+		case "roots":
+			count := d.Head(cbor.MajorList)
+			for j := uint64(0); j < count && d.Err == nil; j++ {
+				if tag := d.Head(cbor.MajorTag); tag != 42 {
+					d.Fail("got tag %d, want a CID", tag)
+				}
+				cid := d.Bytes()
+				if d.Err == nil && (len(cid) == 0 || cid[0] != 0) {
+					d.Fail("invalid CID")
+				}
+				if d.Err == nil {
+					roots = append(roots, cid[1:])
+				}
+			}
+		case "version":
+			version = d.Head(cbor.MajorUint)
+		default:
+			d.Fail("unexpected key %q", key)
+		}
+	}
+	return roots, version, d.Err
+}
@@ -0,0 +1,248 @@
+package unixfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCID(t *testing.T) {
+	dir := Directory(nil)
+	cid, err := ParseCID(CIDString(dir.CID))
+	require.NoError(t, err)
+	require.Equal(t, dir.CID, cid)
+
+	// the CIDv0 of the empty directory is its multihash
+	cid, err = ParseCID("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	require.NoError(t, err)
+	require.Equal(t, dir.CID[2:], cid)
+
+	_, err = ParseCID("bafybeiczsscdsbs7ffqz55asqdf3smv6klcw3gofszvwlyarci47bgf35")
+	require.Error(t, err)
+	_, err = ParseCID("mAXASIA")
+	require.ErrorContains(t, err, "unsupported encoding")
+}
+
+// chunkedFile returns the blocks of a file split into raw chunks, its root
+// first.
+func chunkedFile(chunks ...string) []Block {
+	var data, node []byte
+	blocks := []Block{{}}
+	var size uint64
+	for _, chunk := range chunks {
+		leaf := File([]byte(chunk))
+		blocks = append(blocks, leaf)
+		var link []byte
+		link = appendBytes(link, 1, leaf.CID)
+		link = appendVarintField(link, 3, uint64(len(chunk)))
+		node = appendBytes(node, 2, link)
+		size += uint64(len(chunk))
+	}
+	data = appendVarintField(data, 1, typeFile)
+	data = appendVarintField(data, 3, size)
+	node = appendBytes(node, 1, data)
+	blocks[0] = Block{CID: newCID(codecDagPB, node), Data: node}
+	return blocks
+}
+
+// shard returns the HAMT shard block with fanout 256 and links.
+func shard(links ...Link) Block {
+	var node []byte
+	for _, l := range links {
+		var link []byte
+		link = appendBytes(link, 1, l.CID)
+		link = appendBytes(link, 2, []byte(l.Name))
+		link = appendVarintField(link, 3, l.Size)
+		node = appendBytes(node, 2, link)
+	}
+	node = appendBytes(node, 1, appendVarintField(appendVarintField(nil, 1, typeHAMTShard), 6, 256))
+	return Block{CID: newCID(codecDagPB, node), Data: node}
+}
+
+// car returns the CARv1 archive of blocks, with the first as root.
+func car(blocks ...Block) []byte {
+	var b bytes.Buffer
+	WriteCAR(&b, blocks[0].CID, blocks)
+	return b.Bytes()
+}
+
+// stream hides the io.ReaderAt of r.
+type stream struct{ io.Reader }
+
+func TestReadFile(t *testing.T) {
+	rules := File([]byte("/a /b\n"))
+	index := File([]byte("home"))
+	dir := Directory([]Link{{Name: "_redirects", CID: rules.CID, Size: 6}, {Name: "index.html", CID: index.CID, Size: 4}})
+
+	b, err := ReadFile(stream{bytes.NewReader(car(dir, index, rules))}, nil, "_redirects", 64)
+	require.NoError(t, err)
+	require.Equal(t, "/a /b\n", string(b))
+
+	b, err = ReadFile(stream{bytes.NewReader(car(dir, index, rules))}, dir.CID, "index.html", 64)
+	require.NoError(t, err)
+	require.Equal(t, "home", string(b))
+
+	_, err = ReadFile(bytes.NewReader(car(dir, index, rules)), nil, "missing", 64)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = ReadFile(bytes.NewReader(car(dir, index, rules)), nil, "_redirects", 5)
+	require.ErrorIs(t, err, ErrTooLarge)
+
+	_, err = ReadFile(bytes.NewReader(car(dir, index)), nil, "_redirects", 64)
+	require.ErrorContains(t, err, "is missing from the CAR archive")
+
+	_, err = ReadFile(bytes.NewReader(car(rules)), nil, "_redirects", 64)
+	require.ErrorContains(t, err, "not a directory")
+
+	// blocks in any order can only be read again from an io.ReaderAt
+	var rootFirst, rootLast bytes.Buffer
+	WriteCAR(&rootFirst, dir.CID, []Block{dir, rules})
+	WriteCAR(&rootLast, dir.CID, []Block{rules, dir})
+	_, err = ReadFile(stream{bytes.NewReader(rootLast.Bytes())}, nil, "_redirects", 64)
+	require.ErrorContains(t, err, "depth-first order")
+	b, err = ReadFile(bytes.NewReader(rootLast.Bytes()), nil, "_redirects", 64)
+	require.NoError(t, err)
+	require.Equal(t, "/a /b\n", string(b))
+
+	// corrupted blocks are rejected
+	corrupted := bytes.Replace(rootFirst.Bytes(), []byte("/a /b"), []byte("/a /c"), 1)
+	_, err = ReadFile(bytes.NewReader(corrupted), nil, "_redirects", 64)
+	require.ErrorContains(t, err, "content doesn't match its CID")
+}
+
+func TestReadFileChunked(t *testing.T) {
+	file := chunkedFile("/a /b\n", "/c /d\n", "/a /b\n")
+	dir := Directory([]Link{{Name: "_redirects", CID: file[0].CID, Size: 18}})
+	archive := car(append([]Block{dir}, file...)...)
+
+	b, err := ReadFile(stream{bytes.NewReader(archive)}, nil, "_redirects", 64)
+	require.NoError(t, err)
+	require.Equal(t, "/a /b\n/c /d\n/a /b\n", string(b))
+
+	// the UnixFS size is checked before reading the chunks
+	_, err = ReadFile(stream{bytes.NewReader(car(dir, file[0]))}, nil, "_redirects", 10)
+	require.ErrorIs(t, err, ErrTooLarge)
+
+	// so is the structure of the file, a file of many empty chunks is too
+	// large to read too
+	chunks := make([]string, 64)
+	file = chunkedFile(chunks...)
+	dir = Directory([]Link{{Name: "_redirects", CID: file[0].CID}})
+	_, err = ReadFile(stream{bytes.NewReader(car(append([]Block{dir}, file...)...))}, nil, "_redirects", 64)
+	require.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestReadFileSharded(t *testing.T) {
+	// _redirects hashes to 0x60be79119a8c6477, its bucket is 60 in the root
+	// shard and BE in the next, index.html to 0xa06d7ec87879381d
+	rules := File([]byte("/a /b\n"))
+	index := File([]byte("home"))
+	sub := shard(Link{Name: "BE_redirects", CID: rules.CID, Size: 6})
+	other := shard(Link{Name: "BE_redirects", CID: index.CID, Size: 4})
+	root := shard(Link{Name: "A0index.html", CID: index.CID, Size: 4}, Link{Name: "60", CID: sub.CID, Size: 60}, Link{Name: "61", CID: other.CID, Size: 60})
+
+	// shards of other buckets aren't read, the archive doesn't need them
+	b, err := ReadFile(stream{bytes.NewReader(car(root, index, sub, rules))}, nil, "_redirects", 64)
+	require.NoError(t, err)
+	require.Equal(t, "/a /b\n", string(b))
+
+	b, err = ReadFile(stream{bytes.NewReader(car(root, index))}, nil, "index.html", 64)
+	require.NoError(t, err)
+	require.Equal(t, "home", string(b))
+
+	// entries in the wrong bucket aren't found, by gateways either
+	misplaced := shard(Link{Name: "00_redirects", CID: rules.CID, Size: 6})
+	_, err = ReadFile(stream{bytes.NewReader(car(misplaced, rules))}, nil, "_redirects", 64)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestHAMTHash(t *testing.T) {
+	for name, want := range map[string]uint64{
+		"":           0,
+		"hello":      0xcbd8a7b341bd9b02,
+		"_redirects": 0x60be79119a8c6477,
+		"index.html": 0xa06d7ec87879381d,
+		"The quick brown fox jumps over the lazy dog": 0xe34bbc7bbc071b6c,
+		"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa":             0x6c7ea977c252d3f1,
+	} {
+		require.Equal(t, want, hamtHash(name), name)
+	}
+
+	prefix, used, err := hamtBucket(0x60be79119a8c6477, 8, 256)
+	require.NoError(t, err)
+	require.Equal(t, "BE", prefix)
+	require.Equal(t, 16, used)
+	prefix, _, err = hamtBucket(0x60be79119a8c6477, 0, 16)
+	require.NoError(t, err)
+	require.Equal(t, "6", prefix)
+	_, _, err = hamtBucket(0, 64, 256)
+	require.Error(t, err)
+}
+
+func TestReadFileCARv2(t *testing.T) {
+	rules := File([]byte("/a /b\n"))
+	dir := Directory([]Link{{Name: "_redirects", CID: rules.CID, Size: 6}})
+	v1 := car(dir, rules)
+
+	// the pragma, then the characteristics, the offset and size of the
+	// CARv1 data and the offset of the index
+	v2 := slices.Clone(v2Pragma)
+	v2 = append(v2, make([]byte, 16)...)
+	v2 = binary.LittleEndian.AppendUint64(v2, uint64(len(v2Pragma)+40+8))
+	v2 = binary.LittleEndian.AppendUint64(v2, uint64(len(v1)))
+	v2 = binary.LittleEndian.AppendUint64(v2, 0)
+	v2 = append(v2, make([]byte, 8)...)
+	v2 = append(v2, v1...)
+	v2 = append(v2, "an index"...)
+
+	b, err := ReadFile(stream{bytes.NewReader(v2)}, nil, "_redirects", 64)
+	require.NoError(t, err)
+	require.Equal(t, "/a /b\n", string(b))
+}
+
+func FuzzParseCID(f *testing.F) {
+	f.Add(CIDString(File([]byte("home")).CID))
+	f.Add("QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn")
+	f.Add("zb2rhe5P4gXftAwvA4eXQ5HJwsER2owDyS9sKaQRRVQPn93bA")
+	f.Fuzz(func(t *testing.T, s string) {
+		cid, err := ParseCID(s)
+		if err != nil {
+			return
+		}
+		again, err := ParseCID(CIDString(cid))
+		require.NoError(t, err)
+		require.Equal(t, cid, again)
+	})
+}
+
+func FuzzDecodeNode(f *testing.F) {
+	f.Add(Directory([]Link{{Name: "_redirects", CID: File(nil).CID, Size: 6}}).Data)
+	f.Add(shard(Link{Name: "60", CID: File(nil).CID}).Data)
+	f.Add(chunkedFile("a", "b")[0].Data)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decodeNode(data)
+	})
+}
+
+func FuzzReadFile(f *testing.F) {
+	rules := File([]byte("/a /b\n"))
+	dir := Directory([]Link{{Name: "_redirects", CID: rules.CID, Size: 6}})
+	f.Add(car(dir, rules))
+	chunked := chunkedFile("/a ", "/b\n")
+	f.Add(car(append([]Block{Directory([]Link{{Name: "_redirects", CID: chunked[0].CID}})}, chunked...)...))
+	sub := shard(Link{Name: "BE_redirects", CID: rules.CID, Size: 6})
+	f.Add(car(shard(Link{Name: "60", CID: sub.CID}), sub, rules))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, r := range []io.Reader{bytes.NewReader(data), stream{bytes.NewReader(data)}} {
+			b, err := ReadFile(r, nil, "_redirects", 64)
+			if err == nil {
+				require.LessOrEqual(t, len(b), 64)
+			}
+		}
+	})
+}
@@ -0,0 +1,135 @@
+// Package unixfs encodes the UnixFS files and directories, CIDs and CAR
+// archives the package's fixtures and tools need, and reads files from CAR
+// archives, without depending on the IPLD libraries. The module has no
+// dependencies besides testify, and go-car and boxo would bring dozens for
+// the small subset used here. It only writes files in a single raw block,
+// directories in a single dag-pb block, dag-cbor blocks encoded by their
+// callers, CIDv1 with sha2-256 and CARv1 archives, and only reads UnixFS
+// files, directories and HAMT shards in CARv1 and CARv2 archives. Every block
+// read is checked against its CID and the decoders are fuzzed, as archives
+// come from anyone.
+package unixfs
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/cbor"
+)
+
+// Multicodec codes.
+const (
+	codecDagPB   = 0x70
+	codecDagCBOR = 0x71
+	codecRaw     = 0x55
+	hashSHA2_256 = 0x12
+)
+
+// UnixFS data types.
+const (
+	typeDirectory = 1
+)
+
+// A Block is the content of a node and its CID, in binary.
+type Block struct {
+	CID  []byte
+	Data []byte
+}
+
+// A Link is an entry of a directory.
+type Link struct {
+	Name string
+	CID  []byte
+
+	// Size is the cumulative size of the DAG linked to.
+	Size uint64
+}
+
+// File returns the raw block of a file with content data.
+func File(data []byte) Block {
+	return Block{CID: newCID(codecRaw, data), Data: data}
+}
+
+// Directory returns the dag-pb block of a directory with entries links,
+// sorted by name.
+func Directory(links []Link) Block {
+	links = slices.Clone(links)
+	slices.SortFunc(links, func(a, b Link) int { return strings.Compare(a.Name, b.Name) })
+
+	var node []byte
+	for _, l := range links {
+		var link []byte
+		link = appendBytes(link, 1, l.CID)
+		link = appendBytes(link, 2, []byte(l.Name))
+		link = appendVarintField(link, 3, l.Size)
+		node = appendBytes(node, 2, link)
+	}
+	node = appendBytes(node, 1, appendVarintField(nil, 1, typeDirectory))
+	return Block{CID: newCID(codecDagPB, node), Data: node}
+}
+
+// DagCBOR returns the dag-cbor block with content data, which must be valid
+// dag-cbor.
+func DagCBOR(data []byte) Block {
+	return Block{CID: newCID(codecDagCBOR, data), Data: data}
+}
+
+// newCID returns the CIDv1 of data with codec.
+func newCID(codec uint64, data []byte) []byte {
+	sum := sha256.Sum256(data)
+	cid := binary.AppendUvarint(nil, 1)
+	cid = binary.AppendUvarint(cid, codec)
+	cid = binary.AppendUvarint(cid, hashSHA2_256)
+	cid = binary.AppendUvarint(cid, uint64(len(sum)))
+	return append(cid, sum[:]...)
+}
+
+// base32Encoding is the encoding of multibase base32 strings.
+var base32Encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// CIDString returns cid in its string form, base32 with multibase prefix b.
+func CIDString(cid []byte) string {
+	return "b" + base32Encoding.EncodeToString(cid)
+}
+
+// WriteCAR writes a CARv1 archive of blocks with root to w.
+func WriteCAR(w io.Writer, root []byte, blocks []Block) error {
+	// the dag-cbor header {"roots": [root], "version": 1}, keys sorted by
+	// length then bytes
+	var header []byte
+	header = append(header, 0xa2)
+	header = cbor.AppendString(header, "roots")
+	header = append(header, 0x81, 0xd8, 42)
+	header = cbor.AppendHead(header, cbor.MajorBytes, uint64(len(root)+1))
+	header = append(header, 0)
+	header = append(header, root...)
+	header = cbor.AppendString(header, "version")
+	header = append(header, 1)
+
+	b := binary.AppendUvarint(nil, uint64(len(header)))
+	b = append(b, header...)
+	for _, block := range blocks {
+		b = binary.AppendUvarint(b, uint64(len(block.CID)+len(block.Data)))
+		b = append(b, block.CID...)
+		b = append(b, block.Data...)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// appendVarintField appends the protobuf varint field number n.
+func appendVarintField(b []byte, n int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(n)<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendBytes appends the protobuf length-delimited field number n.
+func appendBytes(b []byte, n int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(n)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
@@ -0,0 +1,27 @@
+package unixfs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIDs(t *testing.T) {
+	// the CIDs of the empty file and directory kubo adds with CIDv1
+	require.Equal(t, "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku", CIDString(File(nil).CID))
+	require.Equal(t, "bafybeiczsscdsbs7ffqz55asqdf3smv6klcw3gofszvwlyarci47bgf354", CIDString(Directory(nil).CID))
+}
+
+func TestWriteCAR(t *testing.T) {
+	file := File([]byte("hello"))
+	dir := Directory([]Link{{Name: "hello.txt", CID: file.CID, Size: 5}})
+
+	var b bytes.Buffer
+	require.NoError(t, WriteCAR(&b, dir.CID, []Block{dir, file}))
+	car := b.Bytes()
+
+	// the header, then the blocks prefixed with their length
+	require.Equal(t, byte(len(dir.CID)+22), car[0])
+	require.True(t, bytes.HasSuffix(car, append(append([]byte{byte(len(file.CID) + 5)}, file.CID...), "hello"...)))
+}
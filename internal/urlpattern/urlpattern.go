@@ -0,0 +1,161 @@
+// Package urlpattern reads the From and To of rules: the paths a From
+// matches and the placeholders a To refers to. The redirects package and its
+// format converters share it, so rules convert with the meaning gateways
+// give them.
+package urlpattern
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ucarion/urlpath"
+)
+
+// maxCached bounds the memory used by the pattern cache on gateways serving
+// many sites; the cache is reset once it's full.
+const maxCached = 1 << 14
+
+// cache holds compiled 'from' patterns keyed by the rule's From, so matching
+// the same rule repeatedly, or copies of it, doesn't recompile it.
+var cache struct {
+	sync.RWMutex
+	m map[string]*urlpath.Path
+}
+
+// Compile returns the urlpath pattern for from. The returned value is shared
+// and must not be modified.
+func Compile(from string) *urlpath.Path {
+	cache.RLock()
+	p, ok := cache.m[from]
+	cache.RUnlock()
+	if ok {
+		return p
+	}
+
+	compiled := urlpath.New(strings.TrimSuffix(from, "/"))
+	p = &compiled
+
+	cache.Lock()
+	if cache.m == nil || len(cache.m) >= maxCached {
+		cache.m = make(map[string]*urlpath.Path)
+	}
+	cache.m[from] = p
+	cache.Unlock()
+
+	return p
+}
+
+// Static returns the only path matched by p if p has no parameters and no
+// trailing splat.
+func Static(p *urlpath.Path) (string, bool) {
+	if p.Trailing {
+		return "", false
+	}
+
+	var b []byte
+	for i, seg := range p.Segments {
+		if seg.IsParam {
+			return "", false
+		}
+		if i > 0 {
+			b = append(b, '/')
+		}
+		b = append(b, seg.Const...)
+	}
+	return string(b), true
+}
+
+// IsProtocolRelative reports whether the destination to is a URL without a
+// scheme, like "//example.com/a". Browsers take backslashes for slashes, so
+// "/\example.com/a" is one too.
+func IsProtocolRelative(to string) bool {
+	return len(to) > 1 && to[0] == '/' && (to[1] == '/' || to[1] == '\\')
+}
+
+// IsRelative reports whether to is a path on the same site, not a
+// protocol-relative URL like "//host" or "/\host", which browsers read as
+// one too.
+func IsRelative(to string) bool {
+	return len(to) > 0 && to[0] == '/' && !IsProtocolRelative(to)
+}
+
+// EscapesStart returns the offset in to from which "::" is an escaped colon:
+// past the host of absolute and protocol-relative URLs, whose IPv6 addresses
+// have colons of their own.
+func EscapesStart(to string) int {
+	_, end := Authority(to)
+	return end
+}
+
+// Authority returns the span of the authority of the absolute or
+// protocol-relative URL to, its user info, host and port, or an empty span at
+// the start for paths.
+func Authority[T string | []byte](to T) (start, end int) {
+	if len(to) > 1 && to[0] == '/' {
+		if to[1] != '/' && to[1] != '\\' {
+			return 0, 0
+		}
+		start = 2
+	} else {
+		// the scheme ends with a colon right before "//"
+		for start < len(to) && to[start] != '/' {
+			start++
+		}
+		if start == 0 || to[start-1] != ':' || start+1 >= len(to) || to[start+1] != '/' {
+			return 0, 0
+		}
+		start += 2
+	}
+
+	end = start
+	for end < len(to) && to[end] != '/' && to[end] != '?' && to[end] != '#' {
+		end++
+	}
+	return start, end
+}
+
+// SplatSlot is the slot of the splat placeholder.
+const SplatSlot = -1
+
+// A Placeholder is a placeholder a To can refer to.
+type Placeholder struct {
+	Name string
+
+	// Slot is the index of the capture filling the placeholder, or
+	// SplatSlot.
+	Slot int
+}
+
+// Placeholders returns the placeholders available to a To matched by p,
+// longest names first.
+func Placeholders(p *urlpath.Path) []Placeholder {
+	var names []Placeholder
+	slot := 0
+	for _, seg := range p.Segments {
+		if seg.IsParam {
+			// with duplicate names the last capture wins, like urlpath
+			names = slices.DeleteFunc(names, func(ph Placeholder) bool { return ph.Name == seg.Param })
+			names = append(names, Placeholder{seg.Param, slot})
+			slot++
+		}
+	}
+	if !slices.ContainsFunc(names, func(ph Placeholder) bool { return ph.Name == "splat" }) {
+		names = append(names, Placeholder{"splat", SplatSlot})
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return len(names[i].Name) > len(names[j].Name)
+	})
+	return names
+}
+
+// At returns the first of names, sorted longest first, that s starts with.
+func At(s string, names []Placeholder) (Placeholder, bool) {
+	for _, ph := range names {
+		if ph.Name != "" && strings.HasPrefix(s, ph.Name) {
+			return ph, true
+		}
+	}
+	return Placeholder{}, false
+}
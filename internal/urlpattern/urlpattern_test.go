@@ -0,0 +1,40 @@
+package urlpattern
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	p := Compile("/cached/:x/")
+	require.Same(t, p, Compile("/cached/:x/"))
+	require.Len(t, p.Segments, 3)
+}
+
+func TestIsRelative(t *testing.T) {
+	for to, want := range map[string]bool{
+		"/":                   true,
+		"/a/b":                true,
+		"//example.com":       false,
+		"/\\example.com":      false,
+		"https://example.com": false,
+		"":                    false,
+	} {
+		require.Equal(t, want, IsRelative(to), to)
+		if want {
+			require.False(t, IsProtocolRelative(to), to)
+		}
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	names := Placeholders(Compile("/:a/:ab/:a/*"))
+	require.Equal(t, []Placeholder{{"splat", SplatSlot}, {"ab", 1}, {"a", 2}}, names)
+
+	ph, ok := At("abc", names)
+	require.True(t, ok)
+	require.Equal(t, "ab", ph.Name)
+	_, ok = At("b", names)
+	require.False(t, ok)
+}
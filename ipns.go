@@ -0,0 +1,50 @@
+package redirects
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// A NameResolver resolves IPNS names and DNSLink domains, for rules
+// redirecting or proxying to ipns:// URLs. Caching resolutions is left to
+// implementations.
+type NameResolver interface {
+	// ResolveName returns the /ipfs/ path name currently points to, like
+	// /ipfs/<cid> or /ipfs/<cid>/dir.
+	ResolveName(ctx context.Context, name string) (string, error)
+}
+
+// WithNameResolver makes ResolveContext resolve the names of ipns://
+// destinations with r.
+func WithNameResolver(r NameResolver) Option {
+	return func(c *config) {
+		c.nameResolver = r
+	}
+}
+
+// resolveName returns rule with its ipns:// To, if it has one, resolved with
+// s's NameResolver, if it has one.
+func (s *RuleSet) resolveName(ctx context.Context, rule Rule) (Rule, error) {
+	if s.nameResolver == nil || len(rule.To) < len("ipns://") || !strings.EqualFold(rule.To[:len("ipns://")], "ipns://") {
+		return rule, nil
+	}
+
+	name, rest := rule.To[len("ipns://"):], ""
+	if i := strings.IndexAny(name, "/?#"); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+	resolved, err := s.nameResolver.ResolveName(ctx, name)
+	if err != nil {
+		return Rule{}, fmt.Errorf("resolving ipns://%s: %w", name, err)
+	}
+	root, found := strings.CutPrefix(resolved, "/ipfs/")
+	if !found || root == "" || root[0] == '/' {
+		return Rule{}, fmt.Errorf("resolving ipns://%s: %q isn't an /ipfs/ path", name, resolved)
+	}
+	if strings.HasPrefix(rest, "/") {
+		root = strings.TrimSuffix(root, "/")
+	}
+	rule.To = "ipfs://" + root + rest
+	return rule, nil
+}
@@ -0,0 +1,67 @@
+package redirects
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// resolverFunc is a function implementing NameResolver.
+type resolverFunc func(ctx context.Context, name string) (string, error)
+
+func (f resolverFunc) ResolveName(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+func TestResolveContext(t *testing.T) {
+	rules := Must(ParseString(`
+/docs/*   ipns://docs.example.com/:splat?v=1  302
+/app      ipns://app.example.com              200
+/dir/*    ipns://dir.example.com/:splat       302
+/bad      ipns://bad.example.com              302
+/fail     ipns://fail.example.com             302
+/local    /elsewhere                          301
+`))
+	resolver := resolverFunc(func(ctx context.Context, name string) (string, error) {
+		switch name {
+		case "docs.example.com", "app.example.com":
+			return "/ipfs/bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi", nil
+		case "dir.example.com":
+			return "/ipfs/bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi/site/", nil
+		case "bad.example.com":
+			return "/ipns/example.com", nil
+		}
+		return "", errors.New("not found")
+	})
+	set := Compile(rules, WithNameResolver(resolver))
+	ctx := context.Background()
+
+	for path, to := range map[string]string{
+		"/docs/a/b": "ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi/a/b?v=1",
+		"/app":      "ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi",
+		"/dir/x":    "ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi/site/x",
+		"/local":    "/elsewhere",
+	} {
+		rule, ok, err := set.ResolveContext(ctx, path)
+		require.NoError(t, err, path)
+		require.True(t, ok, path)
+		require.Equal(t, to, rule.To, path)
+	}
+
+	_, ok, err := set.ResolveContext(ctx, "/missing")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, _, err = set.ResolveContext(ctx, "/bad")
+	require.EqualError(t, err, `resolving ipns://bad.example.com: "/ipns/example.com" isn't an /ipfs/ path`)
+	_, _, err = set.ResolveContext(ctx, "/fail")
+	require.EqualError(t, err, "resolving ipns://fail.example.com: not found")
+
+	// without a resolver, ipns:// destinations are returned as they are
+	rule, ok, err := Compile(rules).ResolveContext(ctx, "/app")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "ipns://app.example.com", rule.To)
+}
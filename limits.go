@@ -0,0 +1,151 @@
+package redirects
+
+import "fmt"
+
+// WithMaxFileSize lowers the maximum size in bytes of a _redirects file that
+// Parse accepts. Values out of 1 to MaxFileSizeInBytes mean
+// MaxFileSizeInBytes, the default.
+func WithMaxFileSize(n int) Option {
+	return func(c *config) {
+		c.maxFileSize = n
+		if n <= 0 || n > MaxFileSizeInBytes {
+			c.maxFileSize = MaxFileSizeInBytes
+		}
+	}
+}
+
+// fileTooLargeError is returned by Parse for files larger than a maximum
+// size lower than MaxFileSizeInBytes, it matches ErrFileTooLarge.
+type fileTooLargeError struct {
+	max int
+}
+
+func (e *fileTooLargeError) Error() string {
+	return fmt.Sprintf("redirects file size cannot exceed %d bytes", e.max)
+}
+
+func (e *fileTooLargeError) Is(target error) bool {
+	return target == ErrFileTooLarge
+}
+
+// fileTooLarge returns the error for files larger than max bytes.
+func fileTooLarge(max int) error {
+	if max == MaxFileSizeInBytes {
+		return ErrFileTooLarge
+	}
+	return &fileTooLargeError{max: max}
+}
+
+// WithMaxRules makes Parse reject files with more than n rules. Zero, the
+// default, disables the limit.
+func WithMaxRules(n int) Option {
+	return func(c *config) {
+		c.maxRules = n
+	}
+}
+
+// Limits bundles the limits and proxy policies Parse and Compile enforce, so
+// operators can apply a consistent profile, such as PublicGatewayLimits, with
+// a single WithLimits option. Zero fields leave the corresponding setting as
+// it is.
+type Limits struct {
+	// MaxFileSize is the maximum size of a file, see WithMaxFileSize.
+	MaxFileSize int
+
+	// MaxLineLength is the maximum length of a line, see WithMaxLineLength.
+	MaxLineLength int
+
+	// MaxRules is the maximum number of rules, see WithMaxRules.
+	MaxRules int
+
+	// MaxPlaceholders is the maximum number of placeholders in the From
+	// and in the To of a rule, see WithMaxPlaceholders.
+	MaxPlaceholders int
+
+	// MaxComplexity is the maximum score of the rules, see
+	// WithMaxComplexity.
+	MaxComplexity int
+
+	// MaxSplatLength is the longest splat a compiled RuleSet's rules
+	// match, see WithMaxSplatLength.
+	MaxSplatLength int
+
+	// RelativeOnly rejects destinations off the site, see WithRelativeOnly.
+	RelativeOnly bool
+
+	// HTTPSOnlyProxies rejects http:// destinations, see
+	// WithHTTPSOnlyProxies.
+	HTTPSOnlyProxies bool
+
+	// NoPrivateProxies rejects destinations on non-public hosts, see
+	// WithoutPrivateProxies.
+	NoPrivateProxies bool
+
+	// ProxyHosts, if not nil, are the only hosts of URL destinations
+	// allowed, see WithProxyHosts.
+	ProxyHosts []string
+}
+
+// PublicGatewayLimits returns hardened limits for gateways serving any site
+// to anyone: files are kept small and simple, and rules can't send users over
+// cleartext or make the gateway reach its own network. Each call returns a
+// new Limits, changing it doesn't change the profile.
+func PublicGatewayLimits() Limits {
+	return Limits{
+		MaxFileSize:      MaxFileSizeInBytes,
+		MaxLineLength:    1 << 10,
+		MaxRules:         1000,
+		MaxPlaceholders:  8,
+		MaxComplexity:    10000,
+		MaxSplatLength:   4 << 10,
+		HTTPSOnlyProxies: true,
+		NoPrivateProxies: true,
+	}
+}
+
+// PrivateLimits returns the limits for gateways serving trusted sites, such
+// as an organization's own: the defaults, spelled out.
+func PrivateLimits() Limits {
+	return Limits{
+		MaxFileSize:     MaxFileSizeInBytes,
+		MaxLineLength:   DefaultMaxLineLength,
+		MaxPlaceholders: DefaultMaxPlaceholders,
+	}
+}
+
+// WithLimits applies the limits and policies of l, like the options the
+// fields of Limits refer to. Options given after it override them.
+func WithLimits(l Limits) Option {
+	return func(c *config) {
+		if l.MaxFileSize != 0 {
+			WithMaxFileSize(l.MaxFileSize)(c)
+		}
+		if l.MaxLineLength != 0 {
+			WithMaxLineLength(l.MaxLineLength)(c)
+		}
+		if l.MaxRules != 0 {
+			WithMaxRules(l.MaxRules)(c)
+		}
+		if l.MaxPlaceholders != 0 {
+			WithMaxPlaceholders(l.MaxPlaceholders)(c)
+		}
+		if l.MaxComplexity != 0 {
+			WithMaxComplexity(l.MaxComplexity)(c)
+		}
+		if l.MaxSplatLength != 0 {
+			WithMaxSplatLength(l.MaxSplatLength)(c)
+		}
+		if l.RelativeOnly {
+			WithRelativeOnly()(c)
+		}
+		if l.HTTPSOnlyProxies {
+			WithHTTPSOnlyProxies()(c)
+		}
+		if l.NoPrivateProxies {
+			WithoutPrivateProxies()(c)
+		}
+		if l.ProxyHosts != nil {
+			WithProxyHosts(l.ProxyHosts...)(c)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package redirects
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMaxFileSize(t *testing.T) {
+	file := "/a /b\n/c /d\n"
+	_, err := ParseString(file, WithMaxFileSize(len(file)))
+	require.NoError(t, err)
+
+	_, err = ParseString(file, WithMaxFileSize(len(file)-1))
+	require.EqualError(t, err, "redirects file size cannot exceed 11 bytes")
+	require.True(t, errors.Is(err, ErrFileTooLarge))
+	require.Equal(t, CodeFileTooLarge, ErrorDiagnostic(err).Code)
+
+	// the limit can't be raised
+	_, err = ParseString(strings.Repeat("# padding\n", MaxFileSizeInBytes/10+1), WithMaxFileSize(2*MaxFileSizeInBytes))
+	require.Equal(t, ErrFileTooLarge, err)
+}
+
+func TestParseMaxRules(t *testing.T) {
+	file := "/a /b\n\n/c /d\n/e /f\n"
+	_, err := ParseString(file, WithMaxRules(3))
+	require.NoError(t, err)
+
+	_, err = ParseString(file, WithMaxRules(2))
+	require.EqualError(t, err, "line 4: file has more than 2 rules")
+	require.Equal(t, CodeTooManyRules, ErrorDiagnostic(err).Code)
+}
+
+func TestParseWithLimits(t *testing.T) {
+	for _, file := range []string{
+		"/a http://example.com/",
+		"/a https://127.0.0.1/",
+		"/a/:b/:c/:d/:e/:f/:g/:h/:i/:j /x",
+		"/a /" + strings.Repeat("b", 1<<10),
+		strings.Repeat("/a /b\n", 1001),
+	} {
+		_, err := ParseString(file)
+		require.NoError(t, err)
+		_, err = ParseString(file, WithLimits(PrivateLimits()))
+		require.NoError(t, err)
+
+		_, err = ParseString(file, WithLimits(PublicGatewayLimits()))
+		require.Error(t, err, file)
+	}
+
+	_, err := ParseString("/a https://example.com/\n/b /c", WithLimits(PublicGatewayLimits()))
+	require.NoError(t, err)
+
+	// later options override the profile
+	_, err = ParseString(strings.Repeat("/a /b\n", 1001), WithLimits(PublicGatewayLimits()), WithMaxRules(0))
+	require.NoError(t, err)
+
+	limits := PublicGatewayLimits()
+	limits.ProxyHosts = []string{"example.com"}
+	_, err = ParseString("/a https://example.org/", WithLimits(limits))
+	require.EqualError(t, err, `line 1: parsing 'to': host "example.org" is not allowed`)
+
+	// changing a profile doesn't change it for others
+	require.Nil(t, PublicGatewayLimits().ProxyHosts)
+}
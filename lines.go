@@ -0,0 +1,167 @@
+package redirects
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"unicode/utf8"
+)
+
+// LineTooLongError is returned when a line of a _redirects file exceeds the
+// maximum line length.
+type LineTooLongError struct {
+	// Line is the 1-based number of the offending line.
+	Line int
+
+	// Max is the maximum line length in bytes.
+	Max int
+}
+
+func (e *LineTooLongError) Error() string {
+	return fmt.Sprintf("line %d exceeds maximum line length of %d bytes", e.Line, e.Max)
+}
+
+// lineReader splits its input into lines terminated by LF or CRLF. The last
+// line doesn't need a terminator.
+type lineReader struct {
+	r   *bufio.Reader
+	max int
+
+	// n is the number of the last line returned by next.
+	n int
+
+	// offset is the number of bytes consumed by the lines returned so far,
+	// including their terminators.
+	offset int
+
+	// buf accumulates lines longer than the bufio.Reader's buffer.
+	buf []byte
+
+	// sum, if set, is written the bytes read from then on, to check the
+	// integrity pragma.
+	sum hash.Hash
+}
+
+func newLineReader(r io.Reader, max int) *lineReader {
+	return &lineReader{r: bufio.NewReader(r), max: max}
+}
+
+// next returns the next line without its terminator, or io.EOF when there are
+// no more lines. The line is only valid until the next call.
+func (l *lineReader) next() ([]byte, error) {
+	l.buf = l.buf[:0]
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		if l.sum != nil {
+			l.sum.Write(chunk)
+		}
+		if err == bufio.ErrBufferFull {
+			// the chunk has no terminator, it's all part of the line
+			if len(l.buf)+len(chunk) > l.max {
+				return nil, l.tooLong(len(l.buf) + len(chunk))
+			}
+			l.buf = append(l.buf, chunk...)
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line := chunk
+		if len(l.buf) > 0 {
+			l.buf = append(l.buf, chunk...)
+			line = l.buf
+		}
+		if len(line) == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
+		l.n++
+		l.offset += len(line)
+
+		if l.n == 1 {
+			if isUTF16(line) {
+				return nil, &ParseError{Line: 1, Code: CodeUnsupportedEncoding, Err: errors.New("file must be encoded in UTF-8, not UTF-16")}
+			}
+			// a UTF-8 byte order mark isn't part of the first line
+			line = bytes.TrimPrefix(line, utf8BOM)
+		}
+
+		line = bytes.TrimSuffix(line, []byte{'\n'})
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if len(line) > l.max {
+			return nil, &LineTooLongError{Line: l.n, Max: l.max}
+		}
+		return line, nil
+	}
+}
+
+// verify reads the rest of the input into sum and reports whether the digest
+// of all that was read since sum was set is digest. It can't tell when
+// reading fails and reports true, leaving the error to next.
+func (l *lineReader) verify(digest []byte) bool {
+	if _, err := io.Copy(l.sum, l.r); err != nil {
+		return true
+	}
+	return bytes.Equal(l.sum.Sum(nil), digest)
+}
+
+// tooLong skips the rest of a line of which read bytes were already consumed
+// and returns a LineTooLongError for it. The whole line is accounted for in
+// offset, so callers can tell whether it extends beyond a size limit.
+func (l *lineReader) tooLong(read int) error {
+	l.n++
+	l.offset += read
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		l.offset += len(chunk)
+		if l.sum != nil {
+			l.sum.Write(chunk)
+		}
+		if err != bufio.ErrBufferFull {
+			if err != nil && err != io.EOF {
+				return err
+			}
+			return &LineTooLongError{Line: l.n, Max: l.max}
+		}
+	}
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// isUTF16 reports whether the first line of a file looks encoded in UTF-16:
+// it starts with a UTF-16 byte order mark, or with an ASCII character, such
+// as the leading slash, taking up two bytes.
+func isUTF16(line []byte) bool {
+	if len(line) < 2 {
+		return false
+	}
+	if line[0] == 0xFF && line[1] == 0xFE || line[0] == 0xFE && line[1] == 0xFF {
+		return true
+	}
+	return (line[0] == 0) != (line[1] == 0)
+}
+
+// checkUTF8 returns a ParseError for the first byte of the n-th line that
+// isn't valid UTF-8, if any.
+func checkUTF8(line []byte, n int) error {
+	if utf8.Valid(line) {
+		return nil
+	}
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		if r == utf8.RuneError && size == 1 {
+			return &ParseError{
+				Line:      n,
+				Column:    i + 1,
+				EndColumn: i + 2,
+				Code:      CodeInvalidUTF8,
+				Err:       fmt.Errorf("invalid UTF-8 byte %#x", line[i]),
+			}
+		}
+		i += size
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package redirects
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readLines(t *testing.T, s string, max int) ([]string, error) {
+	t.Helper()
+	l := newLineReader(strings.NewReader(s), max)
+	var lines []string
+	for {
+		line, err := l.next()
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, string(line))
+	}
+}
+
+func TestLineReader(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty", "", nil},
+		{"single newline", "\n", []string{""}},
+		{"no trailing newline", "/a /b\n/c /d", []string{"/a /b", "/c /d"}},
+		{"trailing newline", "/a /b\n/c /d\n", []string{"/a /b", "/c /d"}},
+		{"crlf", "/a /b\r\n/c /d\r\n", []string{"/a /b", "/c /d"}},
+		{"crlf without trailing newline", "/a /b\r\n/c /d\r", []string{"/a /b", "/c /d"}},
+		{"blank lines", "\n\r\n\n", []string{"", "", ""}},
+		{"longer than buffer", strings.Repeat("x", 10000) + "\ny", []string{strings.Repeat("x", 10000), "y"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lines, err := readLines(t, tc.input, MaxFileSizeInBytes)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, lines)
+		})
+	}
+
+	t.Run("line too long", func(t *testing.T) {
+		for _, input := range []string{
+			"ok\n12345\n",
+			"ok\n12345",
+			"ok\r\n12345\r\n",
+			"ok\n" + strings.Repeat("x", 10000),
+		} {
+			_, err := readLines(t, input, 4)
+
+			var tooLong *LineTooLongError
+			require.True(t, errors.As(err, &tooLong), "%q: %v", input, err)
+			require.Equal(t, 2, tooLong.Line)
+			require.Equal(t, 4, tooLong.Max)
+		}
+	})
+
+	t.Run("terminator doesn't count", func(t *testing.T) {
+		lines, err := readLines(t, "1234\r\n1234\n1234", 4)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1234", "1234", "1234"}, lines)
+	})
+}
+
+func TestParseEncoding(t *testing.T) {
+	rules, err := ParseString("\ufeff/a /b\n/c /d")
+	require.NoError(t, err)
+	require.Equal(t, "/a", rules[0].From)
+
+	for _, src := range []string{
+		"\xFF\xFE/\x00a\x00 \x00/\x00b\x00",
+		"\xFE\xFF\x00/\x00a\x00 \x00/\x00b",
+		"/\x00a\x00 \x00/\x00b\x00",
+	} {
+		_, err := ParseString(src)
+		require.EqualError(t, err, "line 1: file must be encoded in UTF-8, not UTF-16")
+		require.Equal(t, CodeUnsupportedEncoding, ErrorDiagnostic(err).Code)
+	}
+
+	_, err = ParseString("/a /b\n# caf\xe9\n")
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	require.Equal(t, &ParseError{Line: 2, Column: 6, EndColumn: 7, Code: CodeInvalidUTF8, Err: parseErr.Err}, parseErr)
+	require.EqualError(t, err, "line 2: invalid UTF-8 byte 0xe9")
+
+	_, err = ParseString("/ą /ę")
+	require.NoError(t, err)
+}
@@ -0,0 +1,547 @@
+package redirects
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+	"github.com/ucarion/urlpath"
+)
+
+// Lint checks rules for likely mistakes that still parse, such as rules that
+// can never match or redirect loops, and for rules or statuses that can be
+// left out to shrink the file. It returns the problems found, ordered by rule.
+// With WithStrict, problems that likely break a site are reported as errors
+// instead of warnings. Explicit default statuses are only reported with
+// WithSource, the rules alone don't tell.
+func Lint(rules Rules, opts ...Option) []Diagnostic {
+	c := newConfig(opts)
+	diags := lint(rules, c)
+	locate(diags, rules, c.source)
+	redactDiagnostics(diags, c)
+	if c.strict {
+		for i := range diags {
+			if diags[i].strict {
+				diags[i].Severity = SeverityError
+			}
+		}
+	}
+	return diags
+}
+
+func lint(rules Rules, c *config) []Diagnostic {
+	var diags []Diagnostic
+	patterns := make([]*urlpath.Path, len(rules))
+	for i, rule := range rules {
+		patterns[i] = urlpattern.Compile(rule.From)
+	}
+
+	// first maps each From, as matched, to the first rule with it
+	first := make(map[string]int, len(rules))
+
+	var lines [][]byte
+	if c.source != nil {
+		lines = bytes.Split(c.source, []byte{'\n'})
+	}
+
+	for j, rule := range rules {
+		diags = append(diags, lintRedundant(rules, patterns, lines, j)...)
+		diags = append(diags, lintUndefinedPlaceholders(rules, patterns, j)...)
+		diags = append(diags, lintUnusedPlaceholders(rules, patterns, j)...)
+		diags = append(diags, lintReservedPlaceholders(rules, patterns, j)...)
+		diags = append(diags, lintDuplicatePlaceholders(rules, patterns, j)...)
+
+		from := strings.TrimSuffix(rule.From, "/")
+		if i, ok := first[from]; ok {
+			// same From is also unreachable, but this is more to the point
+			diags = append(diags, lintDuplicate(rules, i, j))
+			continue
+		}
+		first[from] = j
+
+		diags = append(diags, lintUnreachable(rules, patterns, j)...)
+	}
+
+	diags = append(diags, lintLoops(rules, patterns)...)
+	diags = append(diags, lintAfterCatchAll(rules, patterns)...)
+	diags = suppress(diags, c)
+	slices.SortStableFunc(diags, func(a, b Diagnostic) int {
+		return cmp.Compare(a.Rule, b.Rule)
+	})
+	return diags
+}
+
+// lintRedundant reports what the j-th rule spells out that the gateway does
+// anyway: the default 301 status, found in lines, and rewriting a path to
+// itself or a directory to its index.html.
+func lintRedundant(rules Rules, patterns []*urlpath.Path, lines [][]byte, j int) []Diagnostic {
+	var diags []Diagnostic
+	rule := rules[j]
+
+	if n := rule.Line; rule.Status == 301 && n > 0 && n <= len(lines) {
+		var fields [3][]byte
+		line := bytes.TrimSuffix(lines[n-1], []byte{'\r'})
+		if n, _ := splitFields(line, &fields); n == 3 && string(fields[0]) == rule.From && string(fields[2]) == "301" {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityInfo,
+				Code:     CodeRedundantStatus,
+				Rule:     j,
+				Line:     rule.Line,
+				Related:  -1,
+				Message:  "status 301 is the default and can be left out",
+				field:    fieldStatus,
+			})
+		}
+	}
+
+	if rule.Status == 200 && isIdentityRewrite(rule, patterns[j]) {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityInfo,
+			Code:     CodeRedundantRule,
+			Rule:     j,
+			Line:     rule.Line,
+			Related:  -1,
+			Message:  "rule rewrites paths to what the gateway serves without it and can be removed",
+			field:    fieldTo,
+		})
+	}
+	return diags
+}
+
+// isIdentityRewrite reports whether rewriting with r serves what the
+// gateway serves anyway: the matched path itself, or the index.html of the
+// matched directory.
+func isIdentityRewrite(r Rule, p *urlpath.Path) bool {
+	// expand To with the values samplePath uses, so both spell the same
+	// path the same way
+	var captures []string
+	for _, seg := range p.Segments {
+		if seg.IsParam {
+			captures = append(captures, ":"+seg.Param)
+		}
+	}
+	trailing := ""
+	if p.Trailing {
+		trailing = "*"
+	}
+	to := compileTemplate(r.To, p).expand(captures, trailing)
+
+	path := samplePath(p)
+	if path == "" {
+		path = "/"
+	}
+	if to == path {
+		return true
+	}
+	return strings.HasSuffix(r.From, "/") && to == strings.TrimSuffix(path, "/")+"/index.html"
+}
+
+// lintUndefinedPlaceholders reports placeholders in the j-th rule's To that
+// its From doesn't define. They are left as is when expanding To, which is
+// rarely what was meant.
+func lintUndefinedPlaceholders(rules Rules, patterns []*urlpath.Path, j int) []Diagnostic {
+	var diags []Diagnostic
+	for _, name := range undefinedPlaceholders(rules[j].To, patterns[j]) {
+		diags = append(diags, Diagnostic{
+			Code:    CodeUndefinedPlaceholder,
+			Rule:    j,
+			Line:    rules[j].Line,
+			Related: -1,
+			Message: fmt.Sprintf("placeholder %q is not defined in 'from', write %q for a literal colon", ":"+name, "::"+name),
+			strict:  true,
+			field:   fieldTo,
+			token:   ":" + name,
+		})
+	}
+	return diags
+}
+
+// undefinedPlaceholders returns the names of what looks like placeholders in
+// to, a colon followed by a letter, that aren't defined by p.
+func undefinedPlaceholders(to string, p *urlpath.Path) []string {
+	names := urlpattern.Placeholders(p)
+
+	escapes := urlpattern.EscapesStart(to)
+
+	var undefined []string
+	for i := 0; i < len(to); i++ {
+		if to[i] != ':' {
+			continue
+		}
+		if i >= escapes && i+1 < len(to) && to[i+1] == ':' {
+			i++
+			continue
+		}
+		if ph, ok := urlpattern.At(to[i+1:], names); ok {
+			i += len(ph.Name)
+			continue
+		}
+
+		name := placeholderToken(to[i+1:])
+		if name != "" && !slices.Contains(undefined, name) {
+			undefined = append(undefined, name)
+		}
+		i += len(name)
+	}
+	return undefined
+}
+
+// placeholderToken returns the placeholder name s starts with, a letter
+// followed by letters, digits and underscores, or "".
+func placeholderToken(s string) string {
+	n := 0
+	for n < len(s) {
+		c := s[n]
+		letter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		if !letter && (n == 0 || !(c >= '0' && c <= '9' || c == '_')) {
+			break
+		}
+		n++
+	}
+	return s[:n]
+}
+
+// lintUnusedPlaceholders reports named placeholders captured by the j-th
+// rule's From that its To never uses, which usually is a typo.
+func lintUnusedPlaceholders(rules Rules, patterns []*urlpath.Path, j int) []Diagnostic {
+	tmpl := compileTemplate(rules[j].To, patterns[j])
+
+	var diags []Diagnostic
+	slot := 0
+	for _, seg := range patterns[j].Segments {
+		if !seg.IsParam {
+			continue
+		}
+		if !tmpl.uses(slot) && !isDuplicateParam(patterns[j], seg.Param, slot) {
+			diags = append(diags, Diagnostic{
+				Code:    CodeUnusedPlaceholder,
+				Rule:    j,
+				Line:    rules[j].Line,
+				Related: -1,
+				Message: fmt.Sprintf("placeholder %q is not used in 'to'", ":"+seg.Param),
+				field:   fieldFrom,
+				token:   ":" + seg.Param,
+			})
+		}
+		slot++
+	}
+	return diags
+}
+
+// isDuplicateParam reports whether the parameter at slot isn't the last one
+// with its name, those are never used.
+func isDuplicateParam(p *urlpath.Path, name string, slot int) bool {
+	n := 0
+	for _, seg := range p.Segments {
+		if seg.IsParam {
+			if n > slot && seg.Param == name {
+				return true
+			}
+			n++
+		}
+	}
+	return false
+}
+
+// reservedPlaceholders are placeholder names with a built-in meaning, or
+// set aside for one.
+var reservedPlaceholders = []string{"splat", "path", "cid"}
+
+// lintReservedPlaceholders reports placeholders in the j-th rule's From
+// named like a built-in. A ":splat" parameter takes precedence over the splat
+// in To, which is confusing at best.
+func lintReservedPlaceholders(rules Rules, patterns []*urlpath.Path, j int) []Diagnostic {
+	var diags []Diagnostic
+	for _, seg := range patterns[j].Segments {
+		if seg.IsParam && slices.Contains(reservedPlaceholders, seg.Param) {
+			diags = append(diags, Diagnostic{
+				Code:    CodeReservedPlaceholder,
+				Rule:    j,
+				Line:    rules[j].Line,
+				Related: -1,
+				Message: fmt.Sprintf("placeholder %q in 'from' uses a reserved name", ":"+seg.Param),
+				strict:  true,
+				field:   fieldFrom,
+				token:   ":" + seg.Param,
+			})
+		}
+	}
+	return diags
+}
+
+// lintDuplicatePlaceholders reports placeholder names used more than once in
+// the j-th rule's From. Only the last capture makes it into To.
+func lintDuplicatePlaceholders(rules Rules, patterns []*urlpath.Path, j int) []Diagnostic {
+	var diags []Diagnostic
+	var seen []string
+	for _, seg := range patterns[j].Segments {
+		if !seg.IsParam {
+			continue
+		}
+		if slices.Contains(seen, seg.Param) {
+			diags = append(diags, Diagnostic{
+				Code:    CodeDuplicatePlaceholder,
+				Rule:    j,
+				Line:    rules[j].Line,
+				Related: -1,
+				Message: fmt.Sprintf("placeholder %q is defined more than once in 'from', the last value captured wins", ":"+seg.Param),
+				strict:  true,
+				field:   fieldFrom,
+				token:   ":" + seg.Param,
+			})
+			continue
+		}
+		seen = append(seen, seg.Param)
+	}
+	return diags
+}
+
+// lintDuplicate reports the j-th rule, which has the same From as the
+// earlier i-th rule, as a duplicate or a conflict.
+func lintDuplicate(rules Rules, i, j int) Diagnostic {
+	d := Diagnostic{Rule: j, Line: rules[j].Line, Related: i, field: fieldFrom}
+	if rules[i].To == rules[j].To && rules[i].Status == rules[j].Status {
+		// harmless, the rule just takes space
+		d.Severity = SeverityInfo
+		d.Code = CodeDuplicateRule
+		d.Message = fmt.Sprintf("duplicate of %s", describeRule(rules[i], i))
+	} else {
+		d.Code = CodeConflictingRule
+		d.Message = fmt.Sprintf("conflicts with %s, which already maps %q to %q with status %d",
+			describeRule(rules[i], i), rules[i].From, rules[i].To, rules[i].Status)
+	}
+	return d
+}
+
+// lintUnreachable reports the j-th rule if an earlier rule matches every path
+// it matches.
+func lintUnreachable(rules Rules, patterns []*urlpath.Path, j int) []Diagnostic {
+	for i := 0; i < j; i++ {
+		if covers(patterns[i], patterns[j]) {
+			return []Diagnostic{{
+				Code:    CodeUnreachableRule,
+				Rule:    j,
+				Line:    rules[j].Line,
+				Related: i,
+				Message: fmt.Sprintf("rule can never match, %s %q always matches first", describeRule(rules[i], i), rules[i].From),
+				field:   fieldFrom,
+			}}
+		}
+	}
+	return nil
+}
+
+// lintAfterCatchAll reports the first catch-all rule, like "/* /index.html
+// 200", if rules it leaves unreachable come after it. Every rule after it is
+// also reported as unreachable, but that mistake is common enough to deserve
+// a summary pointing at its cause.
+func lintAfterCatchAll(rules Rules, patterns []*urlpath.Path) []Diagnostic {
+	for i, p := range patterns {
+		if !isCatchAll(p) {
+			continue
+		}
+
+		var dead []int
+		for j := i + 1; j < len(rules); j++ {
+			if covers(p, patterns[j]) {
+				dead = append(dead, j)
+			}
+		}
+		if len(dead) == 0 {
+			return nil
+		}
+
+		msg := "catch-all rule makes the rule after it unreachable, move it before the catch-all"
+		if len(dead) > 1 {
+			msg = fmt.Sprintf("catch-all rule makes %d rules after it unreachable, move them before the catch-all", len(dead))
+		}
+		return []Diagnostic{{
+			Code:    CodeRulesAfterCatchAll,
+			Rule:    i,
+			Line:    rules[i].Line,
+			Related: dead[0],
+			Message: msg,
+			field:   fieldFrom,
+		}}
+	}
+	return nil
+}
+
+// isCatchAll reports whether p matches every path.
+func isCatchAll(p *urlpath.Path) bool {
+	return p.Trailing && len(p.Segments) == 1 && !p.Segments[0].IsParam && p.Segments[0].Const == ""
+}
+
+// covers reports whether a matches every path b matches.
+//
+// A pattern of k segments without trailing segments matches paths of exactly
+// k slash separated parts, with trailing segments it matches paths of more
+// than k parts. A parameter matches any part, a constant only itself.
+func covers(a, b *urlpath.Path) bool {
+	ka, kb := len(a.Segments), len(b.Segments)
+	switch {
+	case !a.Trailing && (b.Trailing || ka != kb):
+		return false
+	case a.Trailing && !b.Trailing && kb <= ka:
+		return false
+	case a.Trailing && b.Trailing && kb < ka:
+		return false
+	}
+
+	for n, seg := range a.Segments {
+		if seg.IsParam {
+			continue
+		}
+		if b.Segments[n].IsParam || b.Segments[n].Const != seg.Const {
+			return false
+		}
+	}
+	return true
+}
+
+// describeRule refers to the i-th rule for diagnostics.
+func describeRule(r Rule, i int) string {
+	if r.Line > 0 {
+		return fmt.Sprintf("the rule on line %d", r.Line)
+	}
+	return fmt.Sprintf("rule %d", i+1)
+}
+
+// lintLoops reports internal redirects that lead back to a path already
+// visited, provided the content along the way doesn't exist. Rules with
+// placeholders are followed from a sample path using the placeholder names
+// as values. Each loop is reported once, on its earliest rule, whichever rule
+// the walk finding it started from.
+func lintLoops(rules Rules, patterns []*urlpath.Path) []Diagnostic {
+	var diags []Diagnostic
+	set := Compile(rules)
+	// explored are the paths walks went through, their loops are found by
+	// then, so later walks stop there and the work is shared
+	explored := make(map[string]bool)
+	// reported are the loops reported, by the set of their rules, as paths
+	// with other values can go through the same rules
+	reported := make(map[string]bool)
+
+	for j, rule := range rules {
+		if !isInternalRedirect(rule) {
+			continue
+		}
+
+		start := samplePath(patterns[j])
+		i, _, ok := set.match(start)
+		if !ok || i != j {
+			// shadowed rules are reported as such
+			continue
+		}
+
+		cycle, ok := followRedirects(set, start, explored)
+		if !ok {
+			continue
+		}
+		key := cycleKey(cycle)
+		if reported[key] {
+			continue
+		}
+		reported[key] = true
+
+		// start the loop at its earliest rule
+		k := slices.Index(cycle, slices.Min(cycle))
+		cycle = append(cycle[k:], cycle[:k]...)
+		first := rules[cycle[0]]
+
+		path := make([]string, 0, len(cycle)+1)
+		for _, i := range cycle {
+			path = append(path, rules[i].From)
+		}
+		msg := "redirects to itself"
+		if len(cycle) > 1 {
+			msg = "redirect loop " + strings.Join(append(path, first.From), " -> ")
+		}
+		diags = append(diags, Diagnostic{
+			Code:    CodeRedirectLoop,
+			Rule:    cycle[0],
+			Line:    first.Line,
+			Related: -1,
+			Message: msg,
+			strict:  true,
+			field:   fieldTo,
+		})
+	}
+	return diags
+}
+
+// followRedirects follows internal redirects from start and returns the
+// indexes of the rules making up the loop the walk ends in, if any, which
+// doesn't have to lead back to start itself. Walks stop at the paths of
+// explored, whose loop is already known, and add theirs to it.
+func followRedirects(set *RuleSet, start string, explored map[string]bool) ([]int, bool) {
+	var visited []int
+	seen := map[string]int{}
+	defer func() {
+		for path := range seen {
+			explored[path] = true
+		}
+	}()
+
+	path := start
+	for {
+		if n, ok := seen[path]; ok {
+			return visited[n:], true
+		}
+		if explored[path] {
+			return nil, false
+		}
+		seen[path] = len(visited)
+
+		i, rule, ok := set.match(path)
+		if !ok || !isInternalRedirect(rule) {
+			return nil, false
+		}
+		visited = append(visited, i)
+		path = destinationPath(rule.To)
+	}
+}
+
+// cycleKey identifies a loop by the set of its rules, whatever rule it's
+// walked from.
+func cycleKey(cycle []int) string {
+	sorted := slices.Clone(cycle)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	return fmt.Sprint(sorted)
+}
+
+// isInternalRedirect reports whether the rule redirects to a path of the
+// same site.
+func isInternalRedirect(r Rule) bool {
+	return r.Status >= 300 && r.Status < 400 && urlpattern.IsRelative(r.To)
+}
+
+// destinationPath returns the path of a relative destination, without query
+// and fragment.
+func destinationPath(to string) string {
+	if i := strings.IndexAny(to, "?#"); i >= 0 {
+		return to[:i]
+	}
+	return to
+}
+
+// samplePath returns a path matched by p, using ":name" as the value of each
+// parameter and "*" as the splat.
+func samplePath(p *urlpath.Path) string {
+	parts := make([]string, 0, len(p.Segments)+1)
+	for _, seg := range p.Segments {
+		if seg.IsParam {
+			parts = append(parts, ":"+seg.Param)
+		} else {
+			parts = append(parts, seg.Const)
+		}
+	}
+	if p.Trailing {
+		parts = append(parts, "*")
+	}
+	return strings.Join(parts, "/")
+}
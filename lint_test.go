@@ -0,0 +1,195 @@
+package redirects
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCovers(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{"/a", "/a", true},
+		{"/a", "/a/", true},
+		{"/a", "/b", false},
+		{"/:x", "/a", true},
+		{"/a", "/:x", false},
+		{"/:x", "/a/b", false},
+		{"/*", "/a", true},
+		{"/*", "/a/b/c", true},
+		{"/*", "/a/*", true},
+		{"/*", "/", false},
+		{"/a/*", "/a", false},
+		{"/a/*", "/a/b", true},
+		{"/a/*", "/a/*", true},
+		{"/a/*", "/a/:x/*", true},
+		{"/a/:x/*", "/a/*", false},
+		{"/a/:x", "/a/*", false},
+		{"/:x/:y", "/a/:z", true},
+		{"/a*", "/ab", false},
+	} {
+		a, b := urlpattern.Compile(tc.a), urlpattern.Compile(tc.b)
+		require.Equal(t, tc.want, covers(a, b), "%s covers %s", tc.a, tc.b)
+
+		// check against urlpath on a few paths
+		if tc.want {
+			for _, path := range []string{"/a", "/b", "/a/b", "/a/b/c", "/ab", "/", ""} {
+				if _, ok := b.Match(path); ok {
+					_, ok := a.Match(path)
+					require.True(t, ok, "%s covers %s but doesn't match %s", tc.a, tc.b, path)
+				}
+			}
+		}
+	}
+}
+
+func TestLintUnreachable(t *testing.T) {
+	rules := Must(ParseString(`
+	/a          /b
+	/posts/:id  /articles/:id
+	/posts/new  /new-post
+	/a/         /c    302
+	/*          /index.html  200
+	/later      /never
+	`))
+
+	diags := Lint(rules)
+	require.Equal(t, []Diagnostic{
+		{Code: CodeUnreachableRule, Rule: 2, Line: 4, Related: 1, Message: `rule can never match, the rule on line 3 "/posts/:id" always matches first`},
+		{Code: CodeConflictingRule, Rule: 3, Line: 5, Related: 0, Message: `conflicts with the rule on line 2, which already maps "/a" to "/b" with status 301`},
+		{Code: CodeRulesAfterCatchAll, Rule: 4, Line: 6, Related: 5, Message: "catch-all rule makes the rule after it unreachable, move it before the catch-all"},
+		{Code: CodeUnreachableRule, Rule: 5, Line: 7, Related: 4, Message: `rule can never match, the rule on line 6 "/*" always matches first`},
+	}, diags)
+	require.Equal(t, `line 4: rule can never match, the rule on line 3 "/posts/:id" always matches first`, diags[0].String())
+
+	t.Run("without lines", func(t *testing.T) {
+		diags := Lint(Rules{{From: "/*", To: "/", Status: 200}, {From: "/a", To: "/b", Status: 301}})
+		require.Len(t, diags, 2)
+		require.Equal(t, `rule 2: rule can never match, rule 1 "/*" always matches first`, diags[1].String())
+	})
+
+	t.Run("clean", func(t *testing.T) {
+		require.Empty(t, Lint(Must(ParseString("/a /b\n/a/* /c\n/* /404.html 404"))))
+	})
+}
+
+func TestLintAfterCatchAll(t *testing.T) {
+	rules := Must(ParseString(`
+	/a          /b
+	/*          /index.html  200
+	/           /home
+	/c          /d
+	/posts/:id  /articles/:id
+	/*          /404.html    404
+	`))
+
+	diags := Lint(rules, WithSuppressed(CodeUnreachableRule, CodeDuplicateRule, CodeConflictingRule))
+	require.Equal(t, []Diagnostic{
+		{Code: CodeRulesAfterCatchAll, Rule: 1, Line: 3, Related: 3, Message: "catch-all rule makes 3 rules after it unreachable, move them before the catch-all"},
+	}, diags)
+
+	require.Empty(t, Lint(Must(ParseString("/a /b\n/* /index.html 200\n/ /home"))), "/* doesn't match /")
+}
+
+func TestLintDuplicates(t *testing.T) {
+	rules := Must(ParseString(`
+	/a        /b
+	/c/:x     /d/:x  302
+	/a        /b     301
+	/c/:x/    /d/:x  302
+	/a        /e
+	/a        /b     302
+	`))
+
+	require.Equal(t, []Diagnostic{
+		{Severity: SeverityInfo, Code: CodeDuplicateRule, Rule: 2, Line: 4, Related: 0, Message: `duplicate of the rule on line 2`},
+		{Severity: SeverityInfo, Code: CodeDuplicateRule, Rule: 3, Line: 5, Related: 1, Message: `duplicate of the rule on line 3`},
+		{Code: CodeConflictingRule, Rule: 4, Line: 6, Related: 0, Message: `conflicts with the rule on line 2, which already maps "/a" to "/b" with status 301`},
+		{Code: CodeConflictingRule, Rule: 5, Line: 7, Related: 0, Message: `conflicts with the rule on line 2, which already maps "/a" to "/b" with status 301`},
+	}, Lint(rules))
+}
+
+func TestLintLoops(t *testing.T) {
+	rules := Must(ParseString(`
+	/self         /self
+	/a            /b?from=a
+	/b            /c       302
+	/c            /a       307
+	/posts/:id    /articles/:id
+	/articles/:x  /posts/:x
+	/docs/*       /docs/:splat
+	/ok           /elsewhere
+	/rewrite      /rewrite  200
+	/proxy        https://example.com/proxy
+	`))
+
+	// the rewrite to itself is there to not be a loop
+	noRedundant := WithSuppressed(CodeRedundantRule)
+
+	diags := Lint(rules, noRedundant)
+	require.Equal(t, []Diagnostic{
+		{Code: CodeRedirectLoop, Rule: 0, Line: 2, Related: -1, Message: "redirects to itself", strict: true},
+		{Code: CodeRedirectLoop, Rule: 1, Line: 3, Related: -1, Message: "redirect loop /a -> /b -> /c -> /a", strict: true},
+		{Code: CodeRedirectLoop, Rule: 4, Line: 6, Related: -1, Message: "redirect loop /posts/:id -> /articles/:x -> /posts/:id", strict: true},
+		{Code: CodeRedirectLoop, Rule: 6, Line: 8, Related: -1, Message: "redirects to itself", strict: true},
+	}, diags)
+
+	t.Run("strict", func(t *testing.T) {
+		for _, d := range Lint(rules, WithStrict(), noRedundant) {
+			require.Equal(t, SeverityError, d.Severity)
+		}
+
+		_, err := ParseString("/ok /fine\n/a /b\n/b /a", WithStrict())
+		require.EqualError(t, err, "line 2: redirect loop /a -> /b -> /a")
+
+		_, err = ParseString("/a /b\n/b /a")
+		require.NoError(t, err)
+	})
+
+	t.Run("any order", func(t *testing.T) {
+		loops := func(src string) []string {
+			var msgs []string
+			for _, d := range Lint(Must(ParseString(src))) {
+				if d.Code == CodeRedirectLoop {
+					msgs = append(msgs, fmt.Sprintf("%d: %s", d.Rule, d.Message))
+				}
+			}
+			return msgs
+		}
+		require.Equal(t, []string{"0: redirect loop /a/:x -> /b -> /a/:x"}, loops("/a/:x /b\n/b /a/q"))
+		require.Equal(t, []string{"0: redirect loop /b -> /a/:x -> /b"}, loops("/b /a/q\n/a/:x /b"))
+
+		_, err := ParseString("/a/:x /b\n/b /a/q", WithStrict())
+		require.ErrorContains(t, err, "redirect loop")
+		_, err = ParseString("/b /a/q\n/a/:x /b", WithStrict())
+		require.ErrorContains(t, err, "redirect loop")
+
+		// a loop reached from another rule is still reported once
+		require.Equal(t, []string{"1: redirect loop /a -> /b -> /a"}, loops("/start /a\n/a /b\n/b /a"))
+	})
+
+	t.Run("long chains", func(t *testing.T) {
+		// walks share what they explored, a file of chained rules at the size
+		// limit lints in well under a second rather than minutes
+		var b strings.Builder
+		i := 0
+		for ; b.Len() < MaxFileSizeInBytes-64; i++ {
+			fmt.Fprintf(&b, "/%d/:x /%d/:x\n", i, i+1)
+		}
+		start := time.Now()
+		_, err := ParseString(b.String(), WithStrict())
+		require.NoError(t, err)
+
+		// and the loop closing the chain is still found
+		fmt.Fprintf(&b, "/%d/:x /0/:x\n", i)
+		_, err = ParseString(b.String(), WithStrict())
+		require.ErrorContains(t, err, "redirect loop")
+		require.Less(t, time.Since(start), 10*time.Second)
+	})
+}
@@ -0,0 +1,21 @@
+package redirects
+
+// A Logger receives the debug records of parsing and evaluating rules, for
+// debugging gateways in production. *slog.Logger implements it. Records are
+// key-value pairs the way slog takes them:
+//
+//   - "skipped line", with "line" and "reason", "empty" or "comment"
+//   - "quarantined rule", with "line", "code" and "error", see
+//     WithQuarantine
+//   - "evaluated path", with "path", "rule", the index of the matching rule
+//     or -1, and "status", that of the rule or zero
+type Logger interface {
+	Debug(msg string, args ...any)
+}
+
+// WithLogger makes Parse and the RuleSets compiled with it log to l.
+func WithLogger(l Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
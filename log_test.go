@@ -0,0 +1,39 @@
+package redirects
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+	var b bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&b, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	rules, err := ParseString(`# redirects
+
+/old /new 301
+/proxy https://evil.example.com 200
+`, WithLogger(logger), WithRelativeOnly(), WithQuarantine(func(Diagnostic) {}))
+	require.NoError(t, err)
+	set := Compile(rules, WithLogger(logger))
+	set.Match("/old")
+	set.Match("/missing")
+
+	require.Equal(t, `level=DEBUG msg="skipped line" line=1 reason=comment
+level=DEBUG msg="skipped line" line=2 reason=empty
+level=DEBUG msg="quarantined rule" line=4 code=disallowed-to error="parsing 'to': destination must be a path on the same site"
+level=DEBUG msg="evaluated path" path=/old rule=0 status=301
+level=DEBUG msg="evaluated path" path=/missing rule=-1 status=0
+`, b.String())
+}
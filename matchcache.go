@@ -0,0 +1,65 @@
+package redirects
+
+import (
+	"container/list"
+	"sync"
+)
+
+// matchResult is the outcome of matching a path against a RuleSet.
+type matchResult struct {
+	index int
+	rule  Rule
+	ok    bool
+}
+
+// matchCache is a bounded, concurrency-safe LRU cache of match results keyed
+// by request path.
+type matchCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+}
+
+type matchCacheEntry struct {
+	path   string
+	result matchResult
+}
+
+func newMatchCache(size int) *matchCache {
+	return &matchCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+func (c *matchCache) get(path string) (matchResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[path]
+	if !ok {
+		return matchResult{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*matchCacheEntry).result, true
+}
+
+func (c *matchCache) add(path string, result matchResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[path]; ok {
+		e.Value.(*matchCacheEntry).result = result
+		c.order.MoveToFront(e)
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*matchCacheEntry).path)
+	}
+	c.entries[path] = c.order.PushFront(&matchCacheEntry{path: path, result: result})
+}
@@ -0,0 +1,50 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchCache(t *testing.T) {
+	c := newMatchCache(2)
+	c.add("/a", matchResult{index: 0, ok: true})
+	c.add("/b", matchResult{index: -1})
+
+	r, ok := c.get("/a")
+	require.True(t, ok)
+	require.Equal(t, matchResult{index: 0, ok: true}, r)
+
+	// "/b" is the least recently used now
+	c.add("/c", matchResult{index: 2, ok: true})
+	_, ok = c.get("/b")
+	require.False(t, ok)
+	_, ok = c.get("/a")
+	require.True(t, ok)
+	_, ok = c.get("/c")
+	require.True(t, ok)
+
+	c.add("/c", matchResult{index: 3, ok: true})
+	r, _ = c.get("/c")
+	require.Equal(t, 3, r.index)
+	require.Equal(t, 2, c.order.Len())
+}
+
+func TestRuleSetMatchCache(t *testing.T) {
+	rules := Must(ParseString("/posts/:slug /articles/:slug\n/static /target 302"))
+	s := Compile(rules, WithMatchCache(8))
+
+	for i := 0; i < 2; i++ {
+		rule, ok := s.Match("/posts/hello")
+		require.True(t, ok)
+		require.Equal(t, "/articles/hello", rule.To)
+
+		rule, ok = s.Match("/static")
+		require.True(t, ok)
+		require.Equal(t, 302, rule.Status)
+
+		_, ok = s.Match("/missing")
+		require.False(t, ok)
+	}
+	require.Equal(t, 3, s.cache.order.Len())
+}
@@ -0,0 +1,23 @@
+package redirects
+
+// A MetricsSink counts what parsing and evaluating rules does, so operators
+// can monitor redirects per site. The redirectsprom package provides one
+// exposing Prometheus metrics. Implementations must be safe for concurrent
+// use, RuleSets are.
+type MetricsSink interface {
+	// Evaluated is called for each path a RuleSet evaluates, with the
+	// status of the matching rule, or zero if no rule matches.
+	Evaluated(status int)
+
+	// ParseFailed is called when parsing a file fails, with the code of
+	// the error, the Code of its ErrorDiagnostic, which may be empty.
+	ParseFailed(code string)
+}
+
+// WithMetrics makes ParseContext, Parse and the RuleSets compiled with it
+// report to m.
+func WithMetrics(m MetricsSink) Option {
+	return func(c *config) {
+		c.metrics = m
+	}
+}
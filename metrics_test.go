@@ -0,0 +1,34 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingSink records what it's called with.
+type countingSink struct {
+	statuses []int
+	codes    []string
+}
+
+func (s *countingSink) Evaluated(status int) { s.statuses = append(s.statuses, status) }
+
+func (s *countingSink) ParseFailed(code string) { s.codes = append(s.codes, code) }
+
+func TestMetrics(t *testing.T) {
+	sink := &countingSink{}
+	rules, err := ParseString("/old /new 301\n/app/* /index.html 200\n", WithMetrics(sink))
+	require.NoError(t, err)
+	_, err = ParseString("/old /new 301\n/a\n", WithMetrics(sink))
+	require.Error(t, err)
+
+	set := Compile(rules, WithMetrics(sink), WithMatchCache(8))
+	set.Match("/old")
+	set.Match("/old")
+	set.Resolve("/app/x")
+	set.Match("/missing")
+
+	require.Equal(t, []int{301, 301, 200, 0}, sink.statuses)
+	require.Equal(t, []string{CodeMissingTo}, sink.codes)
+}
@@ -0,0 +1,105 @@
+package redirects
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+	"github.com/ucarion/urlpath"
+)
+
+// Optimize returns rules without the rules that don't change what any path
+// does, to shrink large files under MaxFileSizeInBytes, and a diagnostic for
+// each rule removed, referring to the rule that makes it useless:
+//
+//   - rules with the same From as an earlier rule, which always matches
+//     first
+//   - rules matching only paths an earlier rule matches, like those after a
+//     catch-all such as "/* /index.html 200"
+//   - rules for a single path that the next rule matching the path handles
+//     the same way, like "/docs/a /guide/a" before "/docs/* /guide/:splat",
+//     which merges them into the rule with the splat
+//
+// The rules left apply to every path like rules do, with the same status and
+// Force. Per-path rules are only merged into rules that already exist:
+// replacing them with a new rule with a splat would also change what the
+// paths none of them matches do, which ProposeSplats reports.
+func Optimize(rules Rules) (Rules, []Diagnostic) {
+	patterns := make([]*urlpath.Path, len(rules))
+	for i, rule := range rules {
+		patterns[i] = urlpattern.Compile(rule.From)
+	}
+
+	var diags []Diagnostic
+	removed := make([]bool, len(rules))
+	remove := func(j, i int, code, format string, args ...any) {
+		removed[j] = true
+		diags = append(diags, Diagnostic{
+			Severity: SeverityInfo,
+			Code:     code,
+			Rule:     j,
+			Line:     rules[j].Line,
+			Related:  i,
+			Message:  "removed, " + fmt.Sprintf(format, args...),
+		})
+	}
+
+	first := make(map[string]int, len(rules))
+	for j, rule := range rules {
+		from := strings.TrimSuffix(rule.From, "/")
+		if i, ok := first[from]; ok {
+			remove(j, i, CodeDuplicateRule, "%s has the same from", describeRule(rules[i], i))
+			continue
+		}
+		first[from] = j
+
+		for i := 0; i < j; i++ {
+			if removed[i] {
+				continue
+			}
+			if covers(patterns[i], patterns[j]) {
+				remove(j, i, CodeUnreachableRule, "%s %q always matches first", describeRule(rules[i], i), rules[i].From)
+				break
+			}
+		}
+	}
+
+	// removing a rule for a single path only changes what the path does,
+	// which the next rule matching it keeps the same, so the rules can be
+	// removed one after the other
+	for j, rule := range rules {
+		path, static := urlpattern.Static(patterns[j])
+		if removed[j] || !static {
+			continue
+		}
+		to := compileTemplate(rule.To, patterns[j]).expand(nil, "")
+		for i := j + 1; i < len(rules); i++ {
+			if removed[i] {
+				continue
+			}
+			captures, trailing, ok := matchPath(patterns[i], path, nil)
+			if !ok {
+				continue
+			}
+			next := rules[i]
+			if next.Status == rule.Status && next.Force == rule.Force && !unsafeDestination(next.To, to) &&
+				compileTemplate(next.To, patterns[i]).expand(captures, trailing) == to {
+				remove(j, i, CodeRedundantRule, "%s %q does the same for %q", describeRule(next, i), next.From, path)
+			}
+			break
+		}
+	}
+
+	optimized := make(Rules, 0, len(rules))
+	for i, rule := range rules {
+		if !removed[i] {
+			optimized = append(optimized, rule)
+		}
+	}
+	slices.SortStableFunc(diags, func(a, b Diagnostic) int {
+		return cmp.Compare(a.Rule, b.Rule)
+	})
+	return optimized, diags
+}
@@ -0,0 +1,74 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptimize(t *testing.T) {
+	rules := Must(ParseString(`
+/old           /new
+/old/          /other
+/docs/a        /guide/a
+/docs/b        /guide/b         302
+/docs/c        /guide/c
+/docs/*        /guide/:splat
+/blog/:year/x  /x
+/blog/2024/x   /y
+/a /b
+/*             /index.html      200
+/c             /d
+/docs/d        /guide/d
+`))
+
+	optimized, diags := Optimize(rules)
+	var froms []string
+	for _, rule := range optimized {
+		froms = append(froms, rule.From)
+	}
+	require.Equal(t, []string{"/old", "/docs/b", "/docs/*", "/blog/:year/x", "/a", "/*"}, froms)
+
+	var messages []string
+	for _, d := range diags {
+		require.Equal(t, SeverityInfo, d.Severity)
+		messages = append(messages, d.String()+" ("+d.Code+")")
+	}
+	require.Equal(t, []string{
+		"line 3: removed, the rule on line 2 has the same from (duplicate-rule)",
+		`line 4: removed, the rule on line 7 "/docs/*" does the same for "/docs/a" (redundant-rule)`,
+		`line 6: removed, the rule on line 7 "/docs/*" does the same for "/docs/c" (redundant-rule)`,
+		`line 9: removed, the rule on line 8 "/blog/:year/x" always matches first (unreachable-rule)`,
+		`line 12: removed, the rule on line 11 "/*" always matches first (unreachable-rule)`,
+		`line 13: removed, the rule on line 7 "/docs/*" always matches first (unreachable-rule)`,
+	}, messages)
+
+	// every path does the same
+	before, after := Compile(rules), Compile(optimized)
+	for _, path := range []string{"/old", "/old/", "/docs/a", "/docs/b", "/docs/c", "/docs/d", "/docs/e/f", "/blog/2024/x", "/blog/2025/x", "/a", "/c", "/zzz"} {
+		want, wantOK := before.Match(path)
+		got, gotOK := after.Match(path)
+		require.Equal(t, wantOK, gotOK, path)
+		require.Equal(t, want.To, got.To, path)
+		require.Equal(t, want.Status, got.Status, path)
+	}
+
+	optimized, diags = Optimize(nil)
+	require.Empty(t, optimized)
+	require.Empty(t, diags)
+}
+
+func TestOptimizeKeepsDifferences(t *testing.T) {
+	// the next rule matching the path would change its status, Force or
+	// destination, or there's none
+	rules := Must(ParseString(`
+/docs/a  /guide/a  302
+/docs/b  /guide/b  301!
+/docs/c  /guide/x
+/docs/*  /guide/:splat
+/e       /f
+`, WithAllowForced()))
+	optimized, diags := Optimize(rules)
+	require.Equal(t, Rules(rules), optimized)
+	require.Empty(t, diags)
+}
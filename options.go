@@ -0,0 +1,214 @@
+package redirects
+
+// An Option configures how rules are parsed or compiled. Options that don't
+// apply to an operation are ignored by it.
+type Option func(*config)
+
+type config struct {
+	strict            bool
+	maxFileSize       int
+	maxLineLength     int
+	maxRules          int
+	maxPlaceholders   int
+	ruleCountHint     int
+	parallelThreshold int
+	matchCacheSize    int
+	maxSplatLength    int
+	singleDecoding    bool
+	validateCID       func(string) error
+	verify            func([]byte) error
+	nameResolver      NameResolver
+	tracer            Tracer
+	metrics           MetricsSink
+	logger            Logger
+	source            []byte
+	deprecated        func(Diagnostic)
+	quarantine        func(Diagnostic)
+	suppressed        []string
+	maxComplexity     int
+	relativeOnly      bool
+	noIPFSURLs        bool
+	httpsOnly         bool
+	redact            bool
+	allowForced       bool
+	proxyHosts        []string
+	dynamicProxyHosts bool
+	publicProxiesOnly bool
+	gatewaySpec       bool
+	evalTrace         func(EvalTrace)
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		maxFileSize:     MaxFileSizeInBytes,
+		maxLineLength:   DefaultMaxLineLength,
+		maxPlaceholders: DefaultMaxPlaceholders,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.gatewaySpec {
+		c.allowForced = false
+		c.dynamicProxyHosts = false
+	}
+	return c
+}
+
+// DefaultMaxLineLength is the default maximum length in bytes of a line in a
+// _redirects file. Real rules are far shorter, the limit keeps a single
+// adversarial line from producing an absurd rule.
+const DefaultMaxLineLength = 4 << 10
+
+// WithMaxLineLength sets the maximum length in bytes of a line in a
+// _redirects file, not counting the line terminator. Parse returns a
+// *LineTooLongError for longer lines. It defaults to DefaultMaxLineLength,
+// zero or less means MaxFileSizeInBytes.
+func WithMaxLineLength(n int) Option {
+	return func(c *config) {
+		c.maxLineLength = n
+		if n <= 0 {
+			c.maxLineLength = MaxFileSizeInBytes
+		}
+	}
+}
+
+// DefaultMaxPlaceholders is the default maximum number of placeholders in
+// the From and in the To of a rule.
+const DefaultMaxPlaceholders = 32
+
+// WithMaxPlaceholders sets the maximum number of placeholders, the splat
+// included, in the From and in the To of a rule, bounding the cost of
+// matching and expanding pathological generated rules on shared gateways.
+// Zero or less removes the limit.
+func WithMaxPlaceholders(n int) Option {
+	return func(c *config) {
+		c.maxPlaceholders = n
+	}
+}
+
+// WithRuleCountHint tells Parse how many rules to expect, so it can allocate
+// the rules at once instead of growing them as it goes. ParseBytes and
+// ParseString provide the hint themselves.
+func WithRuleCountHint(n int) Option {
+	return func(c *config) {
+		c.ruleCountHint = n
+	}
+}
+
+// WithParallelThreshold makes a compiled RuleSet scan its rules concurrently
+// when it has more than n rules with placeholders or splats. Matching returns
+// the same rule as a sequential scan. Zero, the default, disables concurrent
+// scanning.
+func WithParallelThreshold(n int) Option {
+	return func(c *config) {
+		c.parallelThreshold = n
+	}
+}
+
+// WithMaxSplatLength makes a compiled RuleSet's rules with a splat not match
+// paths whose splat would capture more than n bytes, so extremely long paths
+// don't end up in destinations and logs. Later rules can still match them.
+// Zero, the default, disables the limit.
+func WithMaxSplatLength(n int) Option {
+	return func(c *config) {
+		c.maxSplatLength = n
+	}
+}
+
+// WithMatchCache makes a compiled RuleSet remember the outcome of matching the
+// last size distinct paths, which pays off for popular sites that see the same
+// handful of paths over and over. Zero, the default, disables the cache.
+func WithMatchCache(size int) Option {
+	return func(c *config) {
+		c.matchCacheSize = size
+	}
+}
+
+// WithStrict makes Parse reject anything outside the documented grammar,
+// which it otherwise tolerates for compatibility, as CI validators would:
+//
+//   - statuses not spelled as three digits, like 0301
+//   - 'from' paths with a query or a fragment, which never match
+//   - asterisks not making up a path segment of their own, like /a*, which
+//     match literally
+//   - problems Lint reports as errors in strict mode: redirect loops and
+//     undefined, reserved or duplicate placeholders
+//
+// It also makes Lint report problems that likely break a site as errors
+// instead of warnings.
+func WithStrict() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+// WithDeprecationWarnings makes Parse call warn for each rule using a
+// spelling that is still accepted but superseded, like a status of 0301, with
+// the spelling to use instead in the diagnostic's Replacement. In strict mode
+// these spellings are errors instead.
+func WithDeprecationWarnings(warn func(Diagnostic)) Option {
+	return func(c *config) {
+		c.deprecated = warn
+	}
+}
+
+// WithQuarantine makes Parse drop rules violating the destination policies of
+// the other options, like WithProxyHosts, or the limit of WithMaxPlaceholders,
+// calling report for each with a warning explaining why, instead of rejecting
+// the whole file. One unsafe rule then doesn't take all the redirects of a
+// site offline. Syntax errors still make Parse fail.
+func WithQuarantine(report func(Diagnostic)) Option {
+	return func(c *config) {
+		c.quarantine = report
+	}
+}
+
+// quarantineRule reports the rule dropped for err, with a warning.
+func (c *config) quarantineRule(err error) {
+	if c.redact {
+		err = redactError(err)
+	}
+	d := ErrorDiagnostic(err)
+	d.Severity = SeverityWarning
+	if c.logger != nil {
+		c.logger.Debug("quarantined rule", "line", d.Line, "code", d.Code, "error", d.Message)
+	}
+	c.quarantine(d)
+}
+
+// WithRelativeOnly makes Parse reject rules whose To isn't a path on the same
+// site, so a _redirects file can't redirect or proxy requests to other sites,
+// including with protocol-relative //host destinations.
+func WithRelativeOnly() Option {
+	return func(c *config) {
+		c.relativeOnly = true
+	}
+}
+
+// WithoutIPFSURLs makes Parse reject rules whose To is an ipfs:// or ipns://
+// URL, for gateways that can't resolve them. Paths like /ipfs/cid on the same
+// site and http or https URLs aren't affected.
+func WithoutIPFSURLs() Option {
+	return func(c *config) {
+		c.noIPFSURLs = true
+	}
+}
+
+// WithHTTPSOnlyProxies makes Parse reject rules whose To is an http:// URL,
+// so no rule downgrades users to cleartext when redirecting or proxying them
+// off-site.
+func WithHTTPSOnlyProxies() Option {
+	return func(c *config) {
+		c.httpsOnly = true
+	}
+}
+
+// WithAllowForced makes Parse accept forced rules, whose status ends with "!"
+// like "200!", setting their Force. They apply even to paths with content,
+// so they're rejected by default, for gateways to keep serving the content
+// of a site over its rules.
+func WithAllowForced() Option {
+	return func(c *config) {
+		c.allowForced = true
+	}
+}
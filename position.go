@@ -0,0 +1,100 @@
+package redirects
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A ParseError reports a malformed rule and where it is in the file.
+type ParseError struct {
+	// Line is the 1-based number of the offending line.
+	Line int
+
+	// Column is the 1-based byte column where the offending field starts
+	// in the line, and EndColumn the column just past it. They are zero if
+	// the problem isn't with a single field.
+	Column, EndColumn int
+
+	// Code identifies the kind of problem, it's one of the Code constants.
+	Code string
+
+	// Err is the problem.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Fields of a rule, in the order they appear on its line.
+const (
+	fieldNone = iota
+	fieldFrom
+	fieldTo
+	fieldStatus
+)
+
+// fieldError returns a ParseError for err, a problem of kind code with field
+// of the n-th line.
+func fieldError(line, field []byte, n int, code string, err error) *ParseError {
+	start := fieldOffset(line, field)
+	return &ParseError{Line: n, Column: start + 1, EndColumn: start + len(field) + 1, Code: code, Err: err}
+}
+
+// fieldOffset returns the offset of field in line, field being a subslice of
+// line. Both end at the same place in memory, or field has been cut short,
+// so their capacities tell the offset apart.
+func fieldOffset(line, field []byte) int {
+	return cap(line) - cap(field)
+}
+
+// WithSource gives Lint and ValidateDestinations the file the rules were
+// parsed from, so diagnostics about parsed rules carry the columns of the
+// offending token, for editors to underline it.
+func WithSource(src []byte) Option {
+	return func(c *config) {
+		c.source = src
+	}
+}
+
+// locate sets the columns of the diagnostics from the source lines of the
+// rules, and drops what locating them takes. Diagnostics whose rule doesn't
+// match its line in src are left without columns.
+func locate(diags []Diagnostic, rules Rules, src []byte) {
+	var lines [][]byte
+	if src != nil {
+		lines = bytes.Split(src, []byte{'\n'})
+	}
+
+	for i := range diags {
+		d := &diags[i]
+		field, token := d.field, d.token
+		d.field, d.token = fieldNone, ""
+
+		if field == fieldNone || d.Line <= 0 || d.Line > len(lines) {
+			continue
+		}
+		line := bytes.TrimSuffix(lines[d.Line-1], []byte{'\r'})
+
+		var fields [3][]byte
+		n, _ := splitFields(line, &fields)
+		rule := rules[d.Rule]
+		if n < 2 || string(fields[0]) != rule.From || field > n {
+			continue
+		}
+
+		f := fields[field-1]
+		start := fieldOffset(line, f)
+		end := start + len(f)
+		if j := strings.Index(string(f), token); token != "" && j >= 0 {
+			start += j
+			end = start + len(token)
+		}
+		d.Column, d.EndColumn = start+1, end+1
+	}
+}
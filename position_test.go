@@ -0,0 +1,62 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrorColumns(t *testing.T) {
+	for _, tc := range []struct {
+		src               string
+		line, column, end int
+	}{
+		{"/a /b\n  /c   /d   999\n", 2, 13, 16},
+		{"/a /b\r\n\t/c  ftp://x", 2, 6, 13},
+		{"a /b", 1, 1, 2},
+		{"/:a/:b/:c/:d /x", 1, 1, 13},
+		{"/a", 1, 0, 0},
+		{"/a /b 301 x", 1, 0, 0},
+	} {
+		t.Run(tc.src, func(t *testing.T) {
+			_, err := ParseString(tc.src, WithMaxPlaceholders(3))
+
+			var parseErr *ParseError
+			require.ErrorAs(t, err, &parseErr)
+			require.Equal(t, tc.line, parseErr.Line)
+			require.Equal(t, tc.column, parseErr.Column)
+			require.Equal(t, tc.end, parseErr.EndColumn)
+		})
+	}
+}
+
+func TestLintWithSource(t *testing.T) {
+	src := []byte("/a  /b\n\n/a/:x/:y   /c/:x/:z\r\n/a    /d\n/loop /loop\n")
+	rules := Must(ParseBytes(src))
+
+	type span struct{ line, column, end int }
+	var spans []span
+	for _, d := range Lint(rules, WithSource(src)) {
+		spans = append(spans, span{d.Line, d.Column, d.EndColumn})
+	}
+	require.Equal(t, []span{
+		{3, 18, 20}, // ":z" is not defined
+		{3, 7, 9},   // ":y" is not used
+		{4, 1, 3},   // duplicate "/a"
+		{5, 7, 12},  // "/loop" redirects to itself
+	}, spans)
+
+	t.Run("stale source", func(t *testing.T) {
+		for _, d := range Lint(rules, WithSource([]byte("/x /y\n"))) {
+			require.Zero(t, d.Column)
+			require.Zero(t, d.EndColumn)
+		}
+	})
+
+	t.Run("destinations", func(t *testing.T) {
+		diags := ValidateDestinations(rules, func(string) bool { return false }, WithSource(src))
+		require.Equal(t, 1, diags[0].Line)
+		require.Equal(t, 5, diags[0].Column)
+		require.Equal(t, 7, diags[0].EndColumn)
+	})
+}
@@ -0,0 +1,49 @@
+package redirects
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxPrettyWidth is the widest a column Pretty aligns gets, longer fields
+// push the next column of their line only.
+const maxPrettyWidth = 40
+
+// Pretty writes the rules to w for people to read, like in the output of
+// tools and in code reviews: one rule per line, with the from, to and status
+// columns aligned, the status always present. Rules have no conditions, so
+// there's no column for them. The output is a valid _redirects file, parsing
+// it returns the same rules like for WriteTo, but WriteTo's form is the one
+// to diff or hash, since a rule added to a file can realign all the others.
+func (r Rules) Pretty(w io.Writer) error {
+	var fromWidth, toWidth int
+	for _, rule := range r {
+		if n := len(rule.From); n <= maxPrettyWidth {
+			fromWidth = max(fromWidth, n)
+		}
+		if n := len(rule.To); n <= maxPrettyWidth {
+			toWidth = max(toWidth, n)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, rule := range r {
+		writePadded(bw, rule.From, fromWidth)
+		writePadded(bw, rule.To, toWidth)
+		bw.WriteString(strconv.Itoa(rule.Status))
+		if rule.Force {
+			bw.WriteByte('!')
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// writePadded writes field padded with spaces to width, and two more
+// separating it from the next column.
+func writePadded(w *bufio.Writer, field string, width int) {
+	w.WriteString(field)
+	w.WriteString(strings.Repeat(" ", max(width-len(field), 0)+2))
+}
@@ -0,0 +1,38 @@
+package redirects
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulesPretty(t *testing.T) {
+	rules := Rules(Must(ParseString(`
+/home /
+/posts/:slug /articles/:slug 302
+/app/* /index.html 200!
+/a-very-long-path-nobody-would-want-to-align-with /b
+/*  /404.html  404
+`, WithAllowForced())))
+
+	var b strings.Builder
+	require.NoError(t, rules.Pretty(&b))
+	require.Equal(t, `/home         /                301
+/posts/:slug  /articles/:slug  302
+/app/*        /index.html      200!
+/a-very-long-path-nobody-would-want-to-align-with  /b               301
+/*            /404.html        404
+`, b.String())
+
+	again, err := ParseString(b.String(), WithAllowForced())
+	require.NoError(t, err)
+	for i := range again {
+		again[i].Line = rules[i].Line
+	}
+	require.Equal(t, rules, Rules(again))
+
+	b.Reset()
+	require.NoError(t, Rules(nil).Pretty(&b))
+	require.Empty(t, b.String())
+}
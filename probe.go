@@ -0,0 +1,99 @@
+package redirects
+
+import (
+	"bytes"
+	"io"
+	"unicode"
+)
+
+// Capabilities summarizes what kinds of rules a _redirects file contains.
+type Capabilities struct {
+	// Rules is true if the file has at least one rule.
+	Rules bool
+
+	// Rewrite is true if the file has a 200 rewrite rule.
+	Rewrite bool
+
+	// NotFound is true if the file has a 404 rule, e.g. a custom 404 page.
+	NotFound bool
+
+	// Forced is true if the file has a forced ("shadowing") rule, such as
+	// 301!.
+	Forced bool
+}
+
+func (c Capabilities) complete() bool {
+	return c.Rules && c.Rewrite && c.NotFound && c.Forced
+}
+
+// ProbeCapabilities scans a _redirects file for the kinds of rules it
+// contains, without validating or materializing the rules. It stops reading as
+// soon as all capabilities are known to be present, so gateways can cheaply
+// decide whether to engage the redirects machinery at all.
+//
+// Only the status of each rule is inspected: a file that ProbeCapabilities
+// accepts may still fail to Parse.
+func ProbeCapabilities(r io.Reader) (Capabilities, error) {
+	var c Capabilities
+
+	// reading one byte beyond the limit is enough to tell it's exceeded
+	lines := newLineReader(io.LimitReader(r, MaxFileSizeInBytes+1), MaxFileSizeInBytes)
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			break
+		}
+		if lines.offset > MaxFileSizeInBytes {
+			return Capabilities{}, ErrFileTooLarge
+		}
+		if err != nil {
+			return Capabilities{}, err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		c.Rules = true
+
+		status := nthField(line, 2)
+		if len(status) > 0 && status[len(status)-1] == '!' {
+			c.Forced = true
+			status = status[:len(status)-1]
+		}
+		switch string(status) {
+		case "200":
+			c.Rewrite = true
+		case "404":
+			c.NotFound = true
+		}
+
+		if c.complete() {
+			return c, nil
+		}
+	}
+
+	return c, nil
+}
+
+// nthField returns the n-th whitespace separated field of line, or nil if
+// line has fewer fields.
+func nthField(line []byte, n int) []byte {
+	for {
+		i := bytes.IndexFunc(line, isNotSpace)
+		if i < 0 {
+			return nil
+		}
+		line = line[i:]
+
+		j := bytes.IndexFunc(line, unicode.IsSpace)
+		if j < 0 {
+			j = len(line)
+		}
+		if n == 0 {
+			return line[:j]
+		}
+		n--
+		line = line[j:]
+	}
+}
@@ -0,0 +1,47 @@
+package redirects
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeCapabilities(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		file string
+		want Capabilities
+	}{
+		{"empty", "", Capabilities{}},
+		{"comments only", "# /a /b 200\n\n", Capabilities{}},
+		{"redirects", "/a /b\n/c /d 302", Capabilities{Rules: true}},
+		{"rewrite", "/a /b\n/* /index.html 200", Capabilities{Rules: true, Rewrite: true}},
+		{"not found", "/* /404.html   404\r\n", Capabilities{Rules: true, NotFound: true}},
+		{"forced rewrite", "/a /b 200!", Capabilities{Rules: true, Rewrite: true, Forced: true}},
+		{"forced redirect", "/a /b 301!\n/c /d 404", Capabilities{Rules: true, NotFound: true, Forced: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := ProbeCapabilities(strings.NewReader(tc.file))
+			require.NoError(t, err)
+			require.Equal(t, tc.want, c)
+		})
+	}
+
+	t.Run("stops once everything is known", func(t *testing.T) {
+		r := strings.NewReader("/a /b 200\n/c /d 404!\n" + strings.Repeat("/e /f 302\n", 5000))
+		c, err := ProbeCapabilities(r)
+		require.NoError(t, err)
+		require.Equal(t, Capabilities{Rules: true, Rewrite: true, NotFound: true, Forced: true}, c)
+
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NotEmpty(t, rest)
+	})
+
+	t.Run("with too large file", func(t *testing.T) {
+		_, err := ProbeCapabilities(strings.NewReader(strings.Repeat("/from /to 301\n", MaxFileSizeInBytes/10)))
+		require.ErrorContains(t, err, "redirects file size cannot exceed")
+	})
+}
@@ -0,0 +1,117 @@
+package redirects
+
+import (
+	"errors"
+	"strings"
+)
+
+// redacted replaces the sensitive parts of URLs.
+const redacted = "REDACTED"
+
+// WithRedaction makes the errors returned by Parse and the diagnostics of
+// Lint and ValidateDestinations redact the URLs they mention, like Redact, so
+// they can be logged on shared infrastructure. Lines and columns are kept.
+func WithRedaction() Option {
+	return func(c *config) {
+		c.redact = true
+	}
+}
+
+// Redact returns s with the values of URL query parameters and the passwords
+// in URL user info replaced by "REDACTED", keeping the parameter names, so
+// messages mentioning destinations, which may embed tokens, can be logged.
+// It can be used on the errors of RuleSet.Resolve or on request paths.
+func Redact(s string) string {
+	var b strings.Builder
+	literal := 0
+	for i := 0; i < len(s); i++ {
+		var start, end int
+		switch {
+		case s[i] == '?' || s[i] == '&':
+			// a query parameter value runs from '=' to the next delimiter
+			key := i + 1 + strings.IndexFunc(s[i+1:]+" ", isRedactDelimiter)
+			if key >= len(s) || s[key] != '=' || key == i+1 {
+				continue
+			}
+			start = key + 1
+			end = start + strings.IndexFunc(s[start:]+" ", isRedactDelimiter)
+			for end < len(s) && s[end] == '=' {
+				// base64 padding and values with '=' are part of the value
+				end++
+				end += strings.IndexFunc(s[end:]+" ", isRedactDelimiter)
+			}
+		case strings.HasPrefix(s[i:], "//"):
+			// a password runs from the first ':' of the user info to '@'
+			authority := s[i+2:]
+			if j := strings.IndexAny(authority, "/?#\"' \t"); j >= 0 {
+				authority = authority[:j]
+			}
+			at := strings.LastIndexByte(authority, '@')
+			colon := strings.IndexByte(authority, ':')
+			if at < 0 || colon < 0 || colon > at {
+				continue
+			}
+			start, end = i+2+colon+1, i+2+at
+		default:
+			continue
+		}
+		if start == end {
+			continue
+		}
+		b.WriteString(s[literal:start])
+		b.WriteString(redacted)
+		literal = end
+		i = end - 1
+	}
+	if literal == 0 {
+		return s
+	}
+	b.WriteString(s[literal:])
+	return b.String()
+}
+
+// isRedactDelimiter reports whether r ends a query parameter name or value
+// in the text Redact works on, which quotes URLs in messages.
+func isRedactDelimiter(r rune) bool {
+	switch r {
+	case '=', '&', '#', '"', '\'', '\\', ' ', '\t', '\n':
+		return true
+	}
+	return false
+}
+
+// redactedError is an error with a redacted message.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redactError returns err, an error returned by Parse, with its message
+// redacted.
+func redactError(err error) error {
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		return err
+	}
+	msg := parseErr.Err.Error()
+	if r := Redact(msg); r != msg {
+		e := *parseErr
+		e.Err = &redactedError{msg: r, err: parseErr.Err}
+		return &e
+	}
+	return err
+}
+
+// redactDiagnostics redacts the messages of diags if c asks for it.
+func redactDiagnostics(diags []Diagnostic, c *config) {
+	if !c.redact {
+		return
+	}
+	for i := range diags {
+		diags[i].Message = Redact(diags[i].Message)
+		diags[i].Replacement = Redact(diags[i].Replacement)
+	}
+}
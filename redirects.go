@@ -2,19 +2,29 @@
 package redirects
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
 	"github.com/ucarion/urlpath"
 )
 
 // 64 KiB
 const MaxFileSizeInBytes = 65536
 
+// ErrFileTooLarge is returned by Parse for files larger than
+// MaxFileSizeInBytes, or matched by the error it returns for files larger
+// than the limit set with WithMaxFileSize.
+var ErrFileTooLarge = fmt.Errorf("redirects file size cannot exceed %d bytes", MaxFileSizeInBytes)
+
 // A Rule represents a single redirection or rewrite rule.
 type Rule struct {
 	// From is the path which is matched to perform the rule.
@@ -31,8 +41,20 @@ type Rule struct {
 	// - defaults to 301 redirect
 	//
 	Status int
+
+	// Force is true for rules whose status ends with "!", which apply even
+	// to paths with content, shadowing it. Parse only accepts them with
+	// WithAllowForced, gateways check it before serving content.
+	Force bool `json:",omitempty"`
+
+	// Line is the line of the rule in the parsed file, or zero if the rule
+	// wasn't parsed. It isn't part of the rule's JSON encoding or Hash.
+	Line int `json:"-"`
 }
 
+// Rules is an ordered list of rules, the first matching rule applies.
+type Rules []Rule
+
 // IsRewrite returns true if the rule represents a rewrite (status 200).
 func (r *Rule) IsRewrite() bool {
 	return r.Status == 200
@@ -50,9 +72,13 @@ func (r *Rule) IsProxy() bool {
 
 // MatchAndExpandPlaceholders expands placeholders in `r.To` and returns true if the provided path matches.
 // Otherwise it returns false.
+//
+// When `r.From` uses the same placeholder name more than once, the value captured last wins.
+// Paths whose captured values would put control characters in `r.To`, or turn a path `r.To` into a
+// protocol-relative URL like `//example.com`, don't match.
 func (r *Rule) MatchAndExpandPlaceholders(urlPath string) bool {
 	// get rule.From, trim trailing slash, ...
-	fromPath := urlpath.New(strings.TrimSuffix(r.From, "/"))
+	fromPath := urlpattern.Compile(r.From)
 	match, ok := fromPath.Match(urlPath)
 
 	if !ok {
@@ -60,27 +86,63 @@ func (r *Rule) MatchAndExpandPlaceholders(urlPath string) bool {
 	}
 
 	// We have a match!  Perform substitution and return the updated rule
-	toPath := r.To
-	toPath = replacePlaceholders(toPath, match)
-	toPath = replaceSplat(toPath, match)
-
-	r.To = toPath
+	to := expandPlaceholders(r.To, match)
+	if unsafeDestination(r.To, to) {
+		return false
+	}
+	r.To = to
 
 	return true
 }
 
-func replacePlaceholders(to string, match urlpath.Match) string {
-	if len(match.Params) > 0 {
-		for key, value := range match.Params {
-			to = strings.ReplaceAll(to, ":"+key, value)
-		}
+func expandPlaceholders(to string, match urlpath.Match) string {
+	// "::" is a literal colon, so the text around it is expanded separately
+	i := urlpattern.EscapesStart(to)
+	if !strings.Contains(to[i:], "::") {
+		return expandChunk(to, match)
+	}
+	chunks := strings.Split(to[i:], "::")
+	for n, chunk := range chunks {
+		chunks[n] = expandChunk(chunk, match)
 	}
+	return expandChunk(to[:i], match) + strings.Join(chunks, ":")
+}
 
-	return to
+func expandChunk(to string, match urlpath.Match) string {
+	return placeholderReplacer(match).Replace(to)
 }
 
-func replaceSplat(to string, match urlpath.Match) string {
-	return strings.ReplaceAll(to, ":splat", match.Trailing)
+// placeholderReplacer returns a replacer of the placeholders captured by
+// match and the splat, in a single pass so captured values are never
+// expanded again, and longest names first so ":ab" isn't read as ":a"
+// followed by "b".
+func placeholderReplacer(match urlpath.Match) *strings.Replacer {
+	keys := make([]string, 0, len(match.Params)+1)
+	for key := range match.Params {
+		// a colon alone is literal
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if _, ok := match.Params["splat"]; !ok {
+		keys = append(keys, "splat")
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	oldnew := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		value, ok := match.Params[key]
+		if !ok {
+			value = match.Trailing
+		}
+		oldnew = append(oldnew, ":"+key, value)
+	}
+	return strings.NewReplacer(oldnew...)
 }
 
 // Must parse utility.
@@ -93,83 +155,301 @@ func Must(v []Rule, err error) []Rule {
 }
 
 // Parse the given reader.
-func Parse(r io.Reader) (rules []Rule, err error) {
-	limiter := &io.LimitedReader{R: r, N: MaxFileSizeInBytes + 1}
-	s := bufio.NewScanner(limiter)
-	for s.Scan() {
-		// detect when we've read one byte beyond MaxFileSizeInBytes
-		// and return user-friendly error
-		if limiter.N <= 0 {
-			return nil, fmt.Errorf("redirects file size cannot exceed %d bytes", MaxFileSizeInBytes)
+func Parse(r io.Reader, opts ...Option) ([]Rule, error) {
+	return ParseContext(context.Background(), r, opts...)
+}
+
+// ParseContext is like Parse, with ctx the parent of the span started with
+// the Tracer of WithTracer.
+func ParseContext(ctx context.Context, r io.Reader, opts ...Option) ([]Rule, error) {
+	c := newConfig(opts)
+	if c.tracer == nil && c.metrics == nil {
+		return parse(r, c)
+	}
+	var span Span
+	if c.tracer != nil {
+		_, span = c.tracer.StartSpan(ctx, "redirects.Parse")
+		defer span.End()
+	}
+	rules, err := parse(r, c)
+	if err != nil && c.metrics != nil {
+		c.metrics.ParseFailed(ErrorDiagnostic(err).Code)
+	}
+	if span != nil {
+		span.SetAttribute("redirects.rules", len(rules))
+		if err != nil {
+			span.SetAttribute("redirects.error", err.Error())
 		}
+	}
+	return rules, err
+}
 
-		line := strings.TrimSpace(s.Text())
+// parse parses r with c.
+func parse(r io.Reader, c *config) (rules []Rule, err error) {
+	if c.verify != nil {
+		if r, err = verifyFile(r, c); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.ruleCountHint > 0 {
+		rules = make([]Rule, 0, min(c.ruleCountHint, maxRules))
+	}
+
+	// destinations interns the parsed 'to' values
+	var destinations map[string]string
+
+	// digest is the digest of the rest of the file given by the integrity
+	// pragma, if any
+	var digest []byte
+
+	// reading one byte beyond the limit is enough to tell it's exceeded
+	lines := newLineReader(io.LimitReader(r, int64(c.maxFileSize)+1), c.maxLineLength)
+
+	// the integrity pragma is checked even when parsing fails, a file cut
+	// short or modified rarely parses and the pragma tells why
+	defer func() {
+		if lines.sum != nil && !errors.Is(err, ErrFileTooLarge) && !lines.verify(digest) {
+			rules, err = nil, &ParseError{Line: 1, Code: CodeInvalidIntegrity, Err: ErrIntegrityMismatch}
+		}
+		if err != nil && c.redact {
+			err = redactError(err)
+		}
+	}()
+
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			break
+		}
+
+		// detect when the line extends beyond the maximum file size and
+		// return user-friendly error, lines before it are validated as
+		// usual, so which error is reported only depends on the content
+		if lines.offset > c.maxFileSize {
+			return nil, fileTooLarge(c.maxFileSize)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := checkUTF8(line, lines.n); err != nil {
+			return nil, err
+		}
+
+		// work on the reader's buffer so empty lines and comments don't
+		// allocate, only the fields of rules are copied into strings
+		b := bytes.TrimSpace(line)
 
 		// empty
-		if line == "" {
+		if len(b) == 0 {
+			if c.logger != nil {
+				c.logger.Debug("skipped line", "line", lines.n, "reason", "empty")
+			}
 			continue
 		}
 
-		// comment
-		if strings.HasPrefix(line, "#") {
+		// comment, the first line can be the integrity pragma
+		if b[0] == '#' {
+			if lines.n == 1 {
+				lines.sum, digest, err = parseIntegrity(line, b)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if c.logger != nil {
+				c.logger.Debug("skipped line", "line", lines.n, "reason", "comment")
+			}
 			continue
 		}
 
 		// fields
-		fields := strings.Fields(line)
+		var fields [3][]byte
+		n, ok := splitFields(b, &fields)
 
 		// missing dst
-		if len(fields) <= 1 {
-			return nil, fmt.Errorf("missing 'to' path")
+		if n <= 1 {
+			return nil, &ParseError{Line: lines.n, Code: CodeMissingTo, Err: errors.New("missing 'to' path")}
 		}
 
-		if len(fields) > 3 {
-			return nil, fmt.Errorf("must match format 'from to [status]'")
+		if !ok {
+			return nil, &ParseError{Line: lines.n, Code: CodeTooManyFields, Err: errors.New("must match format 'from to [status]'")}
 		}
 
 		// implicit status
-		rule := Rule{Status: 301}
+		rule := Rule{Status: 301, Line: lines.n}
 
 		// from (must parse as an absolute path)
-		from, err := parseFrom(fields[0])
+		from, err := parseFrom(string(fields[0]))
 		if err != nil {
-			return nil, fmt.Errorf("parsing 'from': %w", err)
+			return nil, fieldError(line, fields[0], lines.n, CodeInvalidFrom, fmt.Errorf("parsing 'from': %w", err))
 		}
 		rule.From = from
 
-		// to (must parse as an absolute path or an URL)
-		to, err := parseTo(fields[1])
-		if err != nil {
-			return nil, fmt.Errorf("parsing 'to': %w", err)
+		if c.strict {
+			if err := strictFrom(from); err != nil {
+				return nil, fieldError(line, fields[0], lines.n, CodeInvalidFrom, fmt.Errorf("parsing 'from': %w", err))
+			}
+		}
+
+		// to (must parse as an absolute path or an URL), generated files
+		// repeat the same destinations a lot so they are interned, but for
+		// those with placeholders in the host, which depend on 'from' and
+		// are validated filled in
+		filled, dynamic := fillHostPlaceholders(fields[1], from)
+		to, ok := destinations[string(fields[1])]
+		if !ok || dynamic {
+			if !dynamic {
+				filled = string(fields[1])
+			}
+			to, err = parseTo(filled)
+			if err != nil {
+				return nil, fieldError(line, fields[1], lines.n, CodeInvalidTo, fmt.Errorf("parsing 'to': %w", err))
+			}
+			if code, err := c.checkDestination(to, dynamic); err != nil {
+				err := fieldError(line, fields[1], lines.n, code, fmt.Errorf("parsing 'to': %w", err))
+				if c.quarantine == nil {
+					return nil, err
+				}
+				c.quarantineRule(err)
+				continue
+			}
+			if dynamic {
+				to = string(fields[1])
+			} else {
+				if destinations == nil {
+					destinations = make(map[string]string)
+				}
+				// keyed like it's looked up, by the field as written,
+				// sharing the string with the value unless normalized
+				key := to
+				if key != string(fields[1]) {
+					key = string(fields[1])
+				}
+				destinations[key] = to
+			}
 		}
 		rule.To = to
 
+		if c.maxPlaceholders > 0 {
+			if field, err := checkPlaceholders(rule, c.maxPlaceholders); err != nil {
+				err := fieldError(line, fields[field-1], lines.n, CodeTooManyPlaceholders, err)
+				if c.quarantine == nil {
+					return nil, err
+				}
+				c.quarantineRule(err)
+				continue
+			}
+		}
+
 		// status
-		if len(fields) > 2 {
-			code, err := parseStatus(fields[2])
+		if n > 2 {
+			status := fields[2]
+			if c.allowForced {
+				status, rule.Force = bytes.CutSuffix(status, []byte{'!'})
+			}
+			code, ok := statusCode(status)
+			if !ok {
+				code, err = parseStatus(string(status))
+			}
+			if err == nil && (c.strict || c.gatewaySpec) && !ok {
+				err = fmt.Errorf("status must be three digits")
+			}
 			if err != nil {
-				return nil, fmt.Errorf("parsing status %q: %w", fields[2], err)
+				return nil, fieldError(line, fields[2], lines.n, CodeInvalidStatus, fmt.Errorf("parsing status %q: %w", fields[2], err))
+			}
+			if !ok && c.deprecated != nil && !c.suppresses(CodeDeprecatedStatus) {
+				c.deprecated(deprecatedStatus(line, status, lines.n, len(rules), code))
 			}
 
 			rule.Status = code
 		}
 
+		if c.gatewaySpec {
+			if field, err := checkSpec(rule); err != nil {
+				err := fieldError(line, fields[field-1], lines.n, CodeNonStandard, err)
+				if c.quarantine == nil {
+					return nil, err
+				}
+				c.quarantineRule(err)
+				continue
+			}
+		}
+
+		if c.maxRules > 0 && len(rules) == c.maxRules {
+			return nil, &ParseError{Line: lines.n, Code: CodeTooManyRules, Err: fmt.Errorf("file has more than %d rules", c.maxRules)}
+		}
 		rules = append(rules, rule)
 	}
 
-	err = s.Err()
-	if err != nil {
-		return nil, err
+	if c.maxComplexity > 0 {
+		if err := checkComplexity(rules, c.maxComplexity); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.strict {
+		for _, d := range lint(rules, c) {
+			if d.strict {
+				return nil, &ParseError{Line: d.Line, Code: d.Code, Err: errors.New(d.Message)}
+			}
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil, nil
 	}
 	return rules, nil
 }
 
+// maxRules is the most rules a file within MaxFileSizeInBytes can have, each
+// rule line being at least 4 bytes long ("/ /" and a line terminator).
+const maxRules = MaxFileSizeInBytes / 4
+
 // ParseString parses the given string.
-func ParseString(s string) ([]Rule, error) {
-	return Parse(strings.NewReader(s))
+func ParseString(s string, opts ...Option) ([]Rule, error) {
+	hint := WithRuleCountHint(strings.Count(s, "\n") + 1)
+	return Parse(strings.NewReader(s), append([]Option{hint}, opts...)...)
+}
+
+// ParseBytes parses the given bytes.
+func ParseBytes(b []byte, opts ...Option) ([]Rule, error) {
+	hint := WithRuleCountHint(bytes.Count(b, []byte{'\n'}) + 1)
+	return Parse(bytes.NewReader(b), append([]Option{hint}, opts...)...)
+}
+
+// splitFields splits line around runs of whitespace into fields, like
+// bytes.Fields, without allocating a slice. It returns the number of fields
+// stored and false if line has more fields than fit.
+func splitFields(line []byte, fields *[3][]byte) (n int, ok bool) {
+	for {
+		i := bytes.IndexFunc(line, isNotSpace)
+		if i < 0 {
+			return n, true
+		}
+		line = line[i:]
+		if n == len(fields) {
+			return n, false
+		}
+
+		j := bytes.IndexFunc(line, unicode.IsSpace)
+		if j < 0 {
+			j = len(line)
+		}
+		fields[n] = line[:j]
+		n++
+		line = line[j:]
+	}
+}
+
+func isNotSpace(r rune) bool {
+	return !unicode.IsSpace(r)
 }
 
 func parseFrom(s string) (string, error) {
+	if err := checkControl(s); err != nil {
+		return "", err
+	}
+
 	// enforce a single splat
 	fromSplats := strings.Count(s, "*")
 	if fromSplats > 0 {
@@ -193,37 +473,182 @@ func parseFrom(s string) (string, error) {
 	return s, nil
 }
 
+// checkPlaceholders makes sure neither From nor To of r have more than max
+// placeholders, it returns the field with too many.
+func checkPlaceholders(r Rule, max int) (int, error) {
+	// every placeholder but the splat takes a colon, only count them
+	// precisely when that's not enough to tell
+	if strings.Count(r.From, ":")+1 <= max && strings.Count(r.To, ":") <= max {
+		return fieldNone, nil
+	}
+	p := urlpattern.Compile(r.From)
+
+	n := 0
+	for _, seg := range p.Segments {
+		if seg.IsParam {
+			n++
+		}
+	}
+	if p.Trailing {
+		n++
+	}
+	if n > max {
+		return fieldFrom, fmt.Errorf("'from' has %d placeholders, at most %d are allowed", n, max)
+	}
+
+	tmpl := compileTemplate(r.To, p)
+	n = 0
+	for _, part := range tmpl.parts {
+		if part.placeholder {
+			n++
+		}
+	}
+	if n > max {
+		return fieldTo, fmt.Errorf("'to' has %d placeholders, at most %d are allowed", n, max)
+	}
+	return fieldNone, nil
+}
+
+// strictFrom rejects 'from' paths outside the documented grammar that
+// parseFrom tolerates.
+func strictFrom(s string) error {
+	// matching is done on the path only, these never match
+	if strings.ContainsAny(s, "?#") {
+		return fmt.Errorf("path cannot have a query or fragment")
+	}
+
+	// "/a*" is a literal asterisk, not a splat
+	if strings.HasSuffix(s, "*") && !strings.HasSuffix(s, "/*") {
+		return fmt.Errorf("asterisk must be a path segment of its own")
+	}
+	return nil
+}
+
+// checkDestination applies the destination policies of c to to, the parsed
+// To of a rule, with its host placeholders filled in if dynamic. It returns
+// the code of the violated policy.
+func (c *config) checkDestination(to string, dynamic bool) (string, error) {
+	switch {
+	case c.relativeOnly && !urlpattern.IsRelative(to):
+		return CodeDisallowedTo, errors.New("destination must be a path on the same site")
+	case c.httpsOnly && isHTTPURL(to):
+		return CodeDisallowedTo, errors.New("http:// destinations are not allowed, use https://")
+	case c.noIPFSURLs && isIPFSURL(to):
+		return CodeDisallowedTo, errors.New("ipfs:// and ipns:// destinations are not allowed")
+	case dynamic && (!c.dynamicProxyHosts || c.proxyHosts != nil || c.publicProxiesOnly):
+		return CodeDisallowedTo, errors.New("placeholders are not allowed in the host")
+	case dynamic:
+		// the other policies can't check hosts before they're filled in
+		return "", nil
+	}
+
+	if c.proxyHosts != nil {
+		if err := checkProxyHost(to, c.proxyHosts); err != nil {
+			return CodeDisallowedTo, err
+		}
+	}
+	if c.publicProxiesOnly {
+		if err := checkPublicProxy(to); err != nil {
+			return CodeDisallowedTo, err
+		}
+	}
+	if c.validateCID != nil {
+		if err := checkCID(to, c.validateCID); err != nil {
+			return CodeInvalidCID, err
+		}
+	}
+	return "", nil
+}
+
+// checkControl rejects the control characters in s, which would end up in
+// the headers or paths of responses.
+func checkControl(s string) error {
+	if i := controlIndex(s); i >= 0 {
+		return fmt.Errorf("control character %q is not allowed", s[i])
+	}
+	return nil
+}
+
 func parseTo(s string) (string, error) {
+	if err := checkControl(s); err != nil {
+		return "", err
+	}
+
 	// confirm value is within URL path spec
 	u, err := url.Parse(s)
 	if err != nil {
 		return "", err
 	}
 
+	// browsers resolve "//host/path" against the scheme of the page, so it's
+	// as absolute as "https://host/path" and held to the same rules
+	if urlpattern.IsProtocolRelative(s) {
+		if s[1] == '\\' {
+			return "", fmt.Errorf(`destination cannot begin with "/\", browsers read it as "//"`)
+		}
+		return normalizeProxyURL(s, u)
+	}
+
 	// if the value is  a patch attached to full URL, only allow safelisted schemes
 	if !strings.HasPrefix(s, "/") {
 		if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ipfs" && u.Scheme != "ipns" {
-			return "", fmt.Errorf("invalid URL scheme")
+			return "", unsupportedScheme(u.Scheme)
+		}
+		if u.Scheme == "http" || u.Scheme == "https" {
+			return normalizeProxyURL(s, u)
 		}
 	}
 
 	return s, nil
 }
 
+// statusCode is the fast path of parseStatus for the common case of a
+// supported three digit status, it doesn't allocate.
+func statusCode(b []byte) (int, bool) {
+	if len(b) != 3 {
+		return 0, false
+	}
+
+	code := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		code = code*10 + int(c-'0')
+	}
+	return code, isValidStatusCode(code)
+}
+
+// deprecatedStatus reports the status field of the n-th line, the i-th rule,
+// as superseded by code spelled as three digits.
+func deprecatedStatus(line, field []byte, n, i, code int) Diagnostic {
+	start := fieldOffset(line, field)
+	return Diagnostic{
+		Code:        CodeDeprecatedStatus,
+		Rule:        i,
+		Line:        n,
+		Column:      start + 1,
+		EndColumn:   start + len(field) + 1,
+		Related:     -1,
+		Message:     fmt.Sprintf("status %q is deprecated, use %d", field, code),
+		Replacement: strconv.Itoa(code),
+	}
+}
+
 // parseStatus returns the status code.
 func parseStatus(s string) (code int, err error) {
 	if strings.HasSuffix(s, "!") {
 		// See https://docs.netlify.com/routing/redirects/rewrites-proxies/#shadowing
-		return 0, fmt.Errorf("forced redirects (or \"shadowing\") are not supported")
+		return 0, fmt.Errorf("forced redirects (or \"shadowing\") are not allowed")
 	}
 
 	code, err = strconv.Atoi(s)
 	if err != nil {
-		return 0, err
+		return 0, unsupportedStatus(s, errors.New("status code must be a number"))
 	}
 
 	if !isValidStatusCode(code) {
-		return 0, fmt.Errorf("status code %d is not supported", code)
+		return 0, unsupportedStatus(s, fmt.Errorf("status code %d is not supported", code))
 	}
 
 	return code, nil
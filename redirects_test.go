@@ -3,10 +3,16 @@ package redirects
 import (
 	"bufio"
 	"bytes"
+	"fmt"
+	"io"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
+	"unsafe"
 
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -71,6 +77,25 @@ func TestParse(t *testing.T) {
 		require.ErrorContains(t, err, "forced redirects")
 	})
 
+	t.Run("with allowed force", func(t *testing.T) {
+		rules, err := ParseString("/home / 301!\n/app/* /index.html 200!\n/a /b 302", WithAllowForced())
+		require.NoError(t, err)
+		require.Equal(t, []Rule{
+			{From: "/home", To: "/", Status: 301, Force: true, Line: 1},
+			{From: "/app/*", To: "/index.html", Status: 200, Force: true, Line: 2},
+			{From: "/a", To: "/b", Status: 302, Line: 3},
+		}, rules)
+
+		var b strings.Builder
+		Rules(rules).WriteTo(&b)
+		require.Equal(t, "/home / 301!\n/app/* /index.html 200!\n/a /b 302\n", b.String())
+
+		_, err = ParseString("/home / !", WithAllowForced())
+		require.EqualError(t, err, `line 1: parsing status "!": status code must be a number`)
+		_, err = ParseString("/home / 301!!", WithAllowForced())
+		require.ErrorContains(t, err, "forced redirects")
+	})
+
 	t.Run("with illegal code", func(t *testing.T) {
 		_, err := Parse(strings.NewReader(`
 		/home / 42
@@ -98,6 +123,225 @@ func TestParse(t *testing.T) {
 		require.Error(t, err)
 		require.ErrorContains(t, err, "redirects file size cannot exceed")
 	})
+
+	t.Run("with too long line", func(t *testing.T) {
+		_, err := ParseString("/a /b\n/from /"+strings.Repeat("x", 100)+" 301\n", WithMaxLineLength(64))
+
+		var tooLong *LineTooLongError
+		require.ErrorAs(t, err, &tooLong)
+		require.Equal(t, 2, tooLong.Line)
+	})
+
+	t.Run("with a line longer than the default limit", func(t *testing.T) {
+		line := "/from /" + strings.Repeat("x", DefaultMaxLineLength)
+		_, err := ParseString(line)
+		var tooLong *LineTooLongError
+		require.ErrorAs(t, err, &tooLong)
+		require.Equal(t, DefaultMaxLineLength, tooLong.Max)
+
+		_, err = ParseString(line, WithMaxLineLength(0))
+		require.NoError(t, err)
+	})
+
+	t.Run("with a single line as large as the file limit", func(t *testing.T) {
+		_, err := ParseString("/from /" + strings.Repeat("x", MaxFileSizeInBytes))
+		require.ErrorContains(t, err, "redirects file size cannot exceed")
+	})
+
+	t.Run("with crlf and no trailing newline", func(t *testing.T) {
+		rules, err := ParseString("/a /b 302\r\n/c /d\r\n/e /f 200")
+		require.NoError(t, err)
+		require.Equal(t, []Rule{
+			{From: "/a", To: "/b", Status: 302, Line: 1},
+			{From: "/c", To: "/d", Status: 301, Line: 2},
+			{From: "/e", To: "/f", Status: 200, Line: 3},
+		}, rules)
+	})
+}
+
+func TestParseProxyHost(t *testing.T) {
+	for _, tc := range []struct {
+		to, want string
+	}{
+		{"https://example.com/a", "https://example.com/a"},
+		{"https://Example.COM./a", "https://Example.COM./a"},
+		{"http://127.0.0.1:8080", "http://127.0.0.1:8080"},
+		{"http://[::1]:8080/a", "http://[::1]:8080/a"},
+		{"https://münchen.de/:splat", "https://xn--mnchen-3ya.de/:splat"},
+		{"https://user@Bücher.example:8443/a?b#c", "https://user@xn--bcher-kva.example:8443/a?b#c"},
+		{"https://m%C3%BCnchen.de/", "https://xn--mnchen-3ya.de/"},
+		{"//bücher.example/a", "//xn--bcher-kva.example/a"},
+	} {
+		t.Run(tc.to, func(t *testing.T) {
+			rules, err := ParseString("/a/* " + tc.to + " 200")
+			require.NoError(t, err)
+			require.Equal(t, tc.want, rules[0].To)
+		})
+	}
+
+	for _, tc := range []struct {
+		to, err string
+	}{
+		{"https:///a", "URL must have a host"},
+		{"https://exa_mple.com", `invalid host "exa_mple.com": label "exa_mple" has invalid character '_'`},
+		{"https://-example.com", `invalid host "-example.com": label "-example" begins or ends with a hyphen`},
+		{"https://example..com", `invalid host "example..com": empty label`},
+		{"https://" + strings.Repeat("a", 64) + ".com", "is longer than 63 bytes"},
+		{"https://" + strings.Repeat("a.", 127) + "com", "is longer than 253 bytes"},
+		{"http://[fe80::1%25en0/a", "missing ']' in host"},
+		{"///example.com/a", "URL must have a host"},
+		{"//exa_mple.com", `invalid host "exa_mple.com"`},
+		{`/\example.com/a`, `destination cannot begin with "/\", browsers read it as "//"`},
+	} {
+		t.Run(tc.to, func(t *testing.T) {
+			_, err := ParseString("/a " + tc.to)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestParseRelativeOnly(t *testing.T) {
+	rules, err := ParseString("/a /b\n/c /d?e=f#g 200\n/ipfs/* /ipfs/:splat", WithRelativeOnly())
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+
+	for _, to := range []string{"https://example.com/", "ipfs://bafy", "//example.com/a"} {
+		_, err := ParseString("/a /b\n/c "+to, WithRelativeOnly())
+		require.EqualError(t, err, "line 2: parsing 'to': destination must be a path on the same site")
+		require.Equal(t, CodeDisallowedTo, ErrorDiagnostic(err).Code)
+	}
+}
+
+func TestParseWithoutIPFSURLs(t *testing.T) {
+	rules, err := ParseString("/a /ipfs/bafy/a 200\n/b https://example.com\n/c //example.com", WithoutIPFSURLs())
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+
+	for _, to := range []string{"ipfs://bafy/a", "ipns://example.com/", "IPFS://bafy"} {
+		_, err := ParseString("/a /b\n/c "+to, WithoutIPFSURLs())
+		require.EqualError(t, err, "line 2: parsing 'to': ipfs:// and ipns:// destinations are not allowed")
+		require.Equal(t, CodeDisallowedTo, ErrorDiagnostic(err).Code)
+	}
+}
+
+func TestParseHTTPSOnlyProxies(t *testing.T) {
+	rules, err := ParseString("/a /b\n/c https://example.com 200\n/d //example.com\n/e ipfs://bafy", WithHTTPSOnlyProxies())
+	require.NoError(t, err)
+	require.Len(t, rules, 4)
+
+	for _, to := range []string{"http://example.com/", "HTTP://example.com:8080/a"} {
+		_, err := ParseString("/a /b\n/c "+to+" 200", WithHTTPSOnlyProxies())
+		require.EqualError(t, err, "line 2: parsing 'to': http:// destinations are not allowed, use https://")
+		require.Equal(t, CodeDisallowedTo, ErrorDiagnostic(err).Code)
+	}
+}
+
+func TestParseQuarantine(t *testing.T) {
+	file := "/a /b\n/c http://example.com/x?a=b 200\n/d https://example.com/ 200\n/e/:a/:b/:c /f\n/g /h\n"
+
+	var quarantined []Diagnostic
+	rules, err := ParseString(file, WithHTTPSOnlyProxies(), WithMaxPlaceholders(2), WithQuarantine(func(d Diagnostic) {
+		quarantined = append(quarantined, d)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"/a", "/d", "/g"}, []string{rules[0].From, rules[1].From, rules[2].From})
+	require.Len(t, rules, 3)
+
+	require.Len(t, quarantined, 2)
+	require.Equal(t, Diagnostic{
+		Severity:  SeverityWarning,
+		Code:      CodeDisallowedTo,
+		Line:      2,
+		Column:    4,
+		EndColumn: 28,
+		Rule:      -1,
+		Related:   -1,
+		Message:   "parsing 'to': http:// destinations are not allowed, use https://",
+	}, quarantined[0])
+	require.Equal(t, CodeTooManyPlaceholders, quarantined[1].Code)
+	require.Equal(t, 4, quarantined[1].Line)
+
+	// syntax errors still fail
+	_, err = ParseString("/a /b 999", WithQuarantine(func(Diagnostic) {}))
+	require.Error(t, err)
+}
+
+func TestParseControlCharacters(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		err  string
+	}{
+		{"/a\x00 /b", "line 1: parsing 'from': control character '\\x00' is not allowed"},
+		{"/a /b\x1b[2J", "line 1: parsing 'to': control character '\\x1b' is not allowed"},
+		{"/a https://example.com/\x7f", "line 1: parsing 'to': control character '\\x7f' is not allowed"},
+	} {
+		_, err := ParseString(tc.line)
+		require.EqualError(t, err, tc.err)
+	}
+}
+
+func TestParseMaxPlaceholders(t *testing.T) {
+	_, err := ParseString("/:a/:b/* /:a/:b/:splat", WithMaxPlaceholders(3))
+	require.NoError(t, err)
+
+	_, err = ParseString("/:a/:b/:c/* /x", WithMaxPlaceholders(3))
+	require.EqualError(t, err, "line 1: 'from' has 4 placeholders, at most 3 are allowed")
+
+	_, err = ParseString("/:a /:a/:a/:a/:a", WithMaxPlaceholders(3))
+	require.EqualError(t, err, "line 1: 'to' has 4 placeholders, at most 3 are allowed")
+
+	t.Run("default", func(t *testing.T) {
+		from := strings.Repeat("/:p", DefaultMaxPlaceholders+1)
+		_, err := ParseString(from + " /x")
+		require.ErrorContains(t, err, "at most 32 are allowed")
+
+		_, err = ParseString(from+" /x", WithMaxPlaceholders(0))
+		require.NoError(t, err)
+	})
+}
+
+func TestParseStrict(t *testing.T) {
+	for _, tc := range []struct {
+		rule string
+		err  string
+	}{
+		{"/a /b 0301", `line 1: parsing status "0301": status must be three digits`},
+		{"/a /b +301", `line 1: parsing status "+301": status must be three digits`},
+		{"/a?x=1 /b", "line 1: parsing 'from': path cannot have a query or fragment"},
+		{"/a#x /b", "line 1: parsing 'from': path cannot have a query or fragment"},
+		{"/a* /b", "line 1: parsing 'from': asterisk must be a path segment of its own"},
+		{"/a/:x /b/:y", `line 1: placeholder ":y" is not defined in 'from', write "::y" for a literal colon`},
+	} {
+		t.Run(tc.rule, func(t *testing.T) {
+			_, err := ParseString(tc.rule)
+			require.NoError(t, err, "only strict mode rejects it")
+
+			_, err = ParseString(tc.rule, WithStrict())
+			require.EqualError(t, err, tc.err)
+		})
+	}
+
+	rules, err := ParseString("/a /b\n/c/* /d/:splat 302\n/e/:x /f?x=:x#top 200", WithStrict())
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+}
+
+func TestParseDeprecationWarnings(t *testing.T) {
+	var warnings []Diagnostic
+	rules, err := ParseString("/a /b 301\n/c /d 0302\n/e /f  +200", WithDeprecationWarnings(func(d Diagnostic) {
+		warnings = append(warnings, d)
+	}))
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	require.Equal(t, []Diagnostic{
+		{Code: CodeDeprecatedStatus, Rule: 1, Line: 2, Column: 7, EndColumn: 11, Related: -1, Message: `status "0302" is deprecated, use 302`, Replacement: "302"},
+		{Code: CodeDeprecatedStatus, Rule: 2, Line: 3, Column: 8, EndColumn: 12, Related: -1, Message: `status "+200" is deprecated, use 200`, Replacement: "200"},
+	}, warnings)
+
+	_, err = ParseString("/c /d 0302", WithStrict(), WithDeprecationWarnings(func(d Diagnostic) {
+		t.Fatal("strict mode rejects deprecated spellings")
+	}))
+	require.Error(t, err)
 }
 
 func FuzzParse(f *testing.F) {
@@ -195,3 +439,272 @@ func FuzzParse(f *testing.F) {
 		}
 	})
 }
+
+func FuzzMatchAndExpandPlaceholders(f *testing.F) {
+	testcases := []struct{ rule, path, query string }{
+		{"/a /b", "/a", ""},
+		{"/posts/:year/:title /articles/:year/:title 301", "/posts/2024/hello", "ref=feed"},
+		{"/splat/* /redirected/:splat 301", "/splat/a/b/c", "a=1&b=2"},
+		{"/:lang/* /:lang/index.html 200", "/en/docs/", ""},
+		{"/a/:x /b/::x/:x 302", "/a/y", ""},
+		{"/a/:x/:x /b/:x", "/a/1/2", ""},
+		{"/from/* https://example.com/:splat 200", "/from/%C4%85", "q=%20"},
+		{"/ą /ę 301", "/ą", ""},
+	}
+	for _, tc := range testcases {
+		f.Add(tc.rule, tc.path, tc.query)
+	}
+	f.Fuzz(func(t *testing.T, line, path, query string) {
+		rules, err := ParseString(line)
+		if err != nil || len(rules) != 1 {
+			t.Skip()
+		}
+		// like on a gateway, the path is the decoded path of the request
+		u, err := url.ParseRequestURI(path + "?" + query)
+		if err != nil || u.RawQuery != query {
+			t.Skip()
+		}
+
+		orig := rules[0]
+		rule := orig
+		matched := rule.MatchAndExpandPlaceholders(u.Path)
+
+		// compiled rules expand placeholders the same way
+		compiled, ok := Compile(rules).Match(u.Path)
+		require.Equal(t, matched, ok)
+		if !matched {
+			require.Equal(t, orig, rule, "rules are unchanged without a match")
+			return
+		}
+		require.Equal(t, rule, compiled)
+
+		// the query takes no part in matching or expanding
+		again := orig
+		require.True(t, again.MatchAndExpandPlaceholders(u.Path))
+		require.Equal(t, rule, again)
+
+		require.False(t, hasControl(rule.To), "destination %q has control characters", rule.To)
+		if urlpattern.IsRelative(orig.To) {
+			require.True(t, strings.HasPrefix(rule.To, "/"), "destination %q isn't a path", rule.To)
+			p, err := url.Parse((&url.URL{Path: rule.To}).EscapedPath())
+			require.NoError(t, err)
+			require.Equal(t, rule.To, p.Path)
+		}
+
+		// placeholders can only remain if the path or a literal colon put
+		// them back
+		if !strings.Contains(u.Path, ":") && !strings.Contains(orig.To, "::") {
+			for _, slot := range urlpattern.Placeholders(urlpattern.Compile(orig.From)) {
+				if slot.Name != "" {
+					require.NotContains(t, rule.To, ":"+slot.Name, "placeholder left unexpanded in %q", rule.To)
+				}
+			}
+		}
+	})
+}
+
+// benchmarkRules returns a _redirects file with n rules mixing the kinds of
+// rules found in real sites: static redirects, named placeholders, splats,
+// rewrites and a catch-all at the end.
+func benchmarkRules(n int) string {
+	var b strings.Builder
+	b.WriteString("# generated for benchmarks\n\n")
+	for i := 0; i < n-1; i++ {
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&b, "/old/page-%d /new/page-%d\n", i, i)
+		case 1:
+			fmt.Fprintf(&b, "/blog-%d/:year/:slug /posts/%d/:year/:slug 302\n", i, i)
+		case 2:
+			fmt.Fprintf(&b, "/docs-%d/* /documentation/%d/:splat 301\n", i, i)
+		case 3:
+			fmt.Fprintf(&b, "/app-%d/*  /index.html  200\n", i)
+		}
+	}
+	b.WriteString("/* /404.html 404\n")
+	return b.String()
+}
+
+func BenchmarkParse(b *testing.B) {
+	// 10k rules don't fit within MaxFileSizeInBytes, so parsing is only
+	// benchmarked up to 1k rules
+	for _, n := range []int{100, 1000} {
+		text := benchmarkRules(n)
+		b.Run(fmt.Sprintf("rules=%d", n), func(b *testing.B) {
+			b.SetBytes(int64(len(text)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := ParseString(text)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMatch(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		// build the rules without the size limit to reach realistic sizes of
+		// converted enterprise sites
+		rules := benchmarkRuleSlice(n)
+
+		for _, path := range []string{"/old/page-0", "/blog-1/2022/hello", "/not/found"} {
+			b.Run(fmt.Sprintf("rules=%d/path=%s", n, path), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					for _, rule := range rules {
+						if rule.MatchAndExpandPlaceholders(path) {
+							break
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCompilePattern(t *testing.T) {
+	t.Run("concurrent use", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					r := Rule{From: fmt.Sprintf("/c%d/:x", j), To: "/d/:x"}
+					assert.True(t, r.MatchAndExpandPlaceholders(fmt.Sprintf("/c%d/%d", j, i)))
+					assert.Equal(t, fmt.Sprintf("/d/%d", i), r.To)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
+
+func TestParseBytes(t *testing.T) {
+	rules, err := ParseBytes([]byte("# comment\n/a /b\n\n/c /d 302"))
+	require.NoError(t, err)
+	require.Equal(t, []Rule{
+		{From: "/a", To: "/b", Status: 301, Line: 2},
+		{From: "/c", To: "/d", Status: 302, Line: 4},
+	}, rules)
+
+	rules, err = ParseBytes([]byte("# only a comment\n"))
+	require.NoError(t, err)
+	require.Nil(t, rules)
+}
+
+func TestParseRuleCountHint(t *testing.T) {
+	rules, err := Parse(strings.NewReader("/a /b\n/c /d"), WithRuleCountHint(10))
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.Equal(t, 10, cap(rules))
+
+	t.Run("is bounded", func(t *testing.T) {
+		rules, err := Parse(strings.NewReader("/a /b"), WithRuleCountHint(1<<40))
+		require.NoError(t, err)
+		require.Equal(t, maxRules, cap(rules))
+	})
+}
+
+func TestParseInternsDestinations(t *testing.T) {
+	rules, err := ParseString("/a /index.html 200\n/b /index.html 200\n/c /other.html")
+	require.NoError(t, err)
+	require.Equal(t, "/index.html", rules[0].To)
+	require.True(t, unsafe.StringData(rules[0].To) == unsafe.StringData(rules[1].To))
+	require.False(t, unsafe.StringData(rules[0].To) == unsafe.StringData(rules[2].To))
+
+	// normalized destinations are interned too
+	rules, err = ParseString("/a https://bücher.example/ 302\n/b https://bücher.example/ 302")
+	require.NoError(t, err)
+	require.Equal(t, "https://xn--bcher-kva.example/", rules[0].To)
+	require.True(t, unsafe.StringData(rules[0].To) == unsafe.StringData(rules[1].To))
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestParseSizeLimit(t *testing.T) {
+	// fileOfSize returns valid rules totalling size bytes, ending with the
+	// given terminator
+	fileOfSize := func(size int, end string) string {
+		var b strings.Builder
+		for b.Len()+len("/a /b\n")+len("/last /") < size {
+			b.WriteString("/a /b\n")
+		}
+		pad := size - b.Len() - len("/last /") - len(end)
+		b.WriteString("/last /" + strings.Repeat("x", pad) + end)
+		require.Equal(t, size, b.Len())
+		return b.String()
+	}
+
+	for _, tc := range []struct {
+		name string
+		file string
+		ok   bool
+	}{
+		{"exactly at limit", fileOfSize(MaxFileSizeInBytes, ""), true},
+		{"exactly at limit with newline", fileOfSize(MaxFileSizeInBytes, "\n"), true},
+		{"exactly at limit with crlf", fileOfSize(MaxFileSizeInBytes, "\r\n"), true},
+		{"one byte over", fileOfSize(MaxFileSizeInBytes+1, ""), false},
+		{"one byte over by the newline", fileOfSize(MaxFileSizeInBytes, "") + "\n", false},
+		{"one byte over by an empty line", fileOfSize(MaxFileSizeInBytes, "\n") + "\n", false},
+		{"far over", fileOfSize(3*MaxFileSizeInBytes, "\n"), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &countingReader{r: strings.NewReader(tc.file)}
+			_, err := Parse(r)
+			if tc.ok {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, "redirects file size cannot exceed")
+			}
+			require.LessOrEqual(t, r.n, MaxFileSizeInBytes+1)
+		})
+	}
+
+	t.Run("errors before the limit win", func(t *testing.T) {
+		_, err := ParseString("/a\n" + fileOfSize(2*MaxFileSizeInBytes, ""))
+		require.ErrorContains(t, err, "missing 'to' path")
+	})
+
+	t.Run("errors on the line crossing the limit lose", func(t *testing.T) {
+		file := fileOfSize(MaxFileSizeInBytes-5, "\n") + "/bad-line-crossing-the-limit\n"
+		_, err := ParseString(file)
+		require.ErrorContains(t, err, "redirects file size cannot exceed")
+	})
+
+	t.Run("too long line crossing the limit", func(t *testing.T) {
+		file := fileOfSize(MaxFileSizeInBytes-100, "\n") + "/a /" + strings.Repeat("x", 1000)
+		_, err := ParseString(file, WithMaxLineLength(500))
+		require.ErrorContains(t, err, "redirects file size cannot exceed")
+
+		file = fileOfSize(MaxFileSizeInBytes-2000, "\n") + "/a /" + strings.Repeat("x", 1000)
+		_, err = ParseString(file, WithMaxLineLength(500))
+		var tooLong *LineTooLongError
+		require.ErrorAs(t, err, &tooLong)
+	})
+}
+
+func TestStatusCode(t *testing.T) {
+	for _, s := range []string{"200", "301", "302", "303", "307", "308", "404", "410", "451", "999", "42", "0301", "+301", "301!", "3o1", "", "abc"} {
+		// the fast path may leave unusual spellings to parseStatus, but
+		// must agree with it otherwise
+		want, err := parseStatus(s)
+		code, ok := statusCode([]byte(s))
+		if ok {
+			require.NoError(t, err, s)
+			require.Equal(t, want, code, s)
+		}
+	}
+}
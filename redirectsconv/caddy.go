@@ -0,0 +1,108 @@
+package redirectsconv
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// ExportCaddy converts rules into a Caddyfile snippet named redirects, for
+// sites served by Caddy to apply them with "import redirects" in their site
+// block, after the root directive:
+//
+//   - redirects become redir directives
+//   - rewrites become rewrite directives, the site's file_server then serves
+//     the content at To
+//   - 4xx rules serve the content at To with their status, with a
+//     handle_errors block for a final catch-all rule like /* /404.html 404
+//
+// Rules apply in order, to paths without files unless they're forced, and
+// placeholders and splats are captured with path_regexp matchers. Rewrites to
+// URLs, which proxy requests, are skipped and reported with a diagnostic.
+func ExportCaddy(rules redirects.Rules) ([]byte, []redirects.Diagnostic) {
+	var matchers, handlers, errs bytes.Buffer
+	var diags []redirects.Diagnostic
+	for i, rule := range rules {
+		name := "r" + strconv.Itoa(i+1)
+		p := urlpattern.Compile(rule.From)
+		_, static := urlpattern.Static(p)
+
+		var groups map[string]int
+		if !static {
+			_, groups = fromRegexp(rule.From)
+		}
+		to, err := mapPlaceholders(caddyEscaper.Replace(rule.To), p, func(placeholder string) string {
+			if static {
+				return ""
+			}
+			return fmt.Sprintf("{re.%s.%d}", name, groups[placeholder])
+		}, ":")
+		if err != nil {
+			diags = append(diags, exportWarning(rule, i, "skipped: %v", err))
+			continue
+		}
+		to = caddyToken(to)
+
+		isError := rule.Status >= 400
+		catchAll := rule.From == "/*" && isError && i == len(rules)-1
+		switch {
+		case rule.Status == 200 && !urlpattern.IsRelative(rule.To):
+			diags = append(diags, exportWarning(rule, i, "skipped, proxying to %s isn't supported", rule.To))
+			continue
+		case isError && !urlpattern.IsRelative(rule.To):
+			diags = append(diags, exportWarning(rule, i, "skipped, status %d needs a page on the site", rule.Status))
+			continue
+		case catchAll:
+			fmt.Fprintf(&errs, "\thandle_errors %d {\n\t\trewrite * %s\n\t\tfile_server {\n\t\t\tstatus %d\n\t\t}\n\t}\n", rule.Status, to, rule.Status)
+			continue
+		}
+
+		fmt.Fprintf(&matchers, "\t@%s {\n", name)
+		if static {
+			fmt.Fprintf(&matchers, "\t\tpath %s\n", caddyToken(caddyEscaper.Replace(rule.From)))
+		} else {
+			pattern, _ := fromRegexp(rule.From)
+			fmt.Fprintf(&matchers, "\t\tpath_regexp %s %s\n", name, caddyToken(pattern))
+		}
+		if !rule.Force {
+			matchers.WriteString("\t\tnot file\n")
+		}
+		matchers.WriteString("\t}\n")
+
+		fmt.Fprintf(&handlers, "\t\thandle @%s {\n", name)
+		switch {
+		case rule.Status >= 300 && rule.Status < 400:
+			fmt.Fprintf(&handlers, "\t\t\tredir %s %d\n", to, rule.Status)
+		case rule.Status == 200:
+			fmt.Fprintf(&handlers, "\t\t\trewrite * %s\n", to)
+		default:
+			fmt.Fprintf(&handlers, "\t\t\trewrite * %s\n\t\t\tfile_server {\n\t\t\t\tstatus %d\n\t\t\t}\n", to, rule.Status)
+		}
+		handlers.WriteString("\t\t}\n")
+	}
+
+	var b bytes.Buffer
+	b.WriteString("(redirects) {\n")
+	b.Write(matchers.Bytes())
+	if handlers.Len() > 0 {
+		fmt.Fprintf(&b, "\troute {\n%s\t}\n", handlers.Bytes())
+	}
+	b.Write(errs.Bytes())
+	b.WriteString("}\n")
+	return b.Bytes(), diags
+}
+
+// caddyEscaper escapes the braces Caddy reads as placeholders.
+var caddyEscaper = strings.NewReplacer("{", `\{`, "}", `\}`)
+
+// caddyToken returns s as a Caddyfile token, quoted if needed.
+func caddyToken(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'`#") {
+		return s
+	}
+	return strconv.Quote(s)
+}
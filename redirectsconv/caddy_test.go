@@ -0,0 +1,70 @@
+package redirectsconv
+
+import (
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportCaddy(t *testing.T) {
+	rules := redirects.Must(redirects.ParseString(`
+/old               /new                   301
+/blog/:year/:slug  /posts/:year/:slug     302
+/app/*             /app/index.html        200
+/api/*             https://api.example.com/:splat 200
+/gone              /410.html              410
+/*                 /404.html              404
+`))
+	caddyfile, diags := ExportCaddy(rules)
+	require.Equal(t, `(redirects) {
+	@r1 {
+		path /old
+		not file
+	}
+	@r2 {
+		path_regexp r2 ^/blog/([^/]*)/([^/]*)$
+		not file
+	}
+	@r3 {
+		path_regexp r3 ^/app/(.*)$
+		not file
+	}
+	@r5 {
+		path /gone
+		not file
+	}
+	route {
+		handle @r1 {
+			redir /new 301
+		}
+		handle @r2 {
+			redir /posts/{re.r2.1}/{re.r2.2} 302
+		}
+		handle @r3 {
+			rewrite * /app/index.html
+		}
+		handle @r5 {
+			rewrite * /410.html
+			file_server {
+				status 410
+			}
+		}
+	}
+	handle_errors 404 {
+		rewrite * /404.html
+		file_server {
+			status 404
+		}
+	}
+}
+`, string(caddyfile))
+
+	require.Len(t, diags, 1)
+	require.Equal(t, "line 5: skipped, proxying to https://api.example.com/:splat isn't supported", diags[0].String())
+
+	forced := redirects.Rules{{From: "/a b", To: "/{c}", Status: 302, Force: true}}
+	caddyfile, _ = ExportCaddy(forced)
+	require.Contains(t, string(caddyfile), "\t\tpath \"/a b\"\n\t}\n")
+	require.Contains(t, string(caddyfile), `redir /\{c\} 302`)
+}
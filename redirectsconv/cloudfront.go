@@ -0,0 +1,76 @@
+package redirectsconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// ExportCloudFront converts rules into the JavaScript of a CloudFront
+// Functions viewer request handler, for sites mirrored behind CloudFront to
+// redirect and rewrite at the edge.
+//
+// Redirects respond with their status and rewrites change the URI of the
+// request, in the order of rules. CloudFront runs the function before
+// fetching content, the rules apply to paths with content too, as if they
+// were forced. 4xx rules, which CloudFront serves with custom error
+// responses, and rewrites to URLs are skipped and reported with a diagnostic.
+func ExportCloudFront(rules redirects.Rules) ([]byte, []redirects.Diagnostic) {
+	// entries holds the pattern, destination and status of the rules
+	entries := [][3]any{}
+	var diags []redirects.Diagnostic
+	for i, rule := range rules {
+		switch {
+		case rule.Status == 200 && !urlpattern.IsRelative(rule.To):
+			diags = append(diags, exportWarning(rule, i, "skipped, proxying to %s isn't supported", rule.To))
+			continue
+		case rule.Status != 200 && (rule.Status < 300 || rule.Status >= 400):
+			diags = append(diags, exportWarning(rule, i, "skipped, status %d needs a custom error response", rule.Status))
+			continue
+		}
+
+		p := urlpattern.Compile(rule.From)
+		pattern, groups := fromRegexp(rule.From)
+		to, err := mapPlaceholders(strings.ReplaceAll(rule.To, "$", "$$"), p, func(name string) string {
+			return "$" + strconv.Itoa(groups[name])
+		}, ":")
+		if err != nil {
+			diags = append(diags, exportWarning(rule, i, "skipped: %v", err))
+			continue
+		}
+		entries = append(entries, [3]any{pattern, to, rule.Status})
+	}
+	encoded, _ := json.Marshal(entries)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `var rules = %s;
+
+function handler(event) {
+  var request = event.request;
+  for (var i = 0; i < rules.length; i++) {
+    var m = new RegExp(rules[i][0]).exec(request.uri);
+    if (!m) {
+      continue;
+    }
+    var to = rules[i][1].replace(/\$(\d+|\$)/g, function (ref, n) {
+      return n === "$" ? "$" : m[n];
+    });
+    if (rules[i][2] === 200) {
+      request.uri = to;
+      return request;
+    }
+    return {
+      statusCode: rules[i][2],
+      headers: { location: { value: to } },
+    };
+  }
+  return request;
+}
+`, encoded)
+	return b.Bytes(), diags
+}
@@ -0,0 +1,53 @@
+package redirectsconv
+
+import (
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportCloudFront(t *testing.T) {
+	rules := redirects.Must(redirects.ParseString(`
+/old               /new?a=$1              301
+/blog/:year/:slug  /posts/:year/:slug     302
+/app/*             /app/index.html        200
+/api/*             https://api.example.com/:splat 200
+/*                 /404.html              404
+`))
+	js, diags := ExportCloudFront(rules)
+	require.Equal(t, `var rules = [["^/old$","/new?a=$$1",301],["^/blog/([^/]*)/([^/]*)$","/posts/$1/$2",302],["^/app/(.*)$","/app/index.html",200]];
+
+function handler(event) {
+  var request = event.request;
+  for (var i = 0; i < rules.length; i++) {
+    var m = new RegExp(rules[i][0]).exec(request.uri);
+    if (!m) {
+      continue;
+    }
+    var to = rules[i][1].replace(/\$(\d+|\$)/g, function (ref, n) {
+      return n === "$" ? "$" : m[n];
+    });
+    if (rules[i][2] === 200) {
+      request.uri = to;
+      return request;
+    }
+    return {
+      statusCode: rules[i][2],
+      headers: { location: { value: to } },
+    };
+  }
+  return request;
+}
+`, string(js))
+
+	var messages []string
+	for _, d := range diags {
+		require.Equal(t, redirects.CodeLossyConversion, d.Code)
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		"line 5: skipped, proxying to https://api.example.com/:splat isn't supported",
+		"line 6: skipped, status 404 needs a custom error response",
+	}, messages)
+}
@@ -0,0 +1,46 @@
+package redirectsconv
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNetlifyConformance parses the files of testdata/netlify, in the layout
+// of the fixtures of netlify-redirect-parser, and compares the normalized
+// rules with the expected output next to each file: name.txt holds the file
+// and name.json the rules, or {"error": "..."} with part of the message of
+// the expected error. Vectors from other implementations can be dropped in.
+func TestNetlifyConformance(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "netlify", "*.txt"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".txt")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			require.NoError(t, err)
+			expected, err := os.ReadFile(strings.TrimSuffix(input, ".txt") + ".json")
+			require.NoError(t, err)
+
+			rules, err := redirects.ParseBytes(src, redirects.WithAllowForced())
+			var want struct {
+				Error string `json:"error"`
+			}
+			if json.Unmarshal(expected, &want) == nil {
+				require.ErrorContains(t, err, want.Error)
+				return
+			}
+			require.NoError(t, err)
+			actual, err := json.Marshal(NormalizeNetlify(rules))
+			require.NoError(t, err)
+			require.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package redirectsconv
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// fastlyInlineLimit is the number of rules without placeholders or splat
+// ExportFastly inlines before suggesting an edge dictionary.
+const fastlyInlineLimit = 1000
+
+// ExportFastly converts rules into Fastly VCL subroutines, for gateways
+// fronted by Fastly to apply them at the edge: redirects_recv, to call in
+// vcl_recv, redirects_error in vcl_error and redirects_deliver in
+// vcl_deliver.
+//
+// Redirects respond with synthetic responses, rewrites and 4xx rules change
+// the URL of the request, 4xx rules their status too. Rules apply in order,
+// forced ones in the first pass, the others after the gateway responded 404,
+// restarting the request. Rewrites to URLs and rules whose paths would end
+// the VCL strings holding them are skipped and reported with a diagnostic,
+// like the rules past the first 1000 without placeholders or splat, which
+// would be looked up faster in an edge dictionary.
+func ExportFastly(rules redirects.Rules) ([]byte, []redirects.Diagnostic) {
+	var conditions bytes.Buffer
+	var diags []redirects.Diagnostic
+	statics := 0
+	unforced := false
+	for i, rule := range rules {
+		isRedirect := rule.Status >= 300 && rule.Status < 400
+		switch {
+		case rule.Status == 200 && !urlpattern.IsRelative(rule.To):
+			diags = append(diags, exportWarning(rule, i, "skipped, proxying to %s isn't supported", rule.To))
+			continue
+		case strings.Contains(rule.From, `"}`):
+			diags = append(diags, exportWarning(rule, i, "skipped, %q would end a VCL string", rule.From))
+			continue
+		case strings.Contains(rule.To, `"}`):
+			diags = append(diags, exportWarning(rule, i, "skipped, %q would end a VCL string", rule.To))
+			continue
+		}
+
+		p := urlpattern.Compile(rule.From)
+		key, static := urlpattern.Static(p)
+		pattern, groups := fromRegexp(rule.From)
+		to, err := mapPlaceholders(rule.To, p, func(name string) string {
+			return `"} re.group.` + strconv.Itoa(groups[name]) + ` {"`
+		}, ":")
+		if err != nil {
+			diags = append(diags, exportWarning(rule, i, "skipped: %v", err))
+			continue
+		}
+		to = strings.TrimSuffix(strings.TrimPrefix(`{"`+to+`"}`, `{""} `), ` {""}`)
+
+		var cond string
+		if !rule.Force {
+			cond = "req.http.X-Redirects-Not-Found && "
+			unforced = true
+		}
+		if static {
+			statics++
+			if statics == fastlyInlineLimit+1 {
+				diags = append(diags, exportWarning(rule, i, "rules without placeholders or splat past the first %d would be looked up faster in an edge dictionary", fastlyInlineLimit))
+			}
+			cond += fmt.Sprintf(`req.http.X-Redirects-Path == {"%s"}`, key)
+		} else {
+			// the regular expression matches last, re.group refers to it
+			cond += fmt.Sprintf(`req.http.X-Redirects-Path ~ {"%s"}`, pattern)
+		}
+
+		if conditions.Len() == 0 {
+			fmt.Fprintf(&conditions, "\tif (%s) {\n", cond)
+		} else {
+			fmt.Fprintf(&conditions, "\t} else if (%s) {\n", cond)
+		}
+		switch {
+		case isRedirect:
+			fmt.Fprintf(&conditions, "\t\tset req.http.X-Redirects-Status = \"%d\";\n\t\tset req.http.X-Redirects-Location = %s;\n\t\terror 618;\n", rule.Status, to)
+		case rule.Status == 200:
+			fmt.Fprintf(&conditions, "\t\tset req.url = %s;\n", to)
+		default:
+			fmt.Fprintf(&conditions, "\t\tset req.http.X-Redirects-Status = \"%d\";\n\t\tset req.url = %s;\n", rule.Status, to)
+		}
+	}
+	if conditions.Len() > 0 {
+		conditions.WriteString("\t}\n")
+	}
+
+	var b bytes.Buffer
+	b.WriteString("sub redirects_recv {\n\tif (req.restarts == 0) {\n\t\tset req.http.X-Redirects-Path = req.url.path;\n\t}\n")
+	b.Write(conditions.Bytes())
+	b.WriteString(`}
+
+sub redirects_error {
+	if (obj.status == 618) {
+		set obj.status = std.atoi(req.http.X-Redirects-Status);
+		set obj.http.Location = req.http.X-Redirects-Location;
+		return(deliver);
+	}
+}
+
+sub redirects_deliver {
+`)
+	if unforced {
+		b.WriteString("\tif (resp.status == 404 && req.restarts == 0) {\n\t\tset req.http.X-Redirects-Not-Found = \"1\";\n\t\trestart;\n\t}\n")
+	}
+	b.WriteString("\tif (req.http.X-Redirects-Status && !req.http.X-Redirects-Location) {\n\t\tset resp.status = std.atoi(req.http.X-Redirects-Status);\n\t}\n}\n")
+	return b.Bytes(), diags
+}
@@ -0,0 +1,98 @@
+package redirectsconv
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportFastly(t *testing.T) {
+	rules, err := redirects.ParseString(`
+/home              /                      301!
+/old               /new                   301
+/blog/:year/:slug  /posts/:year/:slug     302
+/app/*             /app/index.html        200
+/api/*             https://api.example.com/:splat 200
+/*                 /404.html              404
+`, redirects.WithAllowForced())
+	require.NoError(t, err)
+	vcl, diags := ExportFastly(rules)
+	require.Equal(t, `sub redirects_recv {
+	if (req.restarts == 0) {
+		set req.http.X-Redirects-Path = req.url.path;
+	}
+	if (req.http.X-Redirects-Path == {"/home"}) {
+		set req.http.X-Redirects-Status = "301";
+		set req.http.X-Redirects-Location = {"/"};
+		error 618;
+	} else if (req.http.X-Redirects-Not-Found && req.http.X-Redirects-Path == {"/old"}) {
+		set req.http.X-Redirects-Status = "301";
+		set req.http.X-Redirects-Location = {"/new"};
+		error 618;
+	} else if (req.http.X-Redirects-Not-Found && req.http.X-Redirects-Path ~ {"^/blog/([^/]*)/([^/]*)$"}) {
+		set req.http.X-Redirects-Status = "302";
+		set req.http.X-Redirects-Location = {"/posts/"} re.group.1 {"/"} re.group.2;
+		error 618;
+	} else if (req.http.X-Redirects-Not-Found && req.http.X-Redirects-Path ~ {"^/app/(.*)$"}) {
+		set req.url = {"/app/index.html"};
+	} else if (req.http.X-Redirects-Not-Found && req.http.X-Redirects-Path ~ {"^/(.*)$"}) {
+		set req.http.X-Redirects-Status = "404";
+		set req.url = {"/404.html"};
+	}
+}
+
+sub redirects_error {
+	if (obj.status == 618) {
+		set obj.status = std.atoi(req.http.X-Redirects-Status);
+		set obj.http.Location = req.http.X-Redirects-Location;
+		return(deliver);
+	}
+}
+
+sub redirects_deliver {
+	if (resp.status == 404 && req.restarts == 0) {
+		set req.http.X-Redirects-Not-Found = "1";
+		restart;
+	}
+	if (req.http.X-Redirects-Status && !req.http.X-Redirects-Location) {
+		set resp.status = std.atoi(req.http.X-Redirects-Status);
+	}
+}
+`, string(vcl))
+
+	var messages []string
+	for _, d := range diags {
+		require.Equal(t, redirects.CodeLossyConversion, d.Code)
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		"line 6: skipped, proxying to https://api.example.com/:splat isn't supported",
+	}, messages)
+}
+
+func TestExportFastlyEdgeDictionary(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i <= fastlyInlineLimit; i++ {
+		fmt.Fprintf(&b, "/old/%d /new/%d 301\n", i, i)
+	}
+	_, diags := ExportFastly(redirects.Must(redirects.ParseString(b.String())))
+	require.Len(t, diags, 1)
+	require.Equal(t, fastlyInlineLimit, diags[0].Rule)
+}
+
+func TestExportFastlyStrings(t *testing.T) {
+	// paths ending VCL long strings would inject the rest into the snippet
+	rules := redirects.Must(redirects.ParseString(`/a"}+req.http.X{" /b 301
+/c /d"}+req.http.X{" 301
+/e"}/:x /f/:x 301`))
+	vcl, diags := ExportFastly(rules)
+	require.NotContains(t, string(vcl), `"}+`)
+	require.Len(t, diags, 3)
+	for i, d := range diags {
+		require.Equal(t, i, d.Rule)
+		require.Contains(t, d.Message, "would end a VCL string")
+	}
+}
@@ -0,0 +1,169 @@
+package redirectsconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+)
+
+// FirebaseHosting is the hosting configuration of a site in a firebase.json
+// file.
+type FirebaseHosting struct {
+	Redirects []FirebaseRedirect `json:"redirects,omitempty"`
+	Rewrites  []FirebaseRewrite  `json:"rewrites,omitempty"`
+}
+
+// A FirebaseRedirect is an entry of the redirects of a firebase.json file.
+type FirebaseRedirect struct {
+	// Source is a glob pattern, Regex a regular expression, which rules
+	// can't represent.
+	Source string `json:"source,omitempty"`
+	Regex  string `json:"regex,omitempty"`
+
+	Destination string `json:"destination"`
+
+	// Type is the status, 301 if zero.
+	Type int `json:"type,omitempty"`
+}
+
+// A FirebaseRewrite is an entry of the rewrites of a firebase.json file.
+type FirebaseRewrite struct {
+	// Source is a glob pattern, Regex a regular expression, which rules
+	// can't represent.
+	Source string `json:"source,omitempty"`
+	Regex  string `json:"regex,omitempty"`
+
+	Destination string `json:"destination,omitempty"`
+
+	// Function, Run and DynamicLinks rewrite to backends rules can't
+	// represent.
+	Function     json.RawMessage `json:"function,omitempty"`
+	Run          json.RawMessage `json:"run,omitempty"`
+	DynamicLinks bool            `json:"dynamicLinks,omitempty"`
+}
+
+// ImportFirebase converts the redirects and rewrites of the site of a
+// firebase.json file into rules, the redirects first since Firebase applies
+// them first. Firebase applies redirects to paths with content too, the rules
+// only apply to paths without.
+//
+// Sources convert when their globs and parameters match whole segments, like
+// * and :slug, or the rest of the path, like ** and :path*, which become
+// placeholders and splats. Entries that can't be represented, such as those
+// with a regular expression or rewriting to a function, are skipped and
+// reported with a diagnostic, as are conversions that match slightly
+// different paths. An error is returned for invalid JSON and files
+// configuring several sites.
+func ImportFirebase(r io.Reader) (redirects.Rules, []redirects.Diagnostic, error) {
+	var file struct {
+		Hosting json.RawMessage `json:"hosting"`
+	}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, nil, err
+	}
+
+	var hosting FirebaseHosting
+	if raw := bytes.TrimSpace(file.Hosting); len(raw) > 0 && raw[0] == '[' {
+		var sites []FirebaseHosting
+		if err := json.Unmarshal(raw, &sites); err != nil {
+			return nil, nil, err
+		}
+		if len(sites) > 1 {
+			return nil, nil, fmt.Errorf("firebase.json configures %d sites, import them one at a time", len(sites))
+		}
+		if len(sites) == 1 {
+			hosting = sites[0]
+		}
+	} else if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &hosting); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var imp importer
+	for i, redirect := range hosting.Redirects {
+		status := redirect.Type
+		if status == 0 {
+			status = 301
+		}
+		imp.firebaseEntry(fmt.Sprintf("redirects[%d]", i), redirect.Source, redirect.Regex, redirect.Destination, status)
+	}
+	for i, rewrite := range hosting.Rewrites {
+		path := fmt.Sprintf("rewrites[%d]", i)
+		if len(rewrite.Function) > 0 || len(rewrite.Run) > 0 || rewrite.DynamicLinks {
+			imp.warn(0, -1, "%s: skipped, rewrites to functions, Cloud Run and Dynamic Links can't be represented", path)
+			continue
+		}
+		imp.firebaseEntry(path, rewrite.Source, rewrite.Regex, rewrite.Destination, 200)
+	}
+	return imp.rules, imp.diags, nil
+}
+
+// firebaseEntry converts the entry of a firebase.json file at path.
+func (imp *importer) firebaseEntry(path, source, regex, destination string, status int) {
+	if regex != "" {
+		imp.warn(0, -1, "%s: skipped, regular expressions can't be represented", path)
+		return
+	}
+	from, params, lossy, err := firebaseSource(source)
+	if err != nil {
+		imp.warn(0, -1, "%s: skipped: %v", path, err)
+		return
+	}
+	to, err := paramDestination(destination, params)
+	if err != nil {
+		imp.warn(0, -1, "%s: skipped: %v", path, err)
+		return
+	}
+	if imp.add(0, from, to, status) && lossy != "" {
+		imp.warn(0, len(imp.rules)-1, "%s: %s", path, lossy)
+	}
+}
+
+// firebaseSource converts source, a glob pattern with parameters, into a From
+// and the names of the placeholders its parameters capture, keyed by
+// parameter name. lossy describes how From matches different paths, if it
+// does.
+func firebaseSource(source string) (from string, params map[string]string, lossy string, err error) {
+	params = make(map[string]string)
+	segments := strings.Split(strings.TrimPrefix(source, "/"), "/")
+	globs := 0
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch {
+		case seg == "**" && last:
+			segments[i] = "*"
+			if i > 0 {
+				lossy = fmt.Sprintf("%q no longer matches", "/"+strings.Join(segments[:i], "/"))
+			}
+		case seg == "*":
+			globs++
+			segments[i] = ":glob" + strconv.Itoa(globs)
+		case strings.HasPrefix(seg, ":"):
+			name, modifier := seg[1:], ""
+			if strings.HasSuffix(name, "*") {
+				name, modifier = name[:len(name)-1], "*"
+			}
+			switch {
+			case name == "" || strings.IndexFunc(name, func(r rune) bool { return r > 0x7f || !isIdentByte(byte(r)) }) >= 0:
+				return "", nil, "", fmt.Errorf("parameter %s of source %q can't be represented", seg, source)
+			case modifier == "":
+				params[name] = name
+				segments[i] = ":" + name
+			case last:
+				params[name] = "splat"
+				segments[i] = "*"
+			default:
+				return "", nil, "", fmt.Errorf("parameter %s of source %q isn't last", seg, source)
+			}
+		case strings.ContainsAny(seg, "*?{}[]!:"):
+			return "", nil, "", fmt.Errorf("segment %q of source %q can't be represented", seg, source)
+		}
+	}
+	return "/" + strings.Join(segments, "/"), params, lossy, nil
+}
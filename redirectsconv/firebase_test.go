@@ -0,0 +1,60 @@
+package redirectsconv
+
+import (
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportFirebase(t *testing.T) {
+	rules, diags, err := ImportFirebase(strings.NewReader(`{
+  "hosting": {
+    "public": "dist",
+    "redirects": [
+      {"source": "/old", "destination": "/new"},
+      {"source": "/blog/:post*", "destination": "https://blog.example.com/:post*", "type": 302},
+      {"source": "/users/*/profile", "destination": "/profile", "type": 301},
+      {"source": "/firebase/:id", "destination": "/fb/:id", "type": 301},
+      {"regex": "^/a/(\\d+)$", "destination": "/b/:1"},
+      {"source": "**/*.php", "destination": "/"}
+    ],
+    "rewrites": [
+      {"source": "/api/**", "function": "api"},
+      {"source": "/app/**", "destination": "/app/index.html"},
+      {"source": "**", "destination": "/index.html"}
+    ]
+  }
+}`))
+	require.NoError(t, err)
+	require.Equal(t, redirects.Rules{
+		{From: "/old", To: "/new", Status: 301},
+		{From: "/blog/*", To: "https://blog.example.com/:splat", Status: 302},
+		{From: "/users/:glob1/profile", To: "/profile", Status: 301},
+		{From: "/firebase/:id", To: "/fb/:id", Status: 301},
+		{From: "/app/*", To: "/app/index.html", Status: 200},
+		{From: "/*", To: "/index.html", Status: 200},
+	}, rules)
+
+	var messages []string
+	for _, d := range diags {
+		require.Equal(t, redirects.CodeLossyConversion, d.Code)
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		"redirects[4]: skipped, regular expressions can't be represented",
+		`redirects[5]: skipped: segment "**" of source "**/*.php" can't be represented`,
+		"rewrites[0]: skipped, rewrites to functions, Cloud Run and Dynamic Links can't be represented",
+		`rule 5: rewrites[1]: "/app" no longer matches`,
+	}, messages)
+}
+
+func TestImportFirebaseSites(t *testing.T) {
+	rules, _, err := ImportFirebase(strings.NewReader(`{"hosting": [{"redirects": [{"source": "/a", "destination": "/b"}]}]}`))
+	require.NoError(t, err)
+	require.Equal(t, redirects.Rules{{From: "/a", To: "/b", Status: 301}}, rules)
+
+	_, _, err = ImportFirebase(strings.NewReader(`{"hosting": [{"site": "a"}, {"site": "b"}]}`))
+	require.EqualError(t, err, "firebase.json configures 2 sites, import them one at a time")
+}
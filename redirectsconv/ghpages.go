@@ -0,0 +1,125 @@
+package redirectsconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// ExportGitHubPages returns the files approximating rules on GitHub Pages,
+// which can't be configured to redirect, keyed by their path relative to the
+// root of the site:
+//
+//   - for each redirect without placeholders or splat, a page at its From
+//     redirecting to its To with a meta refresh, like /old/index.html for
+//     /old
+//   - a 404.html page showing notFound, an HTML fragment, whose script
+//     redirects the paths matching the redirects with placeholders or a
+//     splat
+//
+// The redirects happen in browsers, whatever their status. Rewrites and 4xx
+// rules, which need the server's help, are skipped and reported with a
+// diagnostic, like redirects from paths whose extension isn't .html.
+func ExportGitHubPages(rules redirects.Rules, notFound string) (map[string][]byte, []redirects.Diagnostic) {
+	files := make(map[string][]byte)
+	var diags []redirects.Diagnostic
+	var dynamic [][2]string
+	for i, rule := range rules {
+		if rule.Status < 300 || rule.Status >= 400 {
+			diags = append(diags, exportWarning(rule, i, "skipped, GitHub Pages can't serve status %d", rule.Status))
+			continue
+		}
+
+		p := urlpattern.Compile(rule.From)
+		if _, ok := urlpattern.Static(p); ok {
+			name, ok := ghPagesName(rule.From)
+			if !ok {
+				diags = append(diags, exportWarning(rule, i, "skipped, a page at %q wouldn't be served as HTML", rule.From))
+				continue
+			}
+			if _, exists := files[name]; !exists {
+				files[name] = ghPagesStub(rule.To)
+			}
+			continue
+		}
+
+		pattern, groups := fromRegexp(rule.From)
+		to, err := mapPlaceholders(strings.ReplaceAll(rule.To, "$", "$$"), p, func(name string) string {
+			return "$" + strconv.Itoa(groups[name])
+		}, ":")
+		if err != nil {
+			diags = append(diags, exportWarning(rule, i, "skipped: %v", err))
+			continue
+		}
+		dynamic = append(dynamic, [2]string{pattern, to})
+	}
+
+	files["404.html"] = ghPages404(dynamic, notFound)
+	return files, diags
+}
+
+// ghPagesName returns the name of the page GitHub Pages serves at the path
+// from, and whether it serves it as HTML.
+func ghPagesName(from string) (string, bool) {
+	name := strings.TrimPrefix(from, "/")
+	switch ext := path.Ext(name); {
+	case name == "" || strings.HasSuffix(name, "/"):
+		return name + "index.html", true
+	case ext == ".html" || ext == ".htm":
+		return name, true
+	case ext == "":
+		return name + "/index.html", true
+	}
+	return "", false
+}
+
+// ghPagesStub returns a page redirecting to to.
+func ghPagesStub(to string) []byte {
+	to = html.EscapeString(to)
+	return []byte(`<!DOCTYPE html>
+<meta charset="utf-8">
+<title>Redirecting…</title>
+<meta http-equiv="refresh" content="0; url=` + to + `">
+<link rel="canonical" href="` + to + `">
+<a href="` + to + `">` + to + `</a>
+`)
+}
+
+// ghPages404 returns the 404 page redirecting the paths matching the
+// patterns of dynamic to their destination, and otherwise showing notFound.
+func ghPages404(dynamic [][2]string, notFound string) []byte {
+	if dynamic == nil {
+		dynamic = [][2]string{}
+	}
+	// Marshal escapes '<' and '>', the rules can't end the script
+	rules, _ := json.Marshal(dynamic)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<!DOCTYPE html>
+<meta charset="utf-8">
+<title>Not Found</title>
+<script>
+(function () {
+  var rules = %s;
+  for (var i = 0; i < rules.length; i++) {
+    var m = new RegExp(rules[i][0]).exec(location.pathname);
+    if (m) {
+      location.replace(rules[i][1].replace(/\$(\d+|\$)/g, function (ref, n) {
+        return n === "$" ? "$" : m[n];
+      }));
+      return;
+    }
+  }
+})();
+</script>
+%s
+`, rules, notFound)
+	return b.Bytes()
+}
@@ -0,0 +1,47 @@
+package redirectsconv
+
+import (
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportGitHubPages(t *testing.T) {
+	rules := redirects.Must(redirects.ParseString(`
+/old                 /new                      301
+/docs/               https://docs.example.com/ 302
+/about.html          /team?from=about&x=1
+/blog/:year/:slug    /posts/:year/:slug        301
+/price/*             /pricing/$USD/:splat      302
+/feed.xml            /rss.xml                  301
+/app/*               /index.html               200
+/old                 /ignored                  301
+`))
+	files, diags := ExportGitHubPages(rules, "<h1>Not found</h1>")
+
+	require.Len(t, files, 4)
+	require.Equal(t, `<!DOCTYPE html>
+<meta charset="utf-8">
+<title>Redirecting…</title>
+<meta http-equiv="refresh" content="0; url=/new">
+<link rel="canonical" href="/new">
+<a href="/new">/new</a>
+`, string(files["old/index.html"]))
+	require.Contains(t, string(files["docs/index.html"]), `url=https://docs.example.com/"`)
+	require.Contains(t, string(files["about.html"]), `url=/team?from=about&amp;x=1"`)
+
+	notFound := string(files["404.html"])
+	require.Contains(t, notFound, `var rules = [["^/blog/([^/]*)/([^/]*)$","/posts/$1/$2"],["^/price/(.*)$","/pricing/$$USD/$1"]];`)
+	require.True(t, strings.HasSuffix(notFound, "</script>\n<h1>Not found</h1>\n"))
+
+	var messages []string
+	for _, d := range diags {
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		`line 7: skipped, a page at "/feed.xml" wouldn't be served as HTML`,
+		"line 8: skipped, GitHub Pages can't serve status 200",
+	}, messages)
+}
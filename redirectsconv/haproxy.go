@@ -0,0 +1,129 @@
+package redirectsconv
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// ExportHAProxy converts rules into HAProxy map files, keyed by name, and the
+// frontend rules applying them, which refer to the map files in dir, for
+// HAProxy layers in front of a gateway to apply the same redirects:
+//
+//   - consecutive rules of the same kind share a map file, looked up by path
+//     for rules without placeholders or splat, and by regular expression,
+//     with map_regm, for the others
+//   - forced redirects and rewrites apply to requests
+//   - other redirects apply to responses with status 404, when the gateway
+//     found no content
+//
+// Rules apply in order, except forced rules following others, which now
+// apply first and are reported with a diagnostic. Other rewrites, which
+// HAProxy can't retry after a 404, 4xx rules and rewrites to URLs are skipped
+// and reported, the gateway still applies them.
+func ExportHAProxy(rules redirects.Rules, dir string) (maps map[string][]byte, frontend []byte, diags []redirects.Diagnostic) {
+	maps = make(map[string][]byte)
+	var requests, responses bytes.Buffer
+
+	var group haproxyGroup
+	var entries bytes.Buffer
+	flush := func() {
+		if entries.Len() == 0 {
+			return
+		}
+		name := fmt.Sprintf("redirects-%d.map", len(maps)+1)
+		maps[name] = bytes.Clone(entries.Bytes())
+		entries.Reset()
+		group.write(&requests, &responses, path.Join(dir, name))
+	}
+
+	unforced := false
+	for i, rule := range rules {
+		isRedirect := rule.Status >= 300 && rule.Status < 400
+		switch {
+		case rule.Status == 200 && !urlpattern.IsRelative(rule.To):
+			diags = append(diags, exportWarning(rule, i, "skipped, proxying to %s isn't supported", rule.To))
+			continue
+		case rule.Status == 200 && !rule.Force:
+			diags = append(diags, exportWarning(rule, i, "skipped, HAProxy can't rewrite requests after a 404"))
+			continue
+		case !isRedirect && rule.Status != 200:
+			diags = append(diags, exportWarning(rule, i, "skipped, status %d needs the gateway's pages", rule.Status))
+			continue
+		}
+
+		p := urlpattern.Compile(rule.From)
+		key, static := urlpattern.Static(p)
+		var groups map[string]int
+		if !static {
+			key, groups = fromRegexp(rule.From)
+			if strings.Contains(rule.To, `\`) {
+				diags = append(diags, exportWarning(rule, i, "skipped, map_regm would read \\ in %q as a reference", rule.To))
+				continue
+			}
+		}
+		to, err := mapPlaceholders(rule.To, p, func(name string) string {
+			return `\` + strconv.Itoa(groups[name])
+		}, ":")
+		if err != nil {
+			diags = append(diags, exportWarning(rule, i, "skipped: %v", err))
+			continue
+		}
+
+		if rule.Force && unforced {
+			diags = append(diags, exportWarning(rule, i, "forced rule now applies before the rules preceding it"))
+		}
+		unforced = unforced || !rule.Force
+
+		g := haproxyGroup{status: rule.Status, force: rule.Force, regexp: !static}
+		if g != group {
+			flush()
+			group = g
+		}
+		fmt.Fprintf(&entries, "%s %s\n", key, to)
+	}
+	flush()
+
+	var b bytes.Buffer
+	if requests.Len() > 0 || responses.Len() > 0 {
+		b.WriteString("http-request set-var(txn.redirects_path) path\n")
+	}
+	b.Write(requests.Bytes())
+	b.Write(responses.Bytes())
+	return maps, b.Bytes(), diags
+}
+
+// A haproxyGroup is the kind of the rules sharing a map file.
+type haproxyGroup struct {
+	status int
+	force  bool
+	regexp bool
+}
+
+// write writes the frontend rules applying the map file at name, holding
+// rules of kind g, to requests or responses.
+func (g haproxyGroup) write(requests, responses *bytes.Buffer, name string) {
+	converter := "map_str"
+	if g.regexp {
+		converter = "map_regm"
+	}
+	lookup := fmt.Sprintf("var(txn.redirects_path),%s(%s)", converter, name)
+	found := fmt.Sprintf("{ %s -m found }", lookup)
+	// after a rewrite, the other rules no longer apply
+	applies := "!{ var(txn.redirects_done) -m bool } " + found
+
+	switch {
+	case g.status == 200:
+		fmt.Fprintf(requests, "http-request set-path %%[%s] if %s\n", lookup, applies)
+		fmt.Fprintf(requests, "http-request set-var(txn.redirects_done) bool(true) if %s\n", found)
+	case g.force:
+		fmt.Fprintf(requests, "http-request redirect location %%[%s] code %d if %s\n", lookup, g.status, applies)
+	default:
+		fmt.Fprintf(responses, "http-response redirect location %%[%s] code %d if { status 404 } %s\n", lookup, g.status, applies)
+	}
+}
@@ -0,0 +1,56 @@
+package redirectsconv
+
+import (
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportHAProxy(t *testing.T) {
+	rules, err := redirects.ParseString(`
+/home              /                      301!
+/app/*             /app/index.html        200!
+/old               /new                   301
+/news              /blog                  301
+/blog/:year/:slug  /posts/:year/:slug     302
+/spa/*             /spa/index.html        200
+/shadow            /new                   302!
+/gone              /410.html              410
+`, redirects.WithAllowForced())
+	require.NoError(t, err)
+	maps, frontend, diags := ExportHAProxy(rules, "/etc/haproxy")
+	require.Equal(t, map[string][]byte{
+		"redirects-1.map": []byte("/home /\n"),
+		"redirects-2.map": []byte("^/app/(.*)$ /app/index.html\n"),
+		"redirects-3.map": []byte("/old /new\n/news /blog\n"),
+		"redirects-4.map": []byte("^/blog/([^/]*)/([^/]*)$ /posts/\\1/\\2\n"),
+		"redirects-5.map": []byte("/shadow /new\n"),
+	}, maps)
+	require.Equal(t, `http-request set-var(txn.redirects_path) path
+http-request redirect location %[var(txn.redirects_path),map_str(/etc/haproxy/redirects-1.map)] code 301 if !{ var(txn.redirects_done) -m bool } { var(txn.redirects_path),map_str(/etc/haproxy/redirects-1.map) -m found }
+http-request set-path %[var(txn.redirects_path),map_regm(/etc/haproxy/redirects-2.map)] if !{ var(txn.redirects_done) -m bool } { var(txn.redirects_path),map_regm(/etc/haproxy/redirects-2.map) -m found }
+http-request set-var(txn.redirects_done) bool(true) if { var(txn.redirects_path),map_regm(/etc/haproxy/redirects-2.map) -m found }
+http-request redirect location %[var(txn.redirects_path),map_str(/etc/haproxy/redirects-5.map)] code 302 if !{ var(txn.redirects_done) -m bool } { var(txn.redirects_path),map_str(/etc/haproxy/redirects-5.map) -m found }
+http-response redirect location %[var(txn.redirects_path),map_str(/etc/haproxy/redirects-3.map)] code 301 if { status 404 } !{ var(txn.redirects_done) -m bool } { var(txn.redirects_path),map_str(/etc/haproxy/redirects-3.map) -m found }
+http-response redirect location %[var(txn.redirects_path),map_regm(/etc/haproxy/redirects-4.map)] code 302 if { status 404 } !{ var(txn.redirects_done) -m bool } { var(txn.redirects_path),map_regm(/etc/haproxy/redirects-4.map) -m found }
+`, string(frontend))
+
+	var messages []string
+	for _, d := range diags {
+		require.Equal(t, redirects.CodeLossyConversion, d.Code)
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		"line 7: skipped, HAProxy can't rewrite requests after a 404",
+		"line 8: forced rule now applies before the rules preceding it",
+		"line 9: skipped, status 410 needs the gateway's pages",
+	}, messages)
+}
+
+func TestExportHAProxyEmpty(t *testing.T) {
+	maps, frontend, diags := ExportHAProxy(nil, "/etc/haproxy")
+	require.Empty(t, maps)
+	require.Empty(t, frontend)
+	require.Empty(t, diags)
+}
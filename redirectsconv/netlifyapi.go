@@ -0,0 +1,75 @@
+package redirectsconv
+
+import (
+	"encoding/json"
+	"io"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+)
+
+// A NetlifyRule is a redirect rule object of the Netlify API, as returned for
+// the deploys of a site.
+type NetlifyRule struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status,omitempty"`
+	Force  bool   `json:"force,omitempty"`
+
+	// Query, Conditions, Headers and Signed restrict or change how the
+	// rule applies, which rules can't represent.
+	Query      map[string]string `json:"query,omitempty"`
+	Conditions json.RawMessage   `json:"conditions,omitempty"`
+	Headers    json.RawMessage   `json:"headers,omitempty"`
+	Signed     string            `json:"signed,omitempty"`
+}
+
+// ImportNetlifyAPI converts a JSON array of Netlify API rule objects into
+// rules, for migrating the live rules of a site. Objects without a status
+// redirect with 301, like rules without one. Objects with query parameters,
+// conditions, headers or a signature, which rules can't represent, are
+// skipped and reported with a diagnostic. An error is only returned for
+// invalid JSON.
+func ImportNetlifyAPI(r io.Reader) (redirects.Rules, []redirects.Diagnostic, error) {
+	var objects []NetlifyRule
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return nil, nil, err
+	}
+
+	var imp importer
+	for i, o := range objects {
+		var unsupported string
+		switch {
+		case len(o.Query) > 0:
+			unsupported = "query parameters"
+		case len(o.Conditions) > 0 && string(o.Conditions) != "null" && string(o.Conditions) != "{}":
+			unsupported = "conditions"
+		case len(o.Headers) > 0 && string(o.Headers) != "null" && string(o.Headers) != "{}":
+			unsupported = "headers"
+		case o.Signed != "":
+			unsupported = "signed proxies"
+		}
+		if unsupported != "" {
+			imp.warn(0, -1, "[%d]: skipped, %s can't be represented", i, unsupported)
+			continue
+		}
+
+		status := o.Status
+		if status == 0 {
+			status = 301
+		}
+		if imp.add(0, o.From, o.To, status) {
+			imp.rules[len(imp.rules)-1].Force = o.Force
+		}
+	}
+	return imp.rules, imp.diags, nil
+}
+
+// ExportNetlifyAPI converts rules into Netlify API rule objects, which
+// represent them all.
+func ExportNetlifyAPI(rules redirects.Rules) []NetlifyRule {
+	objects := make([]NetlifyRule, len(rules))
+	for i, rule := range rules {
+		objects[i] = NetlifyRule{From: rule.From, To: rule.To, Status: rule.Status, Force: rule.Force}
+	}
+	return objects
+}
@@ -0,0 +1,62 @@
+package redirectsconv
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportNetlifyAPI(t *testing.T) {
+	rules, diags, err := ImportNetlifyAPI(strings.NewReader(`[
+  {"from": "/old", "to": "/new", "status": 301, "force": false, "conditions": {}, "headers": null},
+  {"from": "/app/*", "to": "/index.html", "status": 200, "force": true},
+  {"from": "/blog/:slug", "to": "/posts/:slug"},
+  {"from": "/search", "to": "/find", "status": 302, "query": {"q": ":q"}},
+  {"from": "/fr/*", "to": "/fr/:splat", "status": 302, "conditions": {"Language": ["fr"]}},
+  {"from": "/api/*", "to": "https://api.example.com/:splat", "status": 200, "signed": "API_SECRET"},
+  {"from": "/bad", "to": "/elsewhere", "status": 999}
+]`))
+	require.NoError(t, err)
+	require.Equal(t, redirects.Rules{
+		{From: "/old", To: "/new", Status: 301},
+		{From: "/app/*", To: "/index.html", Status: 200, Force: true},
+		{From: "/blog/:slug", To: "/posts/:slug", Status: 301},
+	}, rules)
+
+	var messages []string
+	for _, d := range diags {
+		require.Equal(t, redirects.CodeLossyConversion, d.Code)
+		messages = append(messages, d.Message)
+	}
+	require.Equal(t, []string{
+		"[3]: skipped, query parameters can't be represented",
+		"[4]: skipped, conditions can't be represented",
+		"[5]: skipped, signed proxies can't be represented",
+		"skipped, the converted rule is invalid: parsing status \"999\": status code 999 is not supported",
+	}, messages)
+
+	_, _, err = ImportNetlifyAPI(strings.NewReader(`{"from": "/a"}`))
+	require.Error(t, err)
+}
+
+func TestExportNetlifyAPI(t *testing.T) {
+	rules, err := redirects.ParseString("/old /new\n/app/* /index.html 200!\n", redirects.WithAllowForced())
+	require.NoError(t, err)
+	b, err := json.Marshal(ExportNetlifyAPI(rules))
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+  {"from": "/old", "to": "/new", "status": 301},
+  {"from": "/app/*", "to": "/index.html", "status": 200, "force": true}
+]`, string(b))
+
+	imported, diags, err := ImportNetlifyAPI(strings.NewReader(string(b)))
+	require.NoError(t, err)
+	require.Empty(t, diags)
+	for i := range rules {
+		rules[i].Line = 0
+	}
+	require.Equal(t, redirects.Rules(rules), imported)
+}
@@ -0,0 +1,85 @@
+package redirectsconv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// ExportNetlify writes rules to w as a _redirects file Netlify and other
+// implementations of its format read the same, whatever they were parsed or
+// imported from. Each rule is on a line of its own with an explicit status,
+// and the features only this package supports are converted:
+//
+//   - "::" for a literal colon becomes a single colon, which other
+//     implementations keep as is when no placeholder follows
+//   - rules with ipfs:// or ipns:// destinations, placeholders in the host of
+//     To or a literal colon followed by a placeholder's name are dropped
+//
+// Converted and dropped rules are documented with a comment above them in
+// the file, dropped ones commented out, and reported with a diagnostic.
+func ExportNetlify(w io.Writer, rules redirects.Rules) ([]redirects.Diagnostic, error) {
+	bw := bufio.NewWriter(w)
+	var diags []redirects.Diagnostic
+	for i, rule := range rules {
+		to, downgraded, err := netlifyDestination(rule)
+		line := rule.From + " " + to + " " + strconv.Itoa(rule.Status)
+		if rule.Force {
+			line += "!"
+		}
+
+		switch {
+		case err != nil:
+			d := exportWarning(rule, i, "dropped, %v", err)
+			diags = append(diags, d)
+			fmt.Fprintf(bw, "# %s\n# %s\n", d.Message, line)
+			continue
+		case downgraded != "":
+			d := exportWarning(rule, i, "%s", downgraded)
+			d.Severity = redirects.SeverityInfo
+			diags = append(diags, d)
+			fmt.Fprintf(bw, "# %s\n", d.Message)
+		}
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+	}
+	return diags, bw.Flush()
+}
+
+// netlifyDestination returns the To of rule in the format of Netlify, and
+// what was converted, or an error if it can't be represented.
+func netlifyDestination(rule redirects.Rule) (to, downgraded string, err error) {
+	if u, err := url.Parse(rule.To); err == nil && (u.Scheme == "ipfs" || u.Scheme == "ipns") {
+		return rule.To, "", fmt.Errorf("%s destinations are only supported by IPFS gateways", rule.To[:len(u.Scheme)])
+	}
+	names := urlpattern.Placeholders(urlpattern.Compile(rule.From))
+	start, escapes := urlpattern.Authority(rule.To)
+	for i := start; i < escapes; i++ {
+		if _, ok := urlpattern.At(rule.To[i+1:escapes], names); ok && rule.To[i] == ':' {
+			return rule.To, "", fmt.Errorf("placeholders in the host of 'to' aren't supported")
+		}
+	}
+
+	if !strings.Contains(rule.To[escapes:], "::") {
+		return rule.To, "", nil
+	}
+	var b strings.Builder
+	b.WriteString(rule.To[:escapes])
+	for i := escapes; i < len(rule.To); i++ {
+		b.WriteByte(rule.To[i])
+		if rule.To[i] != ':' || i+1 == len(rule.To) || rule.To[i+1] != ':' {
+			continue
+		}
+		i++
+		if ph, ok := urlpattern.At(rule.To[i+1:], names); ok {
+			return rule.To, "", fmt.Errorf("the literal colon before %q would be read as placeholder :%s", ph.Name, ph.Name)
+		}
+	}
+	return b.String(), `"::" became a single colon`, nil
+}
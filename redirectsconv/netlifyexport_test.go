@@ -0,0 +1,56 @@
+package redirectsconv
+
+import (
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportNetlify(t *testing.T) {
+	rules := redirects.Must(redirects.ParseString(`
+/old                /new
+/talks/:id          /schedule/10::am/:id   302
+/slots/:id          /slot::id
+/app/*              /index.html            200!
+/cid                ipfs://bafkqaaa
+/:tenant/*          https://:tenant.example.com/:splat  302
+/*                  /404.html              404
+`, redirects.WithAllowForced(), redirects.WithDynamicProxyHosts()))
+
+	var b strings.Builder
+	diags, err := ExportNetlify(&b, rules)
+	require.NoError(t, err)
+	require.Equal(t, `/old /new 301
+# "::" became a single colon
+/talks/:id /schedule/10:am/:id 302
+# dropped, the literal colon before "id" would be read as placeholder :id
+# /slots/:id /slot::id 301
+/app/* /index.html 200!
+# dropped, ipfs destinations are only supported by IPFS gateways
+# /cid ipfs://bafkqaaa 301
+# dropped, placeholders in the host of 'to' aren't supported
+# /:tenant/* https://:tenant.example.com/:splat 302
+/* /404.html 404
+`, b.String())
+
+	var messages []string
+	for _, d := range diags {
+		require.Equal(t, redirects.CodeLossyConversion, d.Code)
+		messages = append(messages, d.Severity.String()+" "+d.String())
+	}
+	require.Equal(t, []string{
+		`info line 3: "::" became a single colon`,
+		`warning line 4: dropped, the literal colon before "id" would be read as placeholder :id`,
+		"warning line 6: dropped, ipfs destinations are only supported by IPFS gateways",
+		"warning line 7: dropped, placeholders in the host of 'to' aren't supported",
+	}, messages)
+
+	// the exported rules parse into the same rules, but for those converted
+	exported := redirects.Must(redirects.ParseString(b.String(), redirects.WithAllowForced()))
+	require.Len(t, exported, 4)
+	want := rules[3]
+	want.Line = exported[2].Line
+	require.Equal(t, want, exported[2])
+}
@@ -0,0 +1,41 @@
+package redirectsconv
+
+import redirects "github.com/ipfs/go-ipfs-redirects-file"
+
+// A NetlifyNormalizedRule is a rule in the normalized shape
+// netlify-redirect-parser returns and its test vectors expect, for comparing
+// the outputs of both parsers. Rules have no query parameters, conditions
+// or headers, which are always empty.
+type NetlifyNormalizedRule struct {
+	From       string              `json:"from"`
+	Path       string              `json:"path"`
+	Query      map[string]string   `json:"query"`
+	To         string              `json:"to"`
+	Status     int                 `json:"status"`
+	Force      bool                `json:"force"`
+	Conditions map[string][]string `json:"conditions"`
+	Headers    map[string]string   `json:"headers"`
+
+	// Proxy is true for rewrites to URLs, which proxy requests.
+	Proxy bool `json:"proxy"`
+}
+
+// NormalizeNetlify returns rules in the normalized shape of
+// netlify-redirect-parser.
+func NormalizeNetlify(rules redirects.Rules) []NetlifyNormalizedRule {
+	normalized := make([]NetlifyNormalizedRule, len(rules))
+	for i, rule := range rules {
+		normalized[i] = NetlifyNormalizedRule{
+			From:       rule.From,
+			Path:       rule.From,
+			Query:      map[string]string{},
+			To:         rule.To,
+			Status:     rule.Status,
+			Force:      rule.Force,
+			Conditions: map[string][]string{},
+			Headers:    map[string]string{},
+			Proxy:      rule.IsRewrite() && rule.IsProxy(),
+		}
+	}
+	return normalized
+}
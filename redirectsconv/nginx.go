@@ -0,0 +1,560 @@
+package redirectsconv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// ImportNginx converts the redirects and rewrites of an nginx configuration
+// into rules: rewrite directives, and return and rewrite directives in
+// location blocks. Rules come in the order of the directives, which is the
+// order nginx applies them in as long as locations don't overlap.
+//
+// Regular expressions convert when their groups capture whole segments, like
+// ([^/]+), or the rest of the path, like (.*), which become placeholders and
+// splats. Directives that can't be represented, such as those using nginx
+// variables, are skipped and reported with a diagnostic, as are conversions
+// that match slightly different paths. An error is only returned for
+// configurations that don't parse.
+func ImportNginx(r io.Reader) (redirects.Rules, []redirects.Diagnostic, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &nginxParser{src: string(src), line: 1}
+	dirs, err := p.parseBlock(false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var imp importer
+	imp.nginxDirectives(dirs, nil)
+	return imp.rules, imp.diags, nil
+}
+
+// An nginxLocation is the location block directives are in.
+type nginxLocation struct {
+	// from is the path the location matches, as a From, and prefix is
+	// true if it matches the paths beginning with from.
+	from   string
+	prefix bool
+
+	// groups names the placeholders the groups of the location's regular
+	// expression capture.
+	groups []string
+}
+
+// nginxDirectives converts dirs, the directives of the location loc or nil.
+func (imp *importer) nginxDirectives(dirs []nginxDirective, loc *nginxLocation) {
+	for _, d := range dirs {
+		switch d.name {
+		case "rewrite":
+			// the regular expression applies to the whole path, in
+			// locations too
+			imp.nginxRewrite(d)
+		case "return":
+			imp.nginxReturn(d, loc)
+		case "location":
+			if loc, ok := imp.nginxLocation(d); ok {
+				imp.nginxDirectives(d.block, loc)
+			}
+		default:
+			// server, http and the like hold the directives that matter
+			imp.nginxDirectives(d.block, loc)
+		}
+	}
+}
+
+// nginxLocation converts the location directive d.
+func (imp *importer) nginxLocation(d nginxDirective) (*nginxLocation, bool) {
+	args := d.args
+	modifier := ""
+	if len(args) == 2 {
+		modifier, args = args[0], args[1:]
+	}
+	if len(args) != 1 {
+		imp.warn(d.line, -1, "skipped location with %d arguments", len(d.args))
+		return nil, false
+	}
+
+	path := args[0]
+	switch modifier {
+	case "=":
+		return &nginxLocation{from: path}, true
+	case "", "^~":
+		if strings.HasPrefix(path, "@") {
+			// named locations are only reached by internal redirects
+			return nil, false
+		}
+		return &nginxLocation{from: path, prefix: true}, true
+	case "~", "~*":
+		from, groups, prefix, err := nginxPattern(path)
+		if err != nil {
+			imp.warn(d.line, -1, "skipped location: %v", err)
+			return nil, false
+		}
+		if modifier == "~*" {
+			imp.warn(d.line, -1, "location %q is case-insensitive, rules are case-sensitive", path)
+		}
+		return &nginxLocation{from: from, prefix: prefix, groups: groups}, true
+	}
+	imp.warn(d.line, -1, "skipped location with modifier %q", modifier)
+	return nil, false
+}
+
+// nginxPrefix returns the Froms matching the paths beginning with prefix, as
+// closely as rules can.
+func (imp *importer) nginxPrefix(line int, prefix string) []string {
+	if strings.HasSuffix(prefix, "/") {
+		return []string{prefix + "*"}
+	}
+	imp.warn(line, -1, "paths beginning with %q but not %q, like %q, no longer match", prefix, prefix+"/", prefix+"x")
+	return []string{prefix, prefix + "/*"}
+}
+
+// nginxRewrite converts the rewrite directive d.
+func (imp *importer) nginxRewrite(d nginxDirective) {
+	if len(d.args) < 2 || len(d.args) > 3 {
+		imp.warn(d.line, -1, "skipped rewrite with %d arguments", len(d.args))
+		return
+	}
+	from, groups, prefix, err := nginxPattern(d.args[0])
+	if err != nil {
+		imp.warn(d.line, -1, "skipped rewrite: %v", err)
+		return
+	}
+	to, err := nginxReplacement(d.args[1], groups)
+	if err != nil {
+		imp.warn(d.line, -1, "skipped rewrite: %v", err)
+		return
+	}
+
+	status := 200
+	if isAbsoluteURL(to) {
+		status = 302
+	}
+	if len(d.args) == 3 {
+		switch d.args[2] {
+		case "permanent":
+			status = 301
+		case "redirect":
+			status = 302
+		case "last", "break":
+		default:
+			imp.warn(d.line, -1, "skipped rewrite with flag %q", d.args[2])
+			return
+		}
+	}
+
+	froms := []string{from}
+	if prefix {
+		froms = imp.nginxPrefix(d.line, from)
+	}
+	for _, from := range froms {
+		imp.add(d.line, from, to, status)
+	}
+}
+
+// nginxReturn converts the return directive d, in the location loc or nil.
+func (imp *importer) nginxReturn(d nginxDirective, loc *nginxLocation) {
+	if loc == nil {
+		// outside of locations, return applies to every path
+		loc = &nginxLocation{from: "/*"}
+	}
+
+	status, url := 302, ""
+	switch len(d.args) {
+	case 1:
+		url = d.args[0]
+		if code, err := strconv.Atoi(url); err == nil {
+			status, url = code, ""
+		}
+	case 2:
+		code, err := strconv.Atoi(d.args[0])
+		if err != nil {
+			imp.warn(d.line, -1, "skipped return with code %q", d.args[0])
+			return
+		}
+		status, url = code, d.args[1]
+	default:
+		imp.warn(d.line, -1, "skipped return with %d arguments", len(d.args))
+		return
+	}
+	if status < 300 || status >= 400 {
+		imp.warn(d.line, -1, "skipped return %d, rules need a page to respond %d with", status, status)
+		return
+	}
+	if url == "" {
+		imp.warn(d.line, -1, "skipped return %d without a URL", status)
+		return
+	}
+
+	to, err := nginxReplacement(url, loc.groups)
+	if err != nil {
+		imp.warn(d.line, -1, "skipped return: %v", err)
+		return
+	}
+	froms := []string{loc.from}
+	if loc.prefix {
+		froms = imp.nginxPrefix(d.line, loc.from)
+	}
+	for _, from := range froms {
+		imp.add(d.line, from, to, status)
+	}
+}
+
+// nginxPattern converts the regular expression re of a rewrite or location
+// into a From, and the names of the placeholders its groups capture. Unless
+// re is anchored at the end, or ends with a group capturing the rest of the
+// path, it matches paths beginning with From, and prefix is true.
+func nginxPattern(re string) (from string, groups []string, prefix bool, err error) {
+	s, ok := strings.CutPrefix(re, "^")
+	if !ok {
+		return "", nil, false, fmt.Errorf("regular expression %q isn't anchored at the start", re)
+	}
+	s, anchored := strings.CutSuffix(s, "$")
+	if anchored && strings.HasSuffix(s, `\`) {
+		s, anchored = s+"$", false
+	}
+
+	var b strings.Builder
+	for len(s) > 0 {
+		switch c := s[0]; {
+		case c == '\\' && len(s) > 1 && strings.IndexByte("./-_~", s[1]) >= 0:
+			b.WriteByte(s[1])
+			s = s[2:]
+		case c == '(':
+			end := strings.IndexByte(s, ')')
+			if end < 0 {
+				return "", nil, false, fmt.Errorf("regular expression %q has an unterminated group", re)
+			}
+			group, rest := s[1:end], s[end+1:]
+			segment := strings.HasSuffix(b.String(), "/")
+			switch {
+			case segment && rest == "" && (group == ".*" || group == ".+"):
+				b.WriteByte('*')
+				groups = append(groups, "splat")
+				anchored = true
+			case segment && (rest == "" || rest[0] == '/') && (group == "[^/]+" || group == "[^/]*"):
+				name := "p" + strconv.Itoa(len(groups)+1)
+				b.WriteString(":" + name)
+				groups = append(groups, name)
+			default:
+				return "", nil, false, fmt.Errorf("group (%s) of %q can't be represented, only ([^/]+) and a final (.*) can", group, re)
+			}
+			s = rest
+		case strings.IndexByte(`.[]{}()*+?|^$\`, c) >= 0:
+			return "", nil, false, fmt.Errorf("regular expression %q uses %q", re, c)
+		default:
+			b.WriteByte(c)
+			s = s[1:]
+		}
+	}
+	return b.String(), groups, !anchored, nil
+}
+
+// nginxReplacement converts the replacement of a rewrite, or the URL of a
+// return, s into a To, with the references to the groups of the regular
+// expression replaced by the placeholders they capture.
+func nginxReplacement(s string, groups []string) (string, error) {
+	// a trailing '?' drops the query, like rules do
+	s = strings.TrimSuffix(s, "?")
+	// colons in the scheme and authority, like before a port, aren't
+	// placeholders
+	escapes := urlpattern.EscapesStart(s)
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$' && i+1 < len(s) && '1' <= s[i+1] && s[i+1] <= '9':
+			n := int(s[i+1] - '0')
+			if n > len(groups) {
+				return "", fmt.Errorf("reference $%d to a missing group", n)
+			}
+			b.WriteString(":" + groups[n-1])
+			i++
+		case c == '$':
+			end := i + 1
+			for end < len(s) && (isIdentByte(s[end]) || s[end] == '{' || s[end] == '}') {
+				end++
+			}
+			return "", fmt.Errorf("variable %s can't be represented", s[i:end])
+		case c == ':' && i >= escapes && i+1 < len(s) && isIdentByte(s[i+1]):
+			// a literal colon, not a placeholder
+			b.WriteString("::")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// isIdentByte reports whether c can be part of a placeholder's name.
+func isIdentByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+// isAbsoluteURL reports whether to is an http or https URL.
+func isAbsoluteURL(to string) bool {
+	lower := strings.ToLower(to)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// An nginxDirective is a directive of an nginx configuration.
+type nginxDirective struct {
+	name string
+	args []string
+	line int
+
+	// block holds the directives of the directive's block, if any.
+	block []nginxDirective
+}
+
+// nginxParser parses nginx configurations.
+type nginxParser struct {
+	src  string
+	line int
+}
+
+// parseBlock parses directives up to the end of the block, or of the
+// configuration if not nested.
+func (p *nginxParser) parseBlock(nested bool) ([]nginxDirective, error) {
+	var dirs []nginxDirective
+	var words []string
+	line := 0
+	for {
+		tok, quoted, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case tok == "" && !quoted:
+			if nested {
+				return nil, fmt.Errorf("line %d: unexpected end of configuration, expecting \"}\"", p.line)
+			}
+			if len(words) > 0 {
+				return nil, fmt.Errorf("line %d: unexpected end of configuration, expecting \";\"", p.line)
+			}
+			return dirs, nil
+		case !quoted && tok == ";":
+			if len(words) == 0 {
+				return nil, fmt.Errorf("line %d: unexpected \";\"", p.line)
+			}
+			dirs = append(dirs, nginxDirective{name: words[0], args: words[1:], line: line})
+			words = nil
+		case !quoted && tok == "{":
+			if len(words) == 0 {
+				return nil, fmt.Errorf("line %d: unexpected \"{\"", p.line)
+			}
+			block, err := p.parseBlock(true)
+			if err != nil {
+				return nil, err
+			}
+			dirs = append(dirs, nginxDirective{name: words[0], args: words[1:], line: line, block: block})
+			words = nil
+		case !quoted && tok == "}":
+			if !nested || len(words) > 0 {
+				return nil, fmt.Errorf("line %d: unexpected \"}\"", p.line)
+			}
+			return dirs, nil
+		default:
+			if len(words) == 0 {
+				line = p.line
+			}
+			words = append(words, tok)
+		}
+	}
+}
+
+// next returns the next token, a word or one of ";{}", and whether it was
+// quoted. It returns an empty unquoted token at the end of the configuration.
+func (p *nginxParser) next() (string, bool, error) {
+	for len(p.src) > 0 {
+		switch c := p.src[0]; c {
+		case '\n':
+			p.line++
+			p.src = p.src[1:]
+		case ' ', '\t', '\r':
+			p.src = p.src[1:]
+		case '#':
+			end := strings.IndexByte(p.src, '\n')
+			if end < 0 {
+				end = len(p.src)
+			}
+			p.src = p.src[end:]
+		case ';', '{', '}':
+			p.src = p.src[1:]
+			return string(c), false, nil
+		case '"', '\'':
+			var b strings.Builder
+			for i := 1; i < len(p.src); i++ {
+				switch p.src[i] {
+				case c:
+					p.src = p.src[i+1:]
+					return b.String(), true, nil
+				case '\\':
+					if i+1 < len(p.src) && (p.src[i+1] == c || p.src[i+1] == '\\') {
+						i++
+					}
+				case '\n':
+					p.line++
+				}
+				b.WriteByte(p.src[i])
+			}
+			return "", false, fmt.Errorf("line %d: unterminated string", p.line)
+		default:
+			end := strings.IndexAny(p.src, " \t\r\n;{}#")
+			if end < 0 {
+				end = len(p.src)
+			}
+			tok := p.src[:end]
+			p.src = p.src[end:]
+			return tok, false, nil
+		}
+	}
+	return "", false, nil
+}
+
+// ExportNginx converts rules into nginx configuration snippets, http to
+// include in the http context and server in the server block of the site:
+//
+//   - redirects and rewrites without placeholders or splat are looked up in
+//     maps, one per status, keyed by path
+//   - the other redirects and rewrites match regular expressions, in order,
+//     after the maps
+//   - forced rules get locations of their own, exact or regular expression
+//     ones, which apply even to paths with content
+//
+// The other rules apply to paths without content: nginx responding 404 hands
+// the request to a named location, @redirects, applying them. A final
+// catch-all 4xx rule, like /* /404.html 404, becomes the error page of that
+// location. Other 4xx rules and rewrites to URLs, which proxy requests, are
+// skipped and reported with a diagnostic, as are static rules now applying
+// before dynamic ones matching the same paths.
+func ExportNginx(rules redirects.Rules) (http, server []byte, diags []redirects.Diagnostic) {
+	// maps holds the static rules by status
+	maps := make(map[int][][2]string)
+	var statuses []int
+	var dynamic, forced, notFound bytes.Buffer
+
+	// dynamicPatterns are the regular expressions of the rules matched by
+	// them, and dynamicIndexes the indexes of those rules in rules
+	var dynamicPatterns []*regexp.Regexp
+	var dynamicIndexes []int
+
+	for i, rule := range rules {
+		if strings.Contains(rule.To, "$") {
+			diags = append(diags, exportWarning(rule, i, "skipped, nginx would read $ in %q as a variable", rule.To))
+			continue
+		}
+		isRedirect := rule.Status >= 300 && rule.Status < 400
+		switch {
+		case rule.Status == 200 && !urlpattern.IsRelative(rule.To):
+			diags = append(diags, exportWarning(rule, i, "skipped, proxying to %s isn't supported", rule.To))
+			continue
+		case rule.Status >= 400 && rule.From == "/*" && i == len(rules)-1 && !rule.Force && urlpattern.IsRelative(rule.To):
+			fmt.Fprintf(&notFound, "\terror_page %d %s;\n", rule.Status, nginxToken(rule.To))
+			continue
+		case !isRedirect && rule.Status != 200:
+			diags = append(diags, exportWarning(rule, i, "skipped, only a final catch-all rule can have status %d", rule.Status))
+			continue
+		}
+
+		p := urlpattern.Compile(rule.From)
+		key, static := urlpattern.Static(p)
+		pattern, groups := fromRegexp(rule.From)
+		to, err := mapPlaceholders(rule.To, p, func(name string) string {
+			return "$" + strconv.Itoa(groups[name])
+		}, ":")
+		if err != nil {
+			diags = append(diags, exportWarning(rule, i, "skipped: %v", err))
+			continue
+		}
+
+		switch {
+		case rule.Force && static:
+			fmt.Fprintf(&forced, "location = %s {\n\t%s\n}\n", nginxToken(key), nginxRespond("^", to, rule.Status))
+		case rule.Force:
+			// the rewrite's own regular expression resets the captures
+			fmt.Fprintf(&forced, "location ~ %s {\n\t%s\n}\n", nginxQuote(pattern), nginxRespond(nginxQuote(pattern), to, rule.Status))
+		case static:
+			for j, re := range dynamicPatterns {
+				if re.MatchString(key) {
+					diags = append(diags, exportWarning(rule, i, "now applies before rule %d, which matches it too", dynamicIndexes[j]+1))
+					break
+				}
+			}
+			if maps[rule.Status] == nil {
+				statuses = append(statuses, rule.Status)
+			}
+			maps[rule.Status] = append(maps[rule.Status], [2]string{key, to})
+		default:
+			dynamicPatterns = append(dynamicPatterns, regexp.MustCompile(pattern))
+			dynamicIndexes = append(dynamicIndexes, i)
+			fmt.Fprintf(&dynamic, "\t%s\n", nginxRespond(nginxQuote(pattern), to, rule.Status))
+		}
+	}
+
+	var h bytes.Buffer
+	for _, status := range statuses {
+		fmt.Fprintf(&h, "map $uri $redirects_%d {\n", status)
+		for _, entry := range maps[status] {
+			fmt.Fprintf(&h, "\t%s %s;\n", nginxToken(entry[0]), nginxToken(entry[1]))
+		}
+		h.WriteString("}\n")
+	}
+
+	var s bytes.Buffer
+	s.Write(forced.Bytes())
+	s.WriteString("error_page 404 = @redirects;\n")
+	s.WriteString("location @redirects {\n")
+	if notFound.Len() > 0 {
+		s.WriteString("\trecursive_error_pages on;\n")
+		s.Write(notFound.Bytes())
+	}
+	for _, status := range statuses {
+		fmt.Fprintf(&s, "\tif ($redirects_%d) {\n\t\t%s\n\t}\n", status, nginxRespond("^", fmt.Sprintf("$redirects_%d", status), status))
+	}
+	s.Write(dynamic.Bytes())
+	s.WriteString("\treturn 404;\n}\n")
+	return h.Bytes(), s.Bytes(), diags
+}
+
+// nginxRespond returns the directive redirecting or rewriting the paths
+// matching the regular expression re to to with status. to refers to the
+// groups of re, "^" for paths that already matched.
+func nginxRespond(re, to string, status int) string {
+	to = nginxToken(to)
+	switch status {
+	case 200:
+		return fmt.Sprintf("rewrite %s %s last;", re, to)
+	case 301:
+		return fmt.Sprintf("rewrite %s %s permanent;", re, to)
+	case 302:
+		return fmt.Sprintf("rewrite %s %s redirect;", re, to)
+	}
+	if re == "^" {
+		return fmt.Sprintf("return %d %s;", status, to)
+	}
+	return fmt.Sprintf("if ($uri ~ %s) {\n\t\treturn %d %s;\n\t}", re, status, to)
+}
+
+// nginxToken returns s as an nginx configuration token, quoted if needed.
+func nginxToken(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\r\n;{}#\"'\\") {
+		return s
+	}
+	return nginxQuote(s)
+}
+
+// nginxQuote returns s quoted for nginx configurations.
+func nginxQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,152 @@
+package redirectsconv
+
+import (
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportNginx(t *testing.T) {
+	rules, diags, err := ImportNginx(strings.NewReader(`
+server {
+    listen 80;
+    rewrite ^/old$ /new permanent;
+    rewrite ^/blog/([^/]+)/([^/]+)$ /posts/$1/$2 redirect;
+    rewrite "^/docs/(.*)$" /documentation/$1 last;
+    rewrite ^/external/(.*) https://example.com/$1;
+    rewrite ^/user/(\d+)$ /profile/$1 permanent;
+    rewrite ^/home$ $scheme://example.com/ permanent;
+
+    location = /legacy {
+        return 301 /modern;
+    }
+    location /shop/ {
+        return 302 https://shop.example.com/;
+    }
+    location ~ ^/talks/([^/]+)$ {
+        return 301 /schedule/10:am/$1;
+    }
+    location /api {
+        proxy_pass http://backend;
+    }
+    location /app {
+        return 301 /application/;
+    }
+    location /gone/ {
+        return 410;
+    }
+}
+`))
+	require.NoError(t, err)
+	require.Equal(t, redirects.Rules{
+		{From: "/old", To: "/new", Status: 301},
+		{From: "/blog/:p1/:p2", To: "/posts/:p1/:p2", Status: 302},
+		{From: "/docs/*", To: "/documentation/:splat", Status: 200},
+		{From: "/external/*", To: "https://example.com/:splat", Status: 302},
+		{From: "/legacy", To: "/modern", Status: 301},
+		{From: "/shop/*", To: "https://shop.example.com/", Status: 302},
+		{From: "/talks/:p1", To: "/schedule/10::am/:p1", Status: 301},
+		{From: "/app", To: "/application/", Status: 301},
+		{From: "/app/*", To: "/application/", Status: 301},
+	}, rules)
+
+	var messages []string
+	for _, d := range diags {
+		require.Equal(t, redirects.CodeLossyConversion, d.Code)
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		`line 8: skipped rewrite: group (\d+) of "^/user/(\\d+)$" can't be represented, only ([^/]+) and a final (.*) can`,
+		"line 9: skipped rewrite: variable $scheme can't be represented",
+		`line 24: paths beginning with "/app" but not "/app/", like "/appx", no longer match`,
+		"line 27: skipped return 410, rules need a page to respond 410 with",
+	}, messages)
+}
+
+func TestImportNginxPorts(t *testing.T) {
+	rules, diags, err := ImportNginx(strings.NewReader(`
+rewrite ^/a$ https://example.com:8080/b permanent;
+rewrite ^/c/([^/]+)$ http://[::1]:8080/d/$1/10:am redirect;
+location = /e {
+    return 301 https://example.com:8443/f;
+}
+`))
+	require.NoError(t, err)
+	require.Empty(t, diags)
+	require.Equal(t, redirects.Rules{
+		{From: "/a", To: "https://example.com:8080/b", Status: 301},
+		{From: "/c/:p1", To: "http://[::1]:8080/d/:p1/10::am", Status: 302},
+		{From: "/e", To: "https://example.com:8443/f", Status: 301},
+	}, rules)
+}
+
+func TestImportNginxSyntaxErrors(t *testing.T) {
+	for config, err := range map[string]string{
+		"server {\n rewrite ^/a$ /b;\n": `line 3: unexpected end of configuration, expecting "}"`,
+		"rewrite ^/a$ /b":               `line 1: unexpected end of configuration, expecting ";"`,
+		"}":                             `line 1: unexpected "}"`,
+		"rewrite \"^/a$ /b;":            "line 1: unterminated string",
+	} {
+		_, _, e := ImportNginx(strings.NewReader(config))
+		require.EqualError(t, e, err, config)
+	}
+}
+
+func TestExportNginx(t *testing.T) {
+	rules, err := redirects.ParseString(`
+/old               /new                   301
+/blog/:year/:slug  /posts/:year/:slug     302
+/news              /blog                  301
+/blog/2020/hello   /hello                 301
+/app/*             /app/index.html        200
+/moved/*           /elsewhere/:splat      308
+/shadow            /new                   302!
+/promo/*           /sale/:splat           307!
+/api/*             https://api.example.com/:splat 200
+/gone              /410.html              410
+/*                 /404.html              404
+`, redirects.WithAllowForced())
+	require.NoError(t, err)
+	http, server, diags := ExportNginx(rules)
+	require.Equal(t, `map $uri $redirects_301 {
+	/old /new;
+	/news /blog;
+	/blog/2020/hello /hello;
+}
+`, string(http))
+	require.Equal(t, `location = /shadow {
+	rewrite ^ /new redirect;
+}
+location ~ "^/promo/(.*)$" {
+	if ($uri ~ "^/promo/(.*)$") {
+		return 307 /sale/$1;
+	}
+}
+error_page 404 = @redirects;
+location @redirects {
+	recursive_error_pages on;
+	error_page 404 /404.html;
+	if ($redirects_301) {
+		rewrite ^ $redirects_301 permanent;
+	}
+	rewrite "^/blog/([^/]*)/([^/]*)$" /posts/$1/$2 redirect;
+	rewrite "^/app/(.*)$" /app/index.html last;
+	if ($uri ~ "^/moved/(.*)$") {
+		return 308 /elsewhere/$1;
+	}
+	return 404;
+}
+`, string(server))
+
+	var messages []string
+	for _, d := range diags {
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		"line 5: now applies before rule 2, which matches it too",
+		"line 10: skipped, proxying to https://api.example.com/:splat isn't supported",
+		"line 11: skipped, only a final catch-all rule can have status 410",
+	}, messages)
+}
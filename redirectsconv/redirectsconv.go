@@ -0,0 +1,128 @@
+// Package redirectsconv converts rules from and to the redirect
+// configurations of other hosts and servers: Netlify, Vercel, Firebase, S3,
+// nginx, Caddy, HAProxy, CloudFront, Fastly, GitHub Pages and the aliases of
+// static site generators. Conversions that can't be exact are reported with
+// diagnostics of code redirects.CodeLossyConversion. It's separate from the
+// redirects package so gateways and browser builds parsing _redirects files
+// don't pull in the formats' dependencies.
+package redirectsconv
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+	"github.com/ucarion/urlpath"
+)
+
+// An importer accumulates the rules converted from another configuration
+// format, and the diagnostics reporting what didn't convert exactly.
+type importer struct {
+	rules redirects.Rules
+	diags []redirects.Diagnostic
+}
+
+// add adds the rule from, to, status converted from the line-th line, unless
+// it doesn't parse. It returns whether the rule was added.
+func (imp *importer) add(line int, from, to string, status int) bool {
+	rules, err := redirects.ParseString(from + " " + to + " " + strconv.Itoa(status))
+	if err != nil {
+		var parseErr *redirects.ParseError
+		if errors.As(err, &parseErr) {
+			err = parseErr.Err
+		}
+		imp.warn(line, -1, "skipped, the converted rule is invalid: %v", err)
+		return false
+	}
+	rules[0].Line = 0
+	imp.rules = append(imp.rules, rules[0])
+	return true
+}
+
+// warn reports a lossy conversion of the line-th line, into the rule-th rule
+// or -1 if it was skipped.
+func (imp *importer) warn(line, rule int, format string, args ...any) {
+	imp.diags = append(imp.diags, redirects.Diagnostic{
+		Severity: redirects.SeverityWarning,
+		Code:     redirects.CodeLossyConversion,
+		Rule:     rule,
+		Line:     line,
+		Related:  -1,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// exportWarning returns the diagnostic reporting the lossy export of the
+// i-th rule.
+func exportWarning(rule redirects.Rule, i int, format string, args ...any) redirects.Diagnostic {
+	return redirects.Diagnostic{
+		Severity: redirects.SeverityWarning,
+		Code:     redirects.CodeLossyConversion,
+		Rule:     i,
+		Line:     rule.Line,
+		Related:  -1,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// mapPlaceholders returns to, the To of a rule whose From compiled into p,
+// with its placeholders replaced by placeholder(name) and its escaped colons
+// by colon, for exporting rules to other formats. It fails if a placeholder
+// is followed by a character another format would read as part of its name.
+func mapPlaceholders(to string, p *urlpath.Path, placeholder func(name string) string, colon string) (string, error) {
+	names := urlpattern.Placeholders(p)
+	escapes := urlpattern.EscapesStart(to)
+
+	var b strings.Builder
+	for i := 0; i < len(to); i++ {
+		if to[i] != ':' {
+			b.WriteByte(to[i])
+			continue
+		}
+		if i >= escapes && i+1 < len(to) && to[i+1] == ':' {
+			b.WriteString(colon)
+			i++
+			continue
+		}
+		ph, ok := urlpattern.At(to[i+1:], names)
+		if !ok {
+			b.WriteByte(':')
+			continue
+		}
+		i += len(ph.Name)
+		if i+1 < len(to) && isIdentByte(to[i+1]) {
+			return "", fmt.Errorf("placeholder :%s is followed by %q", ph.Name, to[i+1])
+		}
+		b.WriteString(placeholder(ph.Name))
+	}
+	return b.String(), nil
+}
+
+// fromRegexp returns a regular expression matching the paths from matches, in
+// the syntax JavaScript, RE2 and PCRE share, and the numbers of the groups
+// capturing its placeholders, keyed by name. It matches like RuleSet does:
+// placeholders match empty segments too, a splat needs the slash before it,
+// and paths with a trailing slash only match a splat.
+func fromRegexp(from string) (string, map[string]int) {
+	p := urlpattern.Compile(from)
+	groups := make(map[string]int)
+	segments := make([]string, len(p.Segments))
+	for i, seg := range p.Segments {
+		if seg.IsParam {
+			groups[seg.Param] = len(groups) + 1
+			segments[i] = "([^/]*)"
+		} else {
+			segments[i] = regexp.QuoteMeta(seg.Const)
+		}
+	}
+	pattern := "^" + strings.Join(segments, "/")
+	if p.Trailing {
+		groups["splat"] = len(groups) + 1
+		pattern += "/(.*)"
+	}
+	return pattern + "$", groups
+}
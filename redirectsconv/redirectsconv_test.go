@@ -0,0 +1,30 @@
+package redirectsconv
+
+import (
+	"regexp"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromRegexp(t *testing.T) {
+	paths := []string{"/", "/a", "/a/", "/a/b", "/a/b/", "/a//c", "/a/b/c", "/blog/2024/", "/blog//x"}
+	for _, from := range []string{"/a", "/a/", "/a/:x", "/a/:x/c", "/a/*", "/*", "/blog/:year/:slug", "/a.b/*"} {
+		pattern, groups := fromRegexp(from)
+		re := regexp.MustCompile(pattern)
+		set := redirects.Compile(redirects.Must(redirects.ParseString(from + " /to/:splat")))
+		for _, path := range paths {
+			rule, ok := set.Match(path)
+			m := re.FindStringSubmatch(path)
+			require.Equal(t, ok, m != nil, "%s matching %s", pattern, path)
+			if ok && groups["splat"] > 0 {
+				require.Equal(t, rule.To, "/to/"+m[groups["splat"]], "%s matching %s", pattern, path)
+			}
+		}
+	}
+
+	pattern, groups := fromRegexp("/blog/:year/:slug")
+	require.Equal(t, "^/blog/([^/]*)/([^/]*)$", pattern)
+	require.Equal(t, map[string]int{"year": 1, "slug": 2}, groups)
+}
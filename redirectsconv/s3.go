@@ -0,0 +1,177 @@
+package redirectsconv
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// An S3RoutingRule is a routing rule of the website configuration of an S3
+// bucket, as XML or as the JSON of the AWS CLI and console.
+type S3RoutingRule struct {
+	Condition *S3Condition `xml:"Condition" json:"Condition,omitempty"`
+	Redirect  S3Redirect   `xml:"Redirect" json:"Redirect"`
+}
+
+// An S3Condition is the condition of an S3RoutingRule, all keys match if
+// KeyPrefixEquals is empty, and all statuses if HttpErrorCodeReturnedEquals
+// is.
+type S3Condition struct {
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals" json:"KeyPrefixEquals,omitempty"`
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals" json:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+// An S3Redirect is where an S3RoutingRule redirects to. Protocol and HostName
+// default to those of the request, and the key to the one requested.
+type S3Redirect struct {
+	Protocol             string  `xml:"Protocol" json:"Protocol,omitempty"`
+	HostName             string  `xml:"HostName" json:"HostName,omitempty"`
+	ReplaceKeyPrefixWith *string `xml:"ReplaceKeyPrefixWith" json:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       *string `xml:"ReplaceKeyWith" json:"ReplaceKeyWith,omitempty"`
+
+	// HttpRedirectCode is the status, 301 if empty.
+	HttpRedirectCode string `xml:"HttpRedirectCode" json:"HttpRedirectCode,omitempty"`
+}
+
+// ImportS3 converts the routing rules of the website configuration of an S3
+// bucket into rules. It reads the XML of the RoutingRules element, alone or
+// in a WebsiteConfiguration, or their JSON, alone or in the
+// WebsiteConfiguration object of the AWS CLI. S3 applies routing rules to
+// keys with content too, the rules only apply to paths without, so rules
+// conditioned on a 404 convert exactly.
+//
+// A KeyPrefixEquals becomes a splat, matching the paths below the prefix, and
+// ReplaceKeyPrefixWith a destination with the splat. Rules conditioned on
+// errors other than 404 apply to every path without content instead, and
+// prefixes not ending with a slash no longer match the paths they're a prefix
+// of within a segment, which is reported with a diagnostic. Rules that can't
+// be represented, such as those redirecting a path to itself, are skipped and
+// reported. An error is only returned for invalid XML or JSON.
+func ImportS3(r io.Reader) (redirects.Rules, []redirects.Diagnostic, error) {
+	br := bufio.NewReader(r)
+	var routing []S3RoutingRule
+	if first, err := peekNonSpace(br); err != nil {
+		return nil, nil, err
+	} else if first == '<' {
+		var config struct {
+			Rules  []S3RoutingRule `xml:"RoutingRule"`
+			Nested []S3RoutingRule `xml:"RoutingRules>RoutingRule"`
+		}
+		if err := xml.NewDecoder(br).Decode(&config); err != nil {
+			return nil, nil, err
+		}
+		routing = append(config.Rules, config.Nested...)
+	} else if first == '[' {
+		if err := json.NewDecoder(br).Decode(&routing); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		var config struct {
+			RoutingRules         []S3RoutingRule
+			WebsiteConfiguration struct{ RoutingRules []S3RoutingRule }
+		}
+		if err := json.NewDecoder(br).Decode(&config); err != nil {
+			return nil, nil, err
+		}
+		routing = append(config.RoutingRules, config.WebsiteConfiguration.RoutingRules...)
+	}
+
+	var imp importer
+	for i, rule := range routing {
+		imp.s3Rule(fmt.Sprintf("RoutingRules[%d]", i), rule)
+	}
+	return imp.rules, imp.diags, nil
+}
+
+// peekNonSpace returns the first byte of r that isn't white space, without
+// consuming it.
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			return b, r.UnreadByte()
+		}
+	}
+}
+
+// s3Rule converts the routing rule at path.
+func (imp *importer) s3Rule(path string, rule S3RoutingRule) {
+	var cond S3Condition
+	if rule.Condition != nil {
+		cond = *rule.Condition
+	}
+
+	var lossy []string
+	switch cond.HttpErrorCodeReturnedEquals {
+	case "404":
+	case "":
+		lossy = append(lossy, "it no longer applies to paths with content")
+	default:
+		lossy = append(lossy, fmt.Sprintf("it applies to paths without content instead of those returning %s", cond.HttpErrorCodeReturnedEquals))
+	}
+
+	// the splat captures the key after the prefix, but for the slash
+	// separating it when the prefix doesn't end with one
+	prefix := cond.KeyPrefixEquals
+	from, rest := "/"+prefix+"*", ":splat"
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		from, rest = "/"+prefix+"/*", "/:splat"
+		lossy = append(lossy, fmt.Sprintf("paths beginning with %q but not %q no longer match", "/"+prefix, "/"+prefix+"/"))
+	}
+	if _, ok := urlpattern.Static(urlpattern.Compile("/" + prefix)); !ok {
+		imp.warn(0, -1, "%s: skipped, prefix %q would be parsed as placeholders", path, prefix)
+		return
+	}
+
+	redirect := rule.Redirect
+	var key string
+	switch {
+	case redirect.ReplaceKeyWith != nil:
+		key = *redirect.ReplaceKeyWith
+	case redirect.ReplaceKeyPrefixWith != nil:
+		key = strings.TrimPrefix(*redirect.ReplaceKeyPrefixWith+rest, "/")
+	case redirect.HostName == "" && redirect.Protocol == "":
+		imp.warn(0, -1, "%s: skipped, it redirects to the path requested", path)
+		return
+	default:
+		key = strings.TrimPrefix(prefix+rest, "/")
+	}
+
+	to := "/" + key
+	if redirect.HostName != "" {
+		protocol := redirect.Protocol
+		if protocol == "" {
+			protocol = "https"
+			lossy = append(lossy, "it redirects to https instead of the protocol of the request")
+		}
+		to = protocol + "://" + redirect.HostName + to
+	} else if redirect.Protocol != "" {
+		imp.warn(0, -1, "%s: skipped, redirecting to %s on the host of the request can't be represented", path, redirect.Protocol)
+		return
+	}
+
+	status := 301
+	if redirect.HttpRedirectCode != "" {
+		var err error
+		if status, err = strconv.Atoi(redirect.HttpRedirectCode); err != nil {
+			imp.warn(0, -1, "%s: skipped, invalid HttpRedirectCode %q", path, redirect.HttpRedirectCode)
+			return
+		}
+	}
+
+	if imp.add(0, from, to, status) {
+		for _, l := range lossy {
+			imp.warn(0, len(imp.rules)-1, "%s: %s", path, l)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package redirectsconv
+
+import (
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportS3(t *testing.T) {
+	rules, diags, err := ImportS3(strings.NewReader(`<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+  <RoutingRules>
+    <RoutingRule>
+      <Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition>
+      <Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect>
+    </RoutingRule>
+    <RoutingRule>
+      <Condition><KeyPrefixEquals>images/</KeyPrefixEquals></Condition>
+      <Redirect><ReplaceKeyWith>folderdeleted.html</ReplaceKeyWith><HttpRedirectCode>302</HttpRedirectCode></Redirect>
+    </RoutingRule>
+    <RoutingRule>
+      <Condition><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition>
+      <Redirect><Protocol>https</Protocol><HostName>ec2.example.com</HostName><ReplaceKeyPrefixWith>report-404/</ReplaceKeyPrefixWith></Redirect>
+    </RoutingRule>
+    <RoutingRule>
+      <Condition><KeyPrefixEquals>blog</KeyPrefixEquals><HttpErrorCodeReturnedEquals>403</HttpErrorCodeReturnedEquals></Condition>
+      <Redirect><HostName>blog.example.com</HostName></Redirect>
+    </RoutingRule>
+    <RoutingRule>
+      <Condition><KeyPrefixEquals>same/</KeyPrefixEquals></Condition>
+      <Redirect><HttpRedirectCode>307</HttpRedirectCode></Redirect>
+    </RoutingRule>
+    <RoutingRule>
+      <Redirect><ReplaceKeyWith>index.html</ReplaceKeyWith><HttpRedirectCode>999</HttpRedirectCode></Redirect>
+    </RoutingRule>
+  </RoutingRules>
+</WebsiteConfiguration>`))
+	require.NoError(t, err)
+	require.Equal(t, redirects.Rules{
+		{From: "/docs/*", To: "/documents/:splat", Status: 301},
+		{From: "/images/*", To: "/folderdeleted.html", Status: 302},
+		{From: "/*", To: "https://ec2.example.com/report-404/:splat", Status: 301},
+		{From: "/blog/*", To: "https://blog.example.com/blog/:splat", Status: 301},
+	}, rules)
+
+	var messages []string
+	for _, d := range diags {
+		require.Equal(t, redirects.CodeLossyConversion, d.Code)
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		"rule 1: RoutingRules[0]: it no longer applies to paths with content",
+		"rule 2: RoutingRules[1]: it no longer applies to paths with content",
+		"rule 4: RoutingRules[3]: it applies to paths without content instead of those returning 403",
+		`rule 4: RoutingRules[3]: paths beginning with "/blog" but not "/blog/" no longer match`,
+		"rule 4: RoutingRules[3]: it redirects to https instead of the protocol of the request",
+		"RoutingRules[4]: skipped, it redirects to the path requested",
+		"skipped, the converted rule is invalid: parsing status \"999\": status code 999 is not supported",
+	}, messages)
+}
+
+func TestImportS3JSON(t *testing.T) {
+	want := redirects.Rules{{From: "/docs/*", To: "/documents/:splat", Status: 301}}
+
+	for _, file := range []string{
+		`[{"Condition": {"KeyPrefixEquals": "docs/", "HttpErrorCodeReturnedEquals": "404"}, "Redirect": {"ReplaceKeyPrefixWith": "documents/"}}]`,
+		`{"RoutingRules": [{"Condition": {"KeyPrefixEquals": "docs/", "HttpErrorCodeReturnedEquals": "404"}, "Redirect": {"ReplaceKeyPrefixWith": "documents/"}}]}`,
+		`{"WebsiteConfiguration": {"RoutingRules": [{"Condition": {"KeyPrefixEquals": "docs/", "HttpErrorCodeReturnedEquals": "404"}, "Redirect": {"ReplaceKeyPrefixWith": "documents/"}}]}}`,
+		`<RoutingRules><RoutingRule><Condition><KeyPrefixEquals>docs/</KeyPrefixEquals><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition><Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect></RoutingRule></RoutingRules>`,
+	} {
+		rules, diags, err := ImportS3(strings.NewReader(file))
+		require.NoError(t, err, file)
+		require.Empty(t, diags, file)
+		require.Equal(t, want, rules, file)
+	}
+
+	_, _, err := ImportS3(strings.NewReader(`<RoutingRules>`))
+	require.Error(t, err)
+	_, _, err = ImportS3(strings.NewReader(`{"RoutingRules": 1}`))
+	require.Error(t, err)
+}
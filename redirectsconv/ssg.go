@@ -0,0 +1,169 @@
+package redirectsconv
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// ImportAliases converts the aliases of the pages of a static site, like the
+// aliases front matter of Hugo or the redirect_from of Jekyll, keyed by the
+// URL of their page, into 301 redirects to the pages, sorted by From. Gateways
+// then redirect the old paths themselves, instead of serving the pages with a
+// meta refresh the generators write there.
+//
+// Aliases not starting with a slash are relative to the directory of their
+// page, like Hugo resolves them. Aliases of several pages for the same path,
+// which match regardless of a trailing slash, are only kept for the first
+// page in order of URL, and reported with a diagnostic, as are aliases that
+// can't be represented, like URLs and paths with placeholders.
+func ImportAliases(pages map[string][]string) (redirects.Rules, []redirects.Diagnostic) {
+	var imp importer
+	type alias struct{ from, page string }
+	var aliases []alias
+	seen := make(map[string]string)
+	urls := make([]string, 0, len(pages))
+	for page := range pages {
+		urls = append(urls, page)
+	}
+	slices.Sort(urls)
+	for _, page := range urls {
+		for _, a := range pages[page] {
+			from, err := aliasFrom(strings.TrimSpace(a), page)
+			if err != nil {
+				imp.warn(0, -1, "page %q: skipped alias %q: %v", page, a, err)
+				continue
+			}
+			key := aliasKey(from)
+			if key == aliasKey(page) {
+				imp.warn(0, -1, "page %q: skipped alias %q of the page itself", page, a)
+				continue
+			}
+			switch other, ok := seen[key]; {
+			case ok && other == page:
+			case ok:
+				imp.warn(0, -1, "page %q: skipped alias %q, already an alias of %q", page, a, other)
+			default:
+				seen[key] = page
+				aliases = append(aliases, alias{from, page})
+			}
+		}
+	}
+
+	slices.SortStableFunc(aliases, func(a, b alias) int { return strings.Compare(a.from, b.from) })
+	for _, a := range aliases {
+		imp.add(0, a.from, a.page, 301)
+	}
+	return imp.rules, imp.diags
+}
+
+// ImportAliasesJSON is ImportAliases reading the aliases from JSON, either an
+// object keyed by the URL of the pages with their alias or list of aliases,
+// or the list of the front matter of the pages, with their URL in url or
+// permalink and their aliases in aliases or redirect_from:
+//
+//	{"/posts/new/": ["/old", "/older"], "/about/": "/about-us"}
+//	[{"url": "/posts/new/", "redirect_from": ["/old", "/older"]}]
+//
+// An error is only returned for invalid JSON.
+func ImportAliasesJSON(r io.Reader) (redirects.Rules, []redirects.Diagnostic, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, nil, err
+	}
+
+	pages := make(map[string][]string)
+	if raw = bytes.TrimSpace(raw); len(raw) > 0 && raw[0] == '[' {
+		var matters []struct {
+			URL          string          `json:"url"`
+			Permalink    string          `json:"permalink"`
+			Aliases      json.RawMessage `json:"aliases"`
+			RedirectFrom json.RawMessage `json:"redirect_from"`
+		}
+		if err := json.Unmarshal(raw, &matters); err != nil {
+			return nil, nil, err
+		}
+		for i, m := range matters {
+			page := cmp.Or(m.URL, m.Permalink)
+			if page == "" {
+				return nil, nil, fmt.Errorf("page %d has no url or permalink", i)
+			}
+			for _, list := range []json.RawMessage{m.Aliases, m.RedirectFrom} {
+				aliases, err := stringOrList(list)
+				if err != nil {
+					return nil, nil, fmt.Errorf("page %q: %w", page, err)
+				}
+				pages[page] = append(pages[page], aliases...)
+			}
+		}
+	} else {
+		var lists map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &lists); err != nil {
+			return nil, nil, err
+		}
+		for page, list := range lists {
+			aliases, err := stringOrList(list)
+			if err != nil {
+				return nil, nil, fmt.Errorf("page %q: %w", page, err)
+			}
+			pages[page] = aliases
+		}
+	}
+
+	rules, diags := ImportAliases(pages)
+	return rules, diags, nil
+}
+
+// stringOrList decodes raw, a string or a list of strings, or nothing.
+func stringOrList(raw json.RawMessage) ([]string, error) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) == 0 || string(raw) == "null":
+		return nil, nil
+	case raw[0] == '"':
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return []string{s}, err
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("aliases must be a string or a list of strings")
+	}
+	return list, nil
+}
+
+// aliasFrom returns the From redirecting alias of page.
+func aliasFrom(alias, page string) (string, error) {
+	switch {
+	case alias == "":
+		return "", fmt.Errorf("it's empty")
+	case strings.Contains(alias, "://"):
+		return "", fmt.Errorf("only paths of the site can redirect")
+	case !strings.HasPrefix(alias, "/"):
+		if !strings.HasPrefix(page, "/") {
+			return "", fmt.Errorf("it's relative to a page that isn't a path")
+		}
+		alias = path.Join(path.Dir(strings.TrimSuffix(page, "/")), alias)
+	}
+	if _, ok := urlpattern.Static(urlpattern.Compile(alias)); !ok {
+		return "", fmt.Errorf("it would match other paths")
+	}
+	return alias, nil
+}
+
+// aliasKey returns the path from matches, which rules match regardless of a
+// trailing slash.
+func aliasKey(from string) string {
+	if key := strings.TrimSuffix(from, "/"); key != "" {
+		return key
+	}
+	return "/"
+}
@@ -0,0 +1,69 @@
+package redirectsconv
+
+import (
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportAliases(t *testing.T) {
+	rules, diags := ImportAliases(map[string][]string{
+		"/posts/new/": {"/old", "old-name", "/old/", "/posts/new"},
+		"/about/":     {"/about-us.html", "/old", "https://example.com/about", "/team/:name"},
+		"/":           {"/home"},
+	})
+	require.Equal(t, redirects.Rules{
+		{From: "/about-us.html", To: "/about/", Status: 301},
+		{From: "/home", To: "/", Status: 301},
+		{From: "/old", To: "/about/", Status: 301},
+		{From: "/posts/old-name", To: "/posts/new/", Status: 301},
+	}, rules)
+
+	var messages []string
+	for _, d := range diags {
+		require.Equal(t, redirects.CodeLossyConversion, d.Code)
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		`page "/about/": skipped alias "https://example.com/about": only paths of the site can redirect`,
+		`page "/about/": skipped alias "/team/:name": it would match other paths`,
+		`page "/posts/new/": skipped alias "/old", already an alias of "/about/"`,
+		`page "/posts/new/": skipped alias "/old/", already an alias of "/about/"`,
+		`page "/posts/new/": skipped alias "/posts/new" of the page itself`,
+	}, messages)
+
+	rules, diags = ImportAliases(nil)
+	require.Empty(t, rules)
+	require.Empty(t, diags)
+}
+
+func TestImportAliasesJSON(t *testing.T) {
+	want := redirects.Rules{
+		{From: "/about-us", To: "/about/", Status: 301},
+		{From: "/old", To: "/posts/new/", Status: 301},
+		{From: "/older", To: "/posts/new/", Status: 301},
+	}
+
+	rules, diags, err := ImportAliasesJSON(strings.NewReader(`{"/posts/new/": ["/old", "/older"], "/about/": "/about-us"}`))
+	require.NoError(t, err)
+	require.Empty(t, diags)
+	require.Equal(t, want, rules)
+
+	rules, diags, err = ImportAliasesJSON(strings.NewReader(`[
+		{"url": "/posts/new/", "redirect_from": ["/old", "/older"]},
+		{"permalink": "/about/", "aliases": "/about-us", "title": "About"},
+		{"url": "/contact/"}
+	]`))
+	require.NoError(t, err)
+	require.Empty(t, diags)
+	require.Equal(t, want, rules)
+
+	_, _, err = ImportAliasesJSON(strings.NewReader(`[{"title": "About"}]`))
+	require.EqualError(t, err, "page 0 has no url or permalink")
+	_, _, err = ImportAliasesJSON(strings.NewReader(`{"/about/": 1}`))
+	require.EqualError(t, err, `page "/about/": aliases must be a string or a list of strings`)
+	_, _, err = ImportAliasesJSON(strings.NewReader(`{`))
+	require.Error(t, err)
+}
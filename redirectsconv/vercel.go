@@ -0,0 +1,272 @@
+package redirectsconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// A VercelConfig holds the redirects and rewrites of a vercel.json file.
+type VercelConfig struct {
+	Redirects []VercelRedirect `json:"redirects,omitempty"`
+	Rewrites  []VercelRewrite  `json:"rewrites,omitempty"`
+}
+
+// A VercelRedirect is an entry of the redirects of a vercel.json file.
+type VercelRedirect struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+
+	// Permanent selects a 308 status if true, 307 if false. It's true if
+	// nil, unless StatusCode is set.
+	Permanent  *bool `json:"permanent,omitempty"`
+	StatusCode int   `json:"statusCode,omitempty"`
+
+	// Has and Missing are the conditions on the request, which rules can't
+	// represent.
+	Has     json.RawMessage `json:"has,omitempty"`
+	Missing json.RawMessage `json:"missing,omitempty"`
+}
+
+// A VercelRewrite is an entry of the rewrites of a vercel.json file.
+type VercelRewrite struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+
+	// Has and Missing are the conditions on the request, which rules can't
+	// represent.
+	Has     json.RawMessage `json:"has,omitempty"`
+	Missing json.RawMessage `json:"missing,omitempty"`
+}
+
+// ImportVercel converts the redirects and rewrites of a vercel.json file into
+// rules, the redirects first since Vercel applies them first. Vercel applies
+// redirects to paths with content too, the rules only apply to paths without.
+//
+// Sources convert when their parameters capture whole segments, like :slug,
+// or the rest of the path, like :path* or (.*), which become placeholders and
+// splats. Entries that can't be represented, such as those with conditions,
+// are skipped and reported with a diagnostic, as are conversions that match
+// slightly different paths. An error is only returned for invalid JSON.
+func ImportVercel(r io.Reader) (redirects.Rules, []redirects.Diagnostic, error) {
+	var config VercelConfig
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return nil, nil, err
+	}
+
+	var imp importer
+	for i, redirect := range config.Redirects {
+		status := 308
+		switch {
+		case redirect.StatusCode != 0:
+			status = redirect.StatusCode
+		case redirect.Permanent != nil && !*redirect.Permanent:
+			status = 307
+		}
+		imp.vercelEntry(fmt.Sprintf("redirects[%d]", i), redirect.Source, redirect.Destination, status, redirect.Has, redirect.Missing)
+	}
+	for i, rewrite := range config.Rewrites {
+		imp.vercelEntry(fmt.Sprintf("rewrites[%d]", i), rewrite.Source, rewrite.Destination, 200, rewrite.Has, rewrite.Missing)
+	}
+	return imp.rules, imp.diags, nil
+}
+
+// vercelEntry converts the entry of a vercel.json file at path.
+func (imp *importer) vercelEntry(path, source, destination string, status int, has, missing json.RawMessage) {
+	if len(has) > 0 || len(missing) > 0 {
+		imp.warn(0, -1, "%s: skipped, conditions on the request can't be represented", path)
+		return
+	}
+	from, params, lossy, err := vercelSource(source)
+	if err != nil {
+		imp.warn(0, -1, "%s: skipped: %v", path, err)
+		return
+	}
+	to, err := paramDestination(destination, params)
+	if err != nil {
+		imp.warn(0, -1, "%s: skipped: %v", path, err)
+		return
+	}
+	if imp.add(0, from, to, status) && lossy != "" {
+		imp.warn(0, len(imp.rules)-1, "%s: %s", path, lossy)
+	}
+}
+
+// vercelSource converts source, a path-to-regexp pattern, into a From and the
+// names of the placeholders its parameters capture, keyed by parameter name,
+// or number for unnamed groups. lossy describes how From matches different
+// paths, if it does.
+func vercelSource(source string) (from string, params map[string]string, lossy string, err error) {
+	if !strings.HasPrefix(source, "/") {
+		return "", nil, "", fmt.Errorf("source %q isn't a path", source)
+	}
+	params = make(map[string]string)
+	segments := strings.Split(source[1:], "/")
+	groups := 0
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch {
+		case seg == "(.*)" && last:
+			groups++
+			params[strconv.Itoa(groups)] = "splat"
+			segments[i] = "*"
+		case strings.HasPrefix(seg, ":"):
+			end := 1
+			for end < len(seg) && isIdentByte(seg[end]) {
+				end++
+			}
+			name, modifier := seg[1:end], seg[end:]
+			if name == "" {
+				return "", nil, "", fmt.Errorf("source %q has a parameter without a name", source)
+			}
+			switch {
+			case modifier == "":
+				params[name] = name
+				segments[i] = ":" + name
+			case (modifier == "*" || modifier == "+" || modifier == "(.*)") && last:
+				params[name] = "splat"
+				segments[i] = "*"
+				if modifier == "*" {
+					lossy = fmt.Sprintf("%q no longer matches", "/"+strings.Join(segments[:i], "/"))
+				}
+			case strings.HasPrefix(modifier, "(") && strings.HasSuffix(modifier, ")"):
+				params[name] = name
+				segments[i] = ":" + name
+				lossy = fmt.Sprintf(":%s matches any segment, not only %s", name, modifier)
+			default:
+				return "", nil, "", fmt.Errorf("parameter %s of source %q can't be represented", seg, source)
+			}
+		case strings.ContainsAny(vercelUnescape(seg), ":()*?+{}"):
+			return "", nil, "", fmt.Errorf("segment %q of source %q can't be represented", seg, source)
+		default:
+			segments[i] = strings.ReplaceAll(seg, `\`, "")
+		}
+	}
+	return "/" + strings.Join(segments, "/"), params, lossy, nil
+}
+
+// paramDestination converts destination, which refers to parameters like
+// :name, :name* or $1 the way Vercel and Firebase do, into a To, with the
+// references replaced by the placeholders in params they capture.
+func paramDestination(destination string, params map[string]string) (string, error) {
+	escapes := urlpattern.EscapesStart(destination)
+
+	var b strings.Builder
+	for i := 0; i < len(destination); i++ {
+		c := destination[i]
+		if c == '\\' && i+1 < len(destination) && destination[i+1] == ':' {
+			// an escaped colon, escaped the rules' way
+			b.WriteString("::")
+			i++
+			continue
+		}
+		if c != ':' && c != '$' {
+			b.WriteByte(c)
+			continue
+		}
+		end := i + 1
+		for end < len(destination) && isIdentByte(destination[end]) {
+			end++
+		}
+		if end == i+1 || (c == ':' && i < escapes) {
+			b.WriteByte(c)
+			continue
+		}
+		ref := destination[i+1 : end]
+		name, ok := params[ref]
+		if !ok {
+			if c == '$' {
+				b.WriteByte(c)
+				continue
+			}
+			return "", fmt.Errorf("destination %q refers to undefined parameter :%s", destination, ref)
+		}
+		b.WriteString(":" + name)
+		if end < len(destination) && (destination[end] == '*' || destination[end] == '+') {
+			end++
+		}
+		i = end - 1
+	}
+	return b.String(), nil
+}
+
+// ExportVercel converts rules into the redirects and rewrites of a
+// vercel.json file. Rules that can't be represented, like 404 rules, are
+// skipped and reported with a diagnostic. Redirects following rewrites are
+// reported too, Vercel applies all the redirects first.
+func ExportVercel(rules redirects.Rules) (VercelConfig, []redirects.Diagnostic) {
+	var config VercelConfig
+	var diags []redirects.Diagnostic
+	rewrites := false
+	for i, rule := range rules {
+		p := urlpattern.Compile(rule.From)
+		source := vercelPattern(rule.From)
+		destination, err := mapPlaceholders(rule.To, p, func(name string) string {
+			if name == "splat" && p.Trailing {
+				return ":splat*"
+			}
+			return ":" + name
+		}, `\:`)
+		if err != nil {
+			diags = append(diags, exportWarning(rule, i, "skipped: %v", err))
+			continue
+		}
+
+		switch {
+		case rule.Status >= 300 && rule.Status < 400:
+			redirect := VercelRedirect{Source: source, Destination: destination, StatusCode: rule.Status}
+			if rule.Status == 307 || rule.Status == 308 {
+				permanent := rule.Status == 308
+				redirect.Permanent, redirect.StatusCode = &permanent, 0
+			}
+			if rewrites {
+				diags = append(diags, exportWarning(rule, i, "redirect now applies before the rewrites preceding it"))
+			}
+			config.Redirects = append(config.Redirects, redirect)
+		case rule.Status == 200:
+			rewrites = true
+			config.Rewrites = append(config.Rewrites, VercelRewrite{Source: source, Destination: destination})
+		default:
+			diags = append(diags, exportWarning(rule, i, "skipped, status %d can't be represented", rule.Status))
+		}
+	}
+	return config, diags
+}
+
+// vercelPattern returns from as a path-to-regexp pattern.
+func vercelPattern(from string) string {
+	segments := strings.Split(from, "/")
+	for i, seg := range segments {
+		switch {
+		case seg == "*" && i == len(segments)-1:
+			segments[i] = ":splat*"
+		case strings.HasPrefix(seg, ":"):
+		default:
+			segments[i] = vercelEscaper.Replace(seg)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// vercelUnescape returns s without its escaped characters.
+func vercelUnescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// vercelEscaper escapes the characters path-to-regexp treats specially.
+var vercelEscaper = strings.NewReplacer(
+	":", `\:`, "(", `\(`, ")", `\)`, "*", `\*`, "?", `\?`, "+", `\+`, "{", `\{`, "}", `\}`,
+)
@@ -0,0 +1,96 @@
+package redirectsconv
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportVercel(t *testing.T) {
+	rules, diags, err := ImportVercel(strings.NewReader(`{
+  "redirects": [
+    {"source": "/old", "destination": "/new"},
+    {"source": "/blog/:slug", "destination": "/posts/:slug", "permanent": false},
+    {"source": "/docs/:path*", "destination": "https://docs.example.com/:path*", "statusCode": 301},
+    {"source": "/user/:id(\\d+)", "destination": "/profile/:id", "statusCode": 302},
+    {"source": "/beta", "destination": "/", "has": [{"type": "cookie", "key": "beta"}]},
+    {"source": "/:lang?/about", "destination": "/about"}
+  ],
+  "rewrites": [
+    {"source": "/api/(.*)", "destination": "https://api.example.com/$1"},
+    {"source": "/app/:rest+", "destination": "/app/index.html"},
+    {"source": "/a/:b", "destination": "/c/:d"}
+  ]
+}`))
+	require.NoError(t, err)
+	require.Equal(t, redirects.Rules{
+		{From: "/old", To: "/new", Status: 308},
+		{From: "/blog/:slug", To: "/posts/:slug", Status: 307},
+		{From: "/docs/*", To: "https://docs.example.com/:splat", Status: 301},
+		{From: "/user/:id", To: "/profile/:id", Status: 302},
+		{From: "/api/*", To: "https://api.example.com/:splat", Status: 200},
+		{From: "/app/*", To: "/app/index.html", Status: 200},
+	}, rules)
+
+	var messages []string
+	for _, d := range diags {
+		require.Equal(t, redirects.CodeLossyConversion, d.Code)
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		`rule 3: redirects[2]: "/docs" no longer matches`,
+		`rule 4: redirects[3]: :id matches any segment, not only (\d+)`,
+		"redirects[4]: skipped, conditions on the request can't be represented",
+		`redirects[5]: skipped: parameter :lang? of source "/:lang?/about" can't be represented`,
+		`rewrites[2]: skipped: destination "/c/:d" refers to undefined parameter :d`,
+	}, messages)
+
+	_, _, err = ImportVercel(strings.NewReader(`{"redirects": {}}`))
+	require.Error(t, err)
+}
+
+func TestExportVercel(t *testing.T) {
+	rules := redirects.Must(redirects.ParseString(`
+/old          /new                     301
+/docs/*       /documentation/:splat    308
+/talks/:id    /schedule/10::am/:id     307
+/app/*        /index.html              200
+/gone         /410.html                410
+/blog/:slug   /posts/:slugs            302
+/later        /x                       302
+`))
+	config, diags := ExportVercel(rules)
+
+	b, err := json.Marshal(config)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "redirects": [
+    {"source": "/old", "destination": "/new", "statusCode": 301},
+    {"source": "/docs/:splat*", "destination": "/documentation/:splat*", "permanent": true},
+    {"source": "/talks/:id", "destination": "/schedule/10\\:am/:id", "permanent": false},
+    {"source": "/later", "destination": "/x", "statusCode": 302}
+  ],
+  "rewrites": [
+    {"source": "/app/:splat*", "destination": "/index.html"}
+  ]
+}`, string(b))
+
+	var messages []string
+	for _, d := range diags {
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		"line 6: skipped, status 410 can't be represented",
+		"line 7: skipped: placeholder :slug is followed by 's'",
+		"line 8: redirect now applies before the rewrites preceding it",
+	}, messages)
+
+	// exported rules import back
+	imported, _, err := ImportVercel(strings.NewReader(string(b)))
+	require.NoError(t, err)
+	require.Len(t, imported, 5)
+	require.Equal(t, "/schedule/10::am/:id", imported[2].To)
+}
@@ -0,0 +1,146 @@
+// Package redirectsipld stores rules as IPLD data, so they can be published,
+// pinned and fetched as a dag-cbor block rather than a _redirects file to
+// parse. The data follows Schema. The codec is the subset of dag-cbor the
+// module shares with its CAR reader rather than go-ipld-prime, keeping the
+// module free of dependencies: blocks it encodes decode with the dag-cbor
+// codec of go-ipld-prime and the other IPLD implementations, and it decodes
+// the blocks they encode from data of that shape.
+package redirectsipld
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/cbor"
+	"github.com/ipfs/go-ipfs-redirects-file/internal/unixfs"
+)
+
+// Schema is the IPLD schema of the data, in the schema DSL. A block holds a
+// Rules list.
+const Schema = `type Rules [Rule]
+
+type Rule struct {
+	from String
+	to String
+	status Int
+	force optional Bool
+} representation map
+`
+
+// Encode returns the dag-cbor encoding of rules, with the fields of each rule
+// in canonical order and force only present for forced rules, so the same
+// rules always encode to the same block. Lines aren't encoded.
+func Encode(rules redirects.Rules) []byte {
+	var b []byte
+	b = cbor.AppendHead(b, cbor.MajorList, uint64(len(rules)))
+	for _, rule := range rules {
+		fields := uint64(3)
+		if rule.Force {
+			fields++
+		}
+		// keys sorted by length then bytes, like dag-cbor requires
+		b = cbor.AppendHead(b, cbor.MajorMap, fields)
+		b = cbor.AppendString(b, "to")
+		b = cbor.AppendString(b, rule.To)
+		b = cbor.AppendString(b, "from")
+		b = cbor.AppendString(b, rule.From)
+		if rule.Force {
+			b = cbor.AppendString(b, "force")
+			b = append(b, cbor.True)
+		}
+		b = cbor.AppendString(b, "status")
+		b = cbor.AppendHead(b, cbor.MajorUint, uint64(rule.Status))
+	}
+	return b
+}
+
+// CID returns the CIDv1 of the dag-cbor block data, with sha2-256, in its
+// base32 string form.
+func CID(data []byte) string {
+	return unixfs.CIDString(unixfs.DagCBOR(data).CID)
+}
+
+// Decode returns the rules of the dag-cbor block data, which must follow
+// Schema and be valid dag-cbor. Blocks may come from anyone, so the rules are
+// then checked like a _redirects file parsed with opts, and have the lines
+// they'd have in their canonical _redirects form.
+func Decode(data []byte, opts ...redirects.Option) (redirects.Rules, error) {
+	d := decoder{cbor.Decoder{Data: data}}
+	n := d.Head(cbor.MajorList)
+	// every rule takes at least 20 bytes, don't trust larger counts
+	if d.Err == nil && n > uint64(len(d.Data))/20 {
+		return nil, errors.New("invalid rules block: rule count exceeds data")
+	}
+
+	rules := make(redirects.Rules, 0, n)
+	for i := uint64(0); i < n && d.Err == nil; i++ {
+		rules = append(rules, d.rule())
+	}
+	if d.Err == nil && len(d.Data) > 0 {
+		d.Fail("trailing data")
+	}
+	if d.Err != nil {
+		return nil, fmt.Errorf("invalid rules block: %w", d.Err)
+	}
+
+	// fields with whitespace would read as other fields or rules, empty
+	// fields as the next one and a from starting with # as a comment
+	for i, rule := range rules {
+		if strings.ContainsFunc(rule.From, unicode.IsSpace) || strings.ContainsFunc(rule.To, unicode.IsSpace) {
+			return nil, fmt.Errorf("invalid rules block: rule %d has whitespace in a path", i)
+		}
+		if rule.From == "" || rule.To == "" || rule.From[0] == '#' {
+			return nil, fmt.Errorf("invalid rules block: rule %d has an empty from or to, or a from starting with #", i)
+		}
+	}
+	var b bytes.Buffer
+	rules.WriteTo(&b)
+	return redirects.ParseBytes(b.Bytes(), opts...)
+}
+
+// decoder reads the rules of a block.
+type decoder struct {
+	cbor.Decoder
+}
+
+// rule reads a Rule map.
+func (d *decoder) rule() redirects.Rule {
+	var rule redirects.Rule
+	n := d.Head(cbor.MajorMap)
+	var prev string
+	var hasFrom, hasTo, hasStatus bool
+	for i := uint64(0); i < n && d.Err == nil; i++ {
+		key := d.Text()
+		if i > 0 && (len(key) < len(prev) || len(key) == len(prev) && key <= prev) {
+			d.Fail("map keys not in canonical order")
+			break
+		}
+		prev = key
+
+		switch key {
+		case "from":
+			rule.From, hasFrom = d.Text(), true
+		case "to":
+			rule.To, hasTo = d.Text(), true
+		case "status":
+			status := d.Head(cbor.MajorUint)
+			if status > math.MaxInt32 {
+				d.Fail("status %d out of range", status)
+			}
+			rule.Status, hasStatus = int(status), true
+		case "force":
+			rule.Force = d.Bool()
+		default:
+			d.Fail("unknown field %q", key)
+		}
+	}
+	if d.Err == nil && !(hasFrom && hasTo && hasStatus) {
+		d.Fail("rule without from, to or status")
+	}
+	return rule
+}
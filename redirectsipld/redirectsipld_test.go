@@ -0,0 +1,86 @@
+package redirectsipld
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+)
+
+func TestEncode(t *testing.T) {
+	require.Equal(t, []byte{0x80}, Encode(nil))
+	require.Equal(t, "bafyreidwx2fvfdiaox32v2mnn6sxu3j4qoxeqcuenhtgrv5qv6litfnmoe", CID(Encode(nil)))
+
+	// [{"to": "/b", "from": "/a", "status": 301}]
+	require.Equal(t, []byte("\x81\xa3\x62to\x62/b\x64from\x62/a\x66status\x19\x01\x2d"),
+		Encode(redirects.Rules{{From: "/a", To: "/b", Status: 301, Line: 1}}))
+
+	// [{"to": "/b", "from": "/a", "force": true, "status": 200}]
+	require.Equal(t, []byte("\x81\xa4\x62to\x62/b\x64from\x62/a\x65force\xf5\x66status\x18\xc8"),
+		Encode(redirects.Rules{{From: "/a", To: "/b", Status: 200, Force: true}}))
+}
+
+func TestDecode(t *testing.T) {
+	rules := redirects.Rules(redirects.Must(redirects.ParseString(`
+	/old        /new                         301
+	/app/*      /index.html                  200!
+	/api/:x     https://api.example.com/:x   200
+	/gone       /gone.html                   410
+	`, redirects.WithAllowForced())))
+
+	decoded, err := Decode(Encode(rules), redirects.WithAllowForced())
+	require.NoError(t, err)
+	for i := range rules {
+		rules[i].Line = i + 1
+	}
+	require.Equal(t, rules, decoded)
+
+	_, err = Decode(Encode(rules))
+	require.ErrorContains(t, err, "forced redirects")
+
+	decoded, err = Decode([]byte{0x80})
+	require.NoError(t, err)
+	require.Empty(t, decoded)
+
+	for _, tc := range []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"not a list", "\xa0", "invalid rules block: got CBOR major type 5, want 4"},
+		{"truncated", "\x81\xa3\x62to", "invalid rules block: rule count exceeds data"},
+		{"trailing data", "\x80\x00", "invalid rules block: trailing data"},
+		{"long count", "\x98\x01" + rule301, "invalid rules block: integer 1 not in its shortest form"},
+		{"unsorted keys", "\x81\xa3\x64from\x62/a\x62to\x62/b\x66status\x19\x01\x2d", "invalid rules block: map keys not in canonical order"},
+		{"unknown field", "\x81\xa4\x62to\x62/b\x64from\x62/a\x66status\x19\x01\x2d\x67headers\xa0", "invalid rules block: unknown field \"headers\""},
+		{"missing status", "\x81\xa2\x62to\x62/b\x64from\x62/a\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00", "invalid rules block: rule without from, to or status"},
+		{"invalid force", "\x81\xa4\x62to\x62/b\x64from\x62/a\x65force\x01\x66status\x19\x01\x2d", "invalid rules block: got CBOR item 0x01, want a boolean"},
+		{"smuggled rule", "\x81\xa3\x62to\x6c/b 302\n/c /d\x64from\x62/a\x66status\x19\x01\x2d", "invalid rules block: rule 0 has whitespace in a path"},
+		{"comment", "\x81\xa3\x62to\x62/b\x64from\x62#a\x66status\x19\x01\x2d", "invalid rules block: rule 0 has an empty from or to, or a from starting with #"},
+		{"empty from", "\x81\xa3\x62to\x62/b\x64from\x60\x66status\x19\x01\x2d", "invalid rules block: rule 0 has an empty from or to, or a from starting with #"},
+		{"invalid UTF-8", "\x81\xa3\x62to\x62/\xff\x64from\x62/a\x66status\x19\x01\x2d", "invalid rules block: text string is not valid UTF-8"},
+		{"invalid status", "\x81\xa3\x62to\x62/b\x64from\x62/a\x66status\x19\x03\xe7", "status code 999 is not supported"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Decode([]byte(tc.data))
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+// rule301 is the encoding of {"to": "/b", "from": "/a", "status": 301}.
+const rule301 = "\xa3\x62to\x62/b\x64from\x62/a\x66status\x19\x01\x2d"
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte("\x81" + rule301))
+	f.Add(Encode(redirects.Rules{{From: "/a/*", To: "/b/:splat", Status: 200, Force: true}}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rules, err := Decode(data, redirects.WithAllowForced())
+		if err != nil {
+			return
+		}
+		// blocks that decode are canonical, they encode back the same
+		require.Equal(t, data, Encode(rules))
+	})
+}
@@ -0,0 +1,143 @@
+// Package redirectsprom exposes the metrics of redirects.MetricsSink in the
+// Prometheus text format, without depending on the Prometheus client
+// library:
+//
+//   - redirects_evaluations_total, the paths evaluated, by site
+//   - redirects_matches_total, the paths a rule matched, by site and status
+//     class, like "3xx"
+//   - redirects_parse_errors_total, the files that failed to parse, by site
+//     and error code
+//
+// A Collector is an http.Handler serving them, for Prometheus to scrape.
+package redirectsprom
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+)
+
+// A Collector collects the metrics of sites. The zero value is an empty
+// Collector.
+type Collector struct {
+	mu    sync.Mutex
+	sites map[string]*Sink
+}
+
+// Site returns the sink of the site named name, labeled site="name", to
+// pass to redirects.WithMetrics for that site. Sites sharing a name share a
+// sink.
+func (c *Collector) Site(name string) *Sink {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.sites[name]; ok {
+		return s
+	}
+	if c.sites == nil {
+		c.sites = make(map[string]*Sink)
+	}
+	s := &Sink{}
+	c.sites[name] = s
+	return s
+}
+
+// WriteTo writes the metrics of the sites to w in the Prometheus text
+// format, sorted by site.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.sites))
+	for name := range c.sites {
+		names = append(names, name)
+	}
+	sinks := make([]*Sink, len(names))
+	slices.Sort(names)
+	for i, name := range names {
+		sinks[i] = c.sites[name]
+	}
+	c.mu.Unlock()
+
+	var b bytes.Buffer
+	b.WriteString("# HELP redirects_evaluations_total Paths evaluated against the rules of a site.\n")
+	b.WriteString("# TYPE redirects_evaluations_total counter\n")
+	for i, s := range sinks {
+		fmt.Fprintf(&b, "redirects_evaluations_total{site=%s} %d\n", label(names[i]), s.evaluations.Load())
+	}
+	b.WriteString("# HELP redirects_matches_total Paths matched by a rule of a site, by status class.\n")
+	b.WriteString("# TYPE redirects_matches_total counter\n")
+	for i, s := range sinks {
+		for class := range s.matches {
+			if n := s.matches[class].Load(); n > 0 {
+				fmt.Fprintf(&b, "redirects_matches_total{site=%s,class=\"%dxx\"} %d\n", label(names[i]), class+1, n)
+			}
+		}
+	}
+	b.WriteString("# HELP redirects_parse_errors_total Files of a site that failed to parse, by error code.\n")
+	b.WriteString("# TYPE redirects_parse_errors_total counter\n")
+	for i, s := range sinks {
+		s.mu.Lock()
+		codes := make([]string, 0, len(s.parseErrors))
+		for code := range s.parseErrors {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+		for _, code := range codes {
+			fmt.Fprintf(&b, "redirects_parse_errors_total{site=%s,code=%s} %d\n", label(names[i]), label(code), s.parseErrors[code])
+		}
+		s.mu.Unlock()
+	}
+	return b.WriteTo(w)
+}
+
+// ServeHTTP serves the metrics of the sites.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+// label returns v as a label value.
+func label(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// A Sink is the redirects.MetricsSink of a site.
+type Sink struct {
+	evaluations atomic.Uint64
+
+	// matches counts the matches by status class, from 1xx to 5xx
+	matches [5]atomic.Uint64
+
+	mu          sync.Mutex
+	parseErrors map[string]uint64
+}
+
+var _ redirects.MetricsSink = (*Sink)(nil)
+
+// Evaluated counts an evaluation, and a match if status isn't zero.
+func (s *Sink) Evaluated(status int) {
+	s.evaluations.Add(1)
+	if class := status / 100; class >= 1 && class <= len(s.matches) {
+		s.matches[class-1].Add(1)
+	}
+}
+
+// ParseFailed counts a parse error with code, "unknown" if empty.
+func (s *Sink) ParseFailed(code string) {
+	if code == "" {
+		code = "unknown"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.parseErrors == nil {
+		s.parseErrors = make(map[string]uint64)
+	}
+	s.parseErrors[code]++
+}
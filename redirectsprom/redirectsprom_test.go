@@ -0,0 +1,42 @@
+package redirectsprom
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	var c Collector
+	blog := redirects.WithMetrics(c.Site("blog.example.com"))
+	docs := redirects.WithMetrics(c.Site(`docs "v2"`))
+
+	rules, err := redirects.ParseString("/old /new 301\n/app/* /index.html 200\n", blog)
+	require.NoError(t, err)
+	set := redirects.Compile(rules, blog)
+	set.Match("/old")
+	set.Match("/app/x")
+	set.Match("/missing")
+	_, err = redirects.ParseString("/a /b 999\n", docs)
+	require.Error(t, err)
+	_, err = redirects.ParseString("/a /b 999\n", docs)
+	require.Error(t, err)
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, `# HELP redirects_evaluations_total Paths evaluated against the rules of a site.
+# TYPE redirects_evaluations_total counter
+redirects_evaluations_total{site="blog.example.com"} 3
+redirects_evaluations_total{site="docs \"v2\""} 0
+# HELP redirects_matches_total Paths matched by a rule of a site, by status class.
+# TYPE redirects_matches_total counter
+redirects_matches_total{site="blog.example.com",class="2xx"} 1
+redirects_matches_total{site="blog.example.com",class="3xx"} 1
+# HELP redirects_parse_errors_total Files of a site that failed to parse, by error code.
+# TYPE redirects_parse_errors_total counter
+redirects_parse_errors_total{site="docs \"v2\"",code="invalid-status"} 2
+`, rec.Body.String())
+}
@@ -0,0 +1,204 @@
+// Package redirectsserver serves an HTTP API evaluating _redirects files, so
+// gateways not written in Go, like nginx with Lua or JavaScript ones, can
+// delegate evaluating rules to a sidecar running the canonical
+// implementation.
+//
+// Clients POST a JSON request to /evaluate:
+//
+//	{"cid": "bafk...", "path": "/old/page", "query": "a=1", "exists": false}
+//
+// where cid is the CID of the _redirects file of the site, path the path of
+// the request, query its query string, which rules don't match on, and
+// exists whether the site has content at path, which only forced rules
+// apply to. The response tells what the gateway should do:
+//
+//	{"match": true, "rule": 0, "status": 301, "location": "/new/page"}
+//
+// with location set for redirects, path for rewrites and 4xx rules serving
+// content of the site, and proxy_url for rewrites proxying requests. Files
+// that don't parse respond 422 Unprocessable Entity and files that can't be
+// loaded 502 Bad Gateway, with the error as a plain text body.
+package redirectsserver
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+)
+
+// A Loader returns the content of the _redirects file with CID cid.
+type Loader func(ctx context.Context, cid string) ([]byte, error)
+
+// Request is the body of requests to /evaluate.
+type Request struct {
+	CID    string `json:"cid"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	Exists bool   `json:"exists,omitempty"`
+}
+
+// Response is the body of responses to /evaluate.
+type Response struct {
+	// Match is false if no rule applies, the gateway serves the content
+	// at the path or responds 404.
+	Match bool `json:"match"`
+
+	// Rule is the index of the rule that applies, or -1.
+	Rule int `json:"rule"`
+
+	Status   int    `json:"status,omitempty"`
+	Location string `json:"location,omitempty"`
+	Path     string `json:"path,omitempty"`
+	ProxyURL string `json:"proxy_url,omitempty"`
+}
+
+// maxRequestSize is the largest request body accepted.
+const maxRequestSize = 64 << 10
+
+// A Server is an http.Handler serving the API, with a cache of the rules of
+// the most recently used files.
+type Server struct {
+	load Loader
+	opts []redirects.Option
+
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+}
+
+// cacheEntry holds the rules of a file, or the error parsing it, files
+// being immutable.
+type cacheEntry struct {
+	cid string
+	set *redirects.RuleSet
+	err error
+}
+
+// New returns a Server loading files with load, parsing and compiling them
+// with opts, and caching the rules of up to cacheSize files, at least one.
+func New(load Loader, cacheSize int, opts ...redirects.Option) *Server {
+	return &Server{
+		load:    load,
+		opts:    opts,
+		size:    max(cacheSize, 1),
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/evaluate" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.CID == "" || len(req.Path) == 0 || req.Path[0] != '/' {
+		http.Error(w, "invalid request: cid and an absolute path are required", http.StatusBadRequest)
+		return
+	}
+
+	set, err := s.ruleSet(r.Context(), req.CID)
+	var parseErr *parseError
+	switch {
+	case errors.As(err, &parseErr):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	res, err := evaluate(set, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// evaluate returns the response to req according to set.
+func evaluate(set *redirects.RuleSet, req Request) (Response, error) {
+	u := &url.URL{Path: req.Path, RawQuery: req.Query}
+	sim := set.SimulateRequest(&http.Request{Method: http.MethodPost, URL: u})
+	switch {
+	case sim.Rule < 0 || req.Exists && !set.Rules()[sim.Rule].Force:
+		return Response{Rule: -1}, nil
+	case sim.Location == "" && sim.Path == "" && sim.ProxyURL == "":
+		// the responses to unsafe destinations only have a status
+		return Response{}, fmt.Errorf("rule %d would expand %q into an unsafe destination", sim.Rule, req.Path)
+	}
+	return Response{
+		Match:    true,
+		Rule:     sim.Rule,
+		Status:   sim.Status,
+		Location: sim.Location,
+		Path:     sim.Path,
+		ProxyURL: sim.ProxyURL,
+	}, nil
+}
+
+// parseError is the error parsing a file.
+type parseError struct {
+	cid string
+	err error
+}
+
+func (e *parseError) Error() string {
+	return fmt.Sprintf("invalid _redirects file %s: %v", e.cid, e.err)
+}
+
+// ruleSet returns the rules of the file with CID cid, from the cache if
+// possible.
+func (s *Server) ruleSet(ctx context.Context, cid string) (*redirects.RuleSet, error) {
+	s.mu.Lock()
+	if e, ok := s.entries[cid]; ok {
+		s.order.MoveToFront(e)
+		entry := e.Value.(*cacheEntry)
+		s.mu.Unlock()
+		return entry.set, entry.err
+	}
+	s.mu.Unlock()
+
+	// load errors may not happen again, they aren't cached
+	b, err := s.load(ctx, cid)
+	if err != nil {
+		return nil, fmt.Errorf("loading _redirects file %s: %w", cid, err)
+	}
+	entry := &cacheEntry{cid: cid}
+	if rules, err := redirects.ParseBytes(b, s.opts...); err != nil {
+		entry.err = &parseError{cid: cid, err: err}
+	} else {
+		entry.set = redirects.Compile(rules, s.opts...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[cid]; !ok {
+		if s.order.Len() >= s.size {
+			oldest := s.order.Back()
+			s.order.Remove(oldest)
+			delete(s.entries, oldest.Value.(*cacheEntry).cid)
+		}
+		s.entries[cid] = s.order.PushFront(entry)
+	}
+	return entry.set, entry.err
+}
@@ -0,0 +1,81 @@
+package redirectsserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	files := map[string]string{
+		"site": `
+/old/*    /new/:splat                    302
+/home     /                              301!
+/app/*    /app/index.html                200
+/api/*    https://api.example.com/:splat 200
+`,
+		"broken": "/a /b 999\n",
+	}
+	loads := 0
+	s := New(func(ctx context.Context, cid string) ([]byte, error) {
+		loads++
+		f, ok := files[cid]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return []byte(f), nil
+	}, 1, redirects.WithAllowForced())
+
+	evaluate := func(body string) (int, string) {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/evaluate", strings.NewReader(body)))
+		return rec.Code, rec.Body.String()
+	}
+
+	for body, want := range map[string]string{
+		`{"cid": "site", "path": "/old/a/b", "query": "x=1"}`: `{"match": true, "rule": 0, "status": 302, "location": "/new/a/b"}`,
+		`{"cid": "site", "path": "/old/a", "exists": true}`:   `{"match": false, "rule": -1}`,
+		`{"cid": "site", "path": "/home", "exists": true}`:    `{"match": true, "rule": 1, "status": 301, "location": "/"}`,
+		`{"cid": "site", "path": "/app/settings"}`:            `{"match": true, "rule": 2, "status": 200, "path": "/app/index.html"}`,
+		`{"cid": "site", "path": "/api/v1/users"}`:            `{"match": true, "rule": 3, "status": 200, "proxy_url": "https://api.example.com/v1/users"}`,
+		`{"cid": "site", "path": "/missing"}`:                 `{"match": false, "rule": -1}`,
+	} {
+		status, res := evaluate(body)
+		require.Equal(t, http.StatusOK, status, body)
+		require.JSONEq(t, want, res, body)
+	}
+	require.Equal(t, 1, loads)
+
+	status, res := evaluate(`{"cid": "broken", "path": "/a"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Contains(t, res, "invalid _redirects file broken")
+
+	status, res = evaluate(`{"cid": "unknown", "path": "/a"}`)
+	require.Equal(t, http.StatusBadGateway, status)
+	require.Equal(t, "loading _redirects file unknown: not found\n", res)
+
+	status, _ = evaluate(`{"cid": "site", "path": "/old/%0d%0aSet-Cookie:x"}`)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = evaluate(`{"cid": "site", "path": "/old/\r\nSet-Cookie: x"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = evaluate(`{"path": "/a"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	// the cache holds one file, evicting the least recently used one
+	loads = 0
+	evaluate(`{"cid": "site", "path": "/old/a"}`)
+	evaluate(`{"cid": "broken", "path": "/a"}`)
+	evaluate(`{"cid": "site", "path": "/old/a"}`)
+	require.Equal(t, 2, loads)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/evaluate", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,81 @@
+package redirectstest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+)
+
+// AssertRedirect checks that a gateway redirects a request for target, a path
+// with an optional query like "/old?x=1", to location with status according
+// to rules, for a path without content. Otherwise it marks t as failed,
+// describing the response, and returns false.
+func AssertRedirect(t testing.TB, rules redirects.Rules, target, location string, status int) bool {
+	t.Helper()
+	return assertResponse(t, rules, target, redirects.SimResponse{Status: status, Location: location})
+}
+
+// AssertRewrite is like AssertRedirect for rewrites and 4xx rules serving the
+// content of the site at path with status.
+func AssertRewrite(t testing.TB, rules redirects.Rules, target, path string, status int) bool {
+	t.Helper()
+	return assertResponse(t, rules, target, redirects.SimResponse{Status: status, Path: path})
+}
+
+// AssertProxy is like AssertRedirect for rewrites proxying requests to
+// proxyURL with status.
+func AssertProxy(t testing.TB, rules redirects.Rules, target, proxyURL string, status int) bool {
+	t.Helper()
+	return assertResponse(t, rules, target, redirects.SimResponse{Status: status, ProxyURL: proxyURL})
+}
+
+// AssertNoMatch is like AssertRedirect for requests no rule applies to,
+// which respond 404 Not Found.
+func AssertNoMatch(t testing.TB, rules redirects.Rules, target string) bool {
+	t.Helper()
+	return assertResponse(t, rules, target, redirects.SimResponse{Status: http.StatusNotFound})
+}
+
+// assertResponse checks that the response to a request for target according
+// to rules is want, whatever rule applies.
+func assertResponse(t testing.TB, rules redirects.Rules, target string, want redirects.SimResponse) bool {
+	t.Helper()
+	got, err := simulate(rules, target)
+	if err != nil {
+		t.Errorf("redirectstest: %v", err)
+		return false
+	}
+	got.Rule = -1
+	want.Rule = -1
+	if got != want {
+		t.Errorf("GET %s: got %s, want %s", target, describe(got), describe(want))
+		return false
+	}
+	return true
+}
+
+// simulate returns the response to a request for target according to rules.
+func simulate(rules redirects.Rules, target string) (redirects.SimResponse, error) {
+	u, err := url.ParseRequestURI(target)
+	if err != nil || u.Path == "" || u.Path[0] != '/' {
+		return redirects.SimResponse{}, fmt.Errorf("invalid target %q, want a path with an optional query", target)
+	}
+	return redirects.SimulateRequest(rules, &http.Request{Method: http.MethodGet, URL: u}), nil
+}
+
+// describe returns res as its status followed by the Location, the path
+// served or the URL proxied, like "301 /new".
+func describe(res redirects.SimResponse) string {
+	switch {
+	case res.Location != "":
+		return fmt.Sprintf("%d %s", res.Status, res.Location)
+	case res.Path != "":
+		return fmt.Sprintf("%d serving %s", res.Status, res.Path)
+	case res.ProxyURL != "":
+		return fmt.Sprintf("%d proxying %s", res.Status, res.ProxyURL)
+	}
+	return fmt.Sprint(res.Status)
+}
@@ -0,0 +1,49 @@
+package redirectstest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+)
+
+// recordingTB records the failures of assertions.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingTB) Helper() {}
+
+func (t *recordingTB) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	rules := redirects.Rules(redirects.Must(redirects.ParseString(`
+	/old          /new                    301
+	/app/*        /index.html             200
+	/api/*        https://api.example.com/:splat  200
+	/gone         /gone.html              410
+	`)))
+
+	require.True(t, AssertRedirect(t, rules, "/old?x=1", "/new", 301))
+	require.True(t, AssertRewrite(t, rules, "/app/page", "/index.html", 200))
+	require.True(t, AssertRewrite(t, rules, "/gone", "/gone.html", 410))
+	require.True(t, AssertProxy(t, rules, "/api/users", "https://api.example.com/users", 200))
+	require.True(t, AssertNoMatch(t, rules, "/missing"))
+
+	rec := &recordingTB{}
+	require.False(t, AssertRedirect(rec, rules, "/old", "/new", 302))
+	require.False(t, AssertRedirect(rec, rules, "/app/page", "/index.html", 301))
+	require.False(t, AssertNoMatch(rec, rules, "/api/users"))
+	require.False(t, AssertRedirect(rec, rules, "old", "/new", 301))
+	require.Equal(t, []string{
+		"GET /old: got 301 /new, want 302 /new",
+		"GET /app/page: got 200 serving /index.html, want 301 /index.html",
+		"GET /api/users: got 200 proxying https://api.example.com/users, want 404",
+		`redirectstest: invalid target "old", want a path with an optional query`,
+	}, rec.errors)
+}
@@ -0,0 +1,72 @@
+package redirectstest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+)
+
+var update = flag.Bool("redirectstest.update", false, "write golden files instead of comparing with them")
+
+// Transcript returns the responses to requests for targets according to
+// rules, one line per target in the format of AssertRedirect failures:
+//
+//	/old?x=1 301 /new
+//	/app/page 200 serving /index.html
+//	/missing 404
+//
+// for comparing with golden files, which then review like the behavior of a
+// _redirects file. Invalid targets have a line with the error.
+func Transcript(rules redirects.Rules, targets ...string) []byte {
+	var b bytes.Buffer
+	for _, target := range targets {
+		res, err := simulate(rules, target)
+		if err != nil {
+			fmt.Fprintf(&b, "%s error: %v\n", target, err)
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s\n", target, describe(res))
+	}
+	return b.Bytes()
+}
+
+// AssertGolden checks that got is the content of the golden file at name,
+// usually in testdata. Otherwise it marks t as failed, showing both, and
+// returns false. When tests run with -redirectstest.update, it writes got to
+// the file instead, creating its directory if needed.
+func AssertGolden(t testing.TB, name string, got []byte) bool {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Errorf("redirectstest: %v", err)
+			return false
+		}
+		if err := os.WriteFile(name, got, 0o644); err != nil {
+			t.Errorf("redirectstest: %v", err)
+			return false
+		}
+		return true
+	}
+
+	want, err := os.ReadFile(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		t.Errorf("redirectstest: golden file %s doesn't exist, run the tests with -redirectstest.update to write it", name)
+		return false
+	case err != nil:
+		t.Errorf("redirectstest: %v", err)
+		return false
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs, run the tests with -redirectstest.update to update it\ngot:\n%s\nwant:\n%s", name, got, want)
+		return false
+	}
+	return true
+}
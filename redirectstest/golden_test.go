@@ -0,0 +1,42 @@
+package redirectstest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	redirects "github.com/ipfs/go-ipfs-redirects-file"
+)
+
+func TestTranscript(t *testing.T) {
+	rules := redirects.Rules(redirects.Must(redirects.ParseString(`
+	/old    /new         301
+	/app/*  /index.html  200
+	`)))
+	require.Equal(t, "/old?x=1 301 /new\n/app/page 200 serving /index.html\n/missing 404\nold error: invalid target \"old\", want a path with an optional query\n",
+		string(Transcript(rules, "/old?x=1", "/app/page", "/missing", "old")))
+}
+
+func TestAssertGolden(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "testdata", "site.golden")
+
+	rec := &recordingTB{}
+	require.False(t, AssertGolden(rec, name, []byte("/old 301 /new\n")))
+	require.Len(t, rec.errors, 1)
+	require.Contains(t, rec.errors[0], "doesn't exist")
+
+	*update = true
+	require.True(t, AssertGolden(t, name, []byte("/old 301 /new\n")))
+	*update = false
+	b, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.Equal(t, "/old 301 /new\n", string(b))
+
+	require.True(t, AssertGolden(t, name, []byte("/old 301 /new\n")))
+
+	rec = &recordingTB{}
+	require.False(t, AssertGolden(rec, name, []byte("/old 302 /new\n")))
+	require.Equal(t, []string{name + " differs, run the tests with -redirectstest.update to update it\ngot:\n/old 302 /new\n\nwant:\n/old 301 /new\n"}, rec.errors)
+}
@@ -0,0 +1,122 @@
+// Package redirectstest provides helpers for testing code that applies
+// _redirects files, like gateways, with consistent fixtures, and for testing
+// rules, like those generated by site generators, with assertions on how
+// gateways apply them and golden files:
+//
+//	redirectstest.AssertRedirect(t, rules, "/old?x=1", "/new", 301)
+//	redirectstest.AssertGolden(t, "testdata/redirects.golden",
+//		redirectstest.Transcript(rules, "/old", "/blog/post"))
+package redirectstest
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/unixfs"
+)
+
+// A Site is a UnixFS directory fixture, the root of a site.
+type Site struct {
+	// Root is the CIDv1 of the directory.
+	Root string
+
+	// CAR is a CARv1 archive of the directory, with Root as its root and
+	// the blocks in depth-first order.
+	CAR []byte
+}
+
+// NewSite returns a site with a _redirects file holding rules, unless rules
+// is empty, and the files of files, keyed by their slash-separated path like
+// "blog/index.html". The same rules and files always give the same site.
+//
+// Files are single raw blocks and directories single dag-pb blocks, like
+// kubo adds small sites with CIDv1. NewSite panics if a path is both a file
+// and a directory.
+func NewSite(rules string, files map[string]string) Site {
+	root := &dir{}
+	if rules != "" {
+		root.add("_redirects", rules)
+	}
+	for _, name := range sortedKeys(files) {
+		root.add(strings.TrimPrefix(name, "/"), files[name])
+	}
+
+	var blocks []unixfs.Block
+	block, _ := root.encode(&blocks)
+	var b bytes.Buffer
+	// writing to a bytes.Buffer doesn't fail
+	unixfs.WriteCAR(&b, block.CID, blocks)
+	return Site{Root: unixfs.CIDString(block.CID), CAR: b.Bytes()}
+}
+
+// dir is a directory of a site.
+type dir struct {
+	files map[string]string
+	dirs  map[string]*dir
+}
+
+// add adds the file at name, relative to d, with content.
+func (d *dir) add(name, content string) {
+	first, rest, nested := strings.Cut(name, "/")
+	if _, isDir := d.dirs[first]; !nested && isDir {
+		panic(fmt.Sprintf("redirectstest: %q is a directory", first))
+	}
+	if _, isFile := d.files[first]; nested && isFile {
+		panic(fmt.Sprintf("redirectstest: %q is a file", first))
+	}
+
+	if !nested {
+		if d.files == nil {
+			d.files = make(map[string]string)
+		}
+		d.files[name] = content
+		return
+	}
+	if d.dirs == nil {
+		d.dirs = make(map[string]*dir)
+	}
+	sub, ok := d.dirs[first]
+	if !ok {
+		sub = &dir{}
+		d.dirs[first] = sub
+	}
+	sub.add(rest, content)
+}
+
+// encode returns the block of d and the cumulative size of its DAG, and
+// appends the blocks of the DAG to blocks, d's first.
+func (d *dir) encode(blocks *[]unixfs.Block) (unixfs.Block, uint64) {
+	i := len(*blocks)
+	*blocks = append(*blocks, unixfs.Block{})
+
+	var links []unixfs.Link
+	var size uint64
+	for _, name := range sortedKeys(d.files) {
+		file := unixfs.File([]byte(d.files[name]))
+		*blocks = append(*blocks, file)
+		links = append(links, unixfs.Link{Name: name, CID: file.CID, Size: uint64(len(file.Data))})
+	}
+	for _, name := range sortedKeys(d.dirs) {
+		block, dagSize := d.dirs[name].encode(blocks)
+		links = append(links, unixfs.Link{Name: name, CID: block.CID, Size: dagSize})
+	}
+	for _, l := range links {
+		size += l.Size
+	}
+
+	block := unixfs.Directory(links)
+	(*blocks)[i] = block
+	return block, size + uint64(len(block.Data))
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,27 @@
+package redirectstest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSite(t *testing.T) {
+	// the empty directory kubo adds with CIDv1
+	require.Equal(t, "bafybeiczsscdsbs7ffqz55asqdf3smv6klcw3gofszvwlyarci47bgf354", NewSite("", nil).Root)
+
+	files := map[string]string{
+		"index.html":      "home",
+		"blog/index.html": "blog",
+		"/blog/post.html": "post",
+	}
+	site := NewSite("/old /new 301\n", files)
+	require.Equal(t, site, NewSite("/old /new 301\n", files))
+	require.NotEqual(t, site.Root, NewSite("/old /new 302\n", files).Root)
+	require.True(t, bytes.Contains(site.CAR, []byte("/old /new 301\n")))
+
+	require.PanicsWithValue(t, `redirectstest: "blog" is a file`, func() {
+		NewSite("", map[string]string{"blog/index.html": "", "blog": ""})
+	})
+}
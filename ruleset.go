@@ -0,0 +1,513 @@
+package redirects
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+	"github.com/ucarion/urlpath"
+)
+
+// A RuleSet is an ordered set of rules compiled for matching request paths.
+//
+// Rules whose From has no placeholders or splat are looked up by exact path,
+// the remaining rules are scanned in order. A RuleSet is safe for concurrent
+// use. A nil RuleSet has no rules and matches nothing.
+//
+// Matching a path takes time linear in the length of the path plus the size
+// of the rules, whatever they are: the path is split at its slashes once,
+// each rule then compares at most as many bytes as its From has, and rules
+// don't match paths they would expand to a To longer than 64 KiB. Crafted
+// rules files can't make a gateway work much harder on a request than
+// reading the rules and the path.
+type RuleSet struct {
+	rules     []Rule
+	patterns  []*urlpath.Path
+	templates []toTemplate
+
+	// static maps the exact path matched by a rule without placeholders to
+	// the index of the first such rule.
+	static map[string]int
+
+	// dynamic holds, in order, the indexes of rules with placeholders or a
+	// splat.
+	dynamic []int
+
+	// segments is the most segments a dynamic rule's pattern has.
+	segments int
+
+	// maxSplat is the longest splat rules match, or zero.
+	maxSplat int
+
+	// singleDecoding rejects paths that are still percent-encoded.
+	singleDecoding bool
+
+	// nameResolver resolves the names of ipns:// destinations, it is nil
+	// unless set with WithNameResolver.
+	nameResolver NameResolver
+
+	// tracer starts the spans of ResolveContext, it is nil unless set with
+	// WithTracer.
+	tracer Tracer
+
+	// metrics counts the evaluations, it is nil unless set with
+	// WithMetrics.
+	metrics MetricsSink
+
+	// logger logs the evaluations, it is nil unless set with WithLogger.
+	logger Logger
+
+	// evalTrace records the traces of the evaluations, it is nil unless
+	// set with WithEvaluationTrace.
+	evalTrace func(EvalTrace)
+
+	// shards splits dynamic into contiguous chunks scanned concurrently, it
+	// is nil when the set is scanned sequentially.
+	shards [][]int
+
+	// cache memoizes match results, it is nil unless enabled with
+	// WithMatchCache.
+	cache *matchCache
+}
+
+// minShardSize is the smallest number of rules worth scanning in a
+// goroutine of its own.
+const minShardSize = 256
+
+// Compile compiles rules into a RuleSet. The rules are copied, later changes
+// to the slice don't affect the RuleSet.
+func Compile(rules []Rule, opts ...Option) *RuleSet {
+	c := newConfig(opts)
+	s := &RuleSet{
+		rules:          append([]Rule(nil), rules...),
+		patterns:       make([]*urlpath.Path, len(rules)),
+		templates:      make([]toTemplate, len(rules)),
+		static:         make(map[string]int),
+		maxSplat:       c.maxSplatLength,
+		singleDecoding: c.singleDecoding,
+		nameResolver:   c.nameResolver,
+		tracer:         c.tracer,
+		metrics:        c.metrics,
+		logger:         c.logger,
+		evalTrace:      c.evalTrace,
+	}
+
+	for i, rule := range s.rules {
+		if c.gatewaySpec {
+			s.rules[i].Force = false
+		}
+		p := urlpattern.Compile(rule.From)
+		s.patterns[i] = p
+		s.templates[i] = compileTemplate(rule.To, p)
+
+		key, ok := urlpattern.Static(p)
+		if !ok {
+			s.dynamic = append(s.dynamic, i)
+			s.segments = max(s.segments, len(p.Segments))
+			continue
+		}
+		if _, exists := s.static[key]; !exists {
+			s.static[key] = i
+		}
+	}
+
+	if c.parallelThreshold > 0 && len(s.dynamic) > c.parallelThreshold {
+		s.shards = shard(s.dynamic, runtime.GOMAXPROCS(0))
+	}
+	if c.matchCacheSize > 0 {
+		s.cache = newMatchCache(c.matchCacheSize)
+	}
+
+	return s
+}
+
+// shard splits indexes into at most n contiguous chunks of at least
+// minShardSize indexes.
+func shard(indexes []int, n int) [][]int {
+	n = min(n, len(indexes)/minShardSize)
+	if n < 2 {
+		return nil
+	}
+
+	size := (len(indexes) + n - 1) / n
+	shards := make([][]int, 0, n)
+	for len(indexes) > 0 {
+		end := min(size, len(indexes))
+		shards = append(shards, indexes[:end])
+		indexes = indexes[end:]
+	}
+	return shards
+}
+
+// Rules returns a copy of the rules in the set.
+func (s *RuleSet) Rules() []Rule {
+	if s == nil {
+		return nil
+	}
+	return append([]Rule(nil), s.rules...)
+}
+
+// Len returns the number of rules in the set.
+func (s *RuleSet) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.rules)
+}
+
+// Match returns a copy of the first rule matching urlPath, with the
+// placeholders in To expanded, and true. If no rule matches, or the expanded
+// To would be unsafe, it returns false. Placeholders are
+// expanded like MatchAndExpandPlaceholders does.
+func (s *RuleSet) Match(urlPath string) (Rule, bool) {
+	_, rule, ok := s.match(urlPath)
+	return rule, ok
+}
+
+// Resolve is like Match, but when the first rule matching urlPath would
+// expand To into a destination with control characters, such as a CR or LF
+// smuggled in a percent-encoded path, or expand a path into a
+// protocol-relative URL, such as /:splat into //example.com for the path
+// /go//example.com, it returns an *UnsafeDestinationError.
+// Match reports no match in that case. It also returns the errors of
+// WithSingleDecoding.
+func (s *RuleSet) Resolve(urlPath string) (Rule, bool, error) {
+	_, rule, ok, err := s.resolve(urlPath)
+	return rule, ok, err
+}
+
+// ResolveContext is like Resolve, with ctx the parent of the span started
+// with the Tracer of WithTracer, if any. When the matching rule's To is an
+// ipns:// URL and the RuleSet was compiled WithNameResolver, it resolves the
+// name and returns the rule with To the ipfs:// URL of the current content,
+// keeping the rest of the URL: ipns://example.com/a?b becomes
+// ipfs://<cid>/a?b. Errors resolving the name are returned.
+func (s *RuleSet) ResolveContext(ctx context.Context, urlPath string) (Rule, bool, error) {
+	if s == nil || s.tracer == nil {
+		_, rule, ok, err := s.resolveContext(ctx, urlPath)
+		return rule, ok, err
+	}
+
+	ctx, span := s.tracer.StartSpan(ctx, "redirects.Resolve")
+	defer span.End()
+	i, rule, ok, err := s.resolveContext(ctx, urlPath)
+	span.SetAttribute("redirects.rule", i)
+	switch {
+	case err != nil:
+		span.SetAttribute("redirects.outcome", "error")
+		span.SetAttribute("redirects.error", err.Error())
+	case ok:
+		span.SetAttribute("redirects.outcome", "match")
+		span.SetAttribute("redirects.placeholders", len(s.captures(i, urlPath)))
+	default:
+		span.SetAttribute("redirects.outcome", "no match")
+	}
+	return rule, ok, err
+}
+
+// resolveContext is ResolveContext without tracing, also returning the index
+// of the matching rule, or -1.
+func (s *RuleSet) resolveContext(ctx context.Context, urlPath string) (int, Rule, bool, error) {
+	i, rule, ok, err := s.resolve(urlPath)
+	if !ok || err != nil {
+		return i, rule, ok, err
+	}
+	if rule, err = s.resolveName(ctx, rule); err != nil {
+		return i, Rule{}, false, err
+	}
+	return i, rule, true, nil
+}
+
+// resolve is Resolve, also returning the index of the matching rule, or -1.
+func (s *RuleSet) resolve(urlPath string) (int, Rule, bool, error) {
+	if s != nil && s.singleDecoding && percentEncoded(urlPath) {
+		return -1, Rule{}, false, fmt.Errorf("%w: %q", ErrDoubleEncoded, urlPath)
+	}
+	i, rule, ok := s.lookup(urlPath)
+	if ok && unsafeDestination(s.rules[i].To, rule.To) {
+		return i, Rule{}, false, &UnsafeDestinationError{
+			Rule:      i,
+			Path:      urlPath,
+			OtherHost: !hasControl(rule.To),
+		}
+	}
+	return i, rule, ok, nil
+}
+
+// An UnsafeDestinationError reports a path that a rule would redirect or
+// rewrite to a destination with control characters, which must never reach
+// a Location header or a proxied request, or to another host although its To
+// is a path of the site.
+type UnsafeDestinationError struct {
+	// Rule is the index of the matching rule.
+	Rule int
+
+	// Path is the matched path.
+	Path string
+
+	// OtherHost is true if the destination is on another host, false if it
+	// has control characters.
+	OtherHost bool
+}
+
+func (e *UnsafeDestinationError) Error() string {
+	if e.OtherHost {
+		return fmt.Sprintf("rule %d expands %q into a destination on another host", e.Rule+1, e.Path)
+	}
+	return fmt.Sprintf("rule %d expands %q into a destination with control characters", e.Rule+1, e.Path)
+}
+
+// unsafeDestination reports whether expanded, the To to with its
+// placeholders expanded, must not be used: it has control characters, or to
+// is a path and expanded a protocol-relative URL, which browsers and proxies
+// follow to another host.
+func unsafeDestination(to, expanded string) bool {
+	return hasControl(expanded) || urlpattern.EscapesStart(to) == 0 && urlpattern.EscapesStart(expanded) > 0
+}
+
+// hasControl reports whether s has ASCII control characters.
+func hasControl(s string) bool {
+	return controlIndex(s) >= 0
+}
+
+// controlIndex returns the index of the first ASCII control character in s,
+// or -1.
+func controlIndex(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] == 0x7f {
+			return i
+		}
+	}
+	return -1
+}
+
+// match returns the index of the first rule matching urlPath and the rule
+// with its placeholders expanded. Unsafe destinations are never returned, the
+// path matches nothing instead.
+func (s *RuleSet) match(urlPath string) (int, Rule, bool) {
+	if s != nil && s.singleDecoding && percentEncoded(urlPath) {
+		return -1, Rule{}, false
+	}
+	i, rule, ok := s.lookup(urlPath)
+	if ok && unsafeDestination(s.rules[i].To, rule.To) {
+		return -1, Rule{}, false
+	}
+	return i, rule, ok
+}
+
+// lookup is match, without checking the destination.
+func (s *RuleSet) lookup(urlPath string) (int, Rule, bool) {
+	if s == nil {
+		return -1, Rule{}, false
+	}
+	i, rule, ok := s.cachedMatch(urlPath)
+	if s.metrics != nil || s.logger != nil {
+		status := 0
+		if ok {
+			status = rule.Status
+		}
+		if s.metrics != nil {
+			s.metrics.Evaluated(status)
+		}
+		if s.logger != nil {
+			s.logger.Debug("evaluated path", "path", urlPath, "rule", i, "status", status)
+		}
+	}
+	if s.evalTrace != nil {
+		s.evalTrace(s.trace(urlPath))
+	}
+	return i, rule, ok
+}
+
+// cachedMatch is matchRules, going through the cache if enabled.
+func (s *RuleSet) cachedMatch(urlPath string) (int, Rule, bool) {
+	if s.cache == nil {
+		return s.matchRules(urlPath)
+	}
+
+	if r, ok := s.cache.get(urlPath); ok {
+		return r.index, r.rule, r.ok
+	}
+	i, rule, ok := s.matchRules(urlPath)
+	s.cache.add(urlPath, matchResult{index: i, rule: rule, ok: ok})
+	return i, rule, ok
+}
+
+// matchRules looks up the first rule matching urlPath.
+func (s *RuleSet) matchRules(urlPath string) (int, Rule, bool) {
+	first, ok := s.static[urlPath]
+	if !ok {
+		first = math.MaxInt
+	}
+
+	// a dynamic rule only wins if it comes before the static match
+	if s.shards != nil {
+		if i, to, ok := s.matchShards(urlPath, first); ok {
+			rule := s.rules[i]
+			rule.To = to
+			return i, rule, true
+		}
+	} else if len(s.dynamic) > 0 && s.dynamic[0] < first {
+		var buf [maxStackSlashes]int
+		slashes := slashOffsets(urlPath, s.segments, buf[:0])
+		for _, i := range s.dynamic {
+			if i > first {
+				break
+			}
+			if to, ok := s.matchAt(i, urlPath, slashes); ok {
+				rule := s.rules[i]
+				rule.To = to
+				return i, rule, true
+			}
+		}
+	}
+
+	if first == math.MaxInt {
+		return -1, Rule{}, false
+	}
+
+	// static rules have nothing to capture, but an empty splat is still
+	// substituted like urlpath would for an exact match
+	rule := s.rules[first]
+	rule.To = s.templates[first].expand(nil, "")
+	return first, rule, true
+}
+
+// maxStackCaptures is the number of captures matchAt holds without
+// allocating.
+const maxStackCaptures = 8
+
+// maxStackSlashes is the number of slash offsets matchRules holds without
+// allocating.
+const maxStackSlashes = 16
+
+// maxDestinationLength is the longest To a rule is expanded to, a rule
+// doesn't match paths it would expand to a longer one. Placeholders repeated
+// in To could otherwise multiply the length of the path.
+const maxDestinationLength = 1 << 16
+
+// matchAt matches urlPath, whose slashes are at the offsets slashes, against
+// the i-th rule and returns its expanded To.
+func (s *RuleSet) matchAt(i int, urlPath string, slashes []int) (string, bool) {
+	p := s.patterns[i]
+
+	var scratch [maxStackCaptures]string
+	captures := scratch[:0]
+	if n := len(p.Segments); n > maxStackCaptures {
+		captures = make([]string, 0, n)
+	}
+
+	captures, trailing, ok := matchSlashes(p, urlPath, slashes, captures)
+	if !ok || s.maxSplat > 0 && len(trailing) > s.maxSplat {
+		return "", false
+	}
+	t := s.templates[i]
+	if t.expandedLen(captures, trailing) > maxDestinationLength {
+		return "", false
+	}
+	return t.expand(captures, trailing), true
+}
+
+// matchShards scans the shards concurrently and returns the earliest dynamic
+// rule matching urlPath with an index below limit, and its expanded To.
+func (s *RuleSet) matchShards(urlPath string, limit int) (int, string, bool) {
+	// best is the index of the earliest match found so far, shards stop
+	// scanning once they pass it
+	var best atomic.Int64
+	best.Store(int64(limit))
+
+	tos := make([]string, len(s.shards))
+	found := make([]int, len(s.shards))
+	slashes := slashOffsets(urlPath, s.segments, nil)
+
+	var wg sync.WaitGroup
+	for n, indexes := range s.shards {
+		found[n] = -1
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, i := range indexes {
+				if int64(i) > best.Load() {
+					return
+				}
+				to, ok := s.matchAt(i, urlPath, slashes)
+				if !ok {
+					continue
+				}
+				tos[n], found[n] = to, i
+				for {
+					cur := best.Load()
+					if int64(i) >= cur || best.CompareAndSwap(cur, int64(i)) {
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// shards are in rule order, so the first shard with a match has the
+	// earliest one
+	for n, i := range found {
+		if i >= 0 {
+			return i, tos[n], true
+		}
+	}
+	return -1, "", false
+}
+
+// matchPath is like urlpath's Path.Match, but appends the values of the
+// parameter segments to captures, in order, instead of allocating a map.
+func matchPath(p *urlpath.Path, s string, captures []string) ([]string, string, bool) {
+	var buf [maxStackSlashes]int
+	return matchSlashes(p, s, slashOffsets(s, len(p.Segments), buf[:0]), captures)
+}
+
+// matchSlashes is matchPath given the offsets of the slashes of s, at least
+// as many of the first ones as p has segments, so matching s against many
+// patterns doesn't scan it again for each.
+func matchSlashes(p *urlpath.Path, s string, slashes []int, captures []string) ([]string, string, bool) {
+	start := 0
+	for n, seg := range p.Segments {
+		last := n == len(p.Segments)-1
+
+		end, next := len(s), len(s)
+		if n < len(slashes) {
+			end, next = slashes[n], slashes[n]+1
+			if last && !p.Trailing {
+				return captures, "", false
+			}
+		} else if !last || p.Trailing {
+			// running out of slashes is only fine on the last segment
+			// without trailing segments
+			return captures, "", false
+		}
+
+		if seg.IsParam {
+			captures = append(captures, s[start:end])
+		} else if s[start:end] != seg.Const {
+			return captures, "", false
+		}
+		start = next
+	}
+	return captures, s[start:], true
+}
+
+// slashOffsets appends the offsets of the first max slashes of path to
+// offsets.
+func slashOffsets(path string, max int, offsets []int) []int {
+	for i := 0; len(offsets) < max; {
+		j := strings.IndexByte(path[i:], '/')
+		if j < 0 {
+			break
+		}
+		offsets = append(offsets, i+j)
+		i += j + 1
+	}
+	return offsets
+}
@@ -0,0 +1,288 @@
+package redirects
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ucarion/urlpath"
+)
+
+func TestRuleSetMatch(t *testing.T) {
+	rules := Must(ParseString(`
+	/static       /static-target
+	/posts/:slug  /articles/:slug  302
+	/posts/fixed  /never
+	/trailing/    /trailing-target
+	/docs/*       /documentation/:splat
+	/empty-splat  /target/:splat
+	/dup          /first
+	/dup          /second
+	`))
+	s := Compile(rules)
+
+	for _, tc := range []struct {
+		path   string
+		to     string
+		status int
+		ok     bool
+	}{
+		{"/static", "/static-target", 301, true},
+		{"/static/", "", 0, false},
+		{"/posts/hello", "/articles/hello", 302, true},
+		{"/posts/fixed", "/articles/fixed", 302, true},
+		{"/trailing", "/trailing-target", 301, true},
+		{"/trailing/", "", 0, false},
+		{"/docs/a/b", "/documentation/a/b", 301, true},
+		{"/empty-splat", "/target/", 301, true},
+		{"/dup", "/first", 301, true},
+		{"/missing", "", 0, false},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			rule, ok := s.Match(tc.path)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.to, rule.To)
+			require.Equal(t, tc.status, rule.Status)
+		})
+	}
+
+	t.Run("same as MatchAndExpandPlaceholders", func(t *testing.T) {
+		for _, path := range []string{"/static", "/posts/x", "/posts/fixed", "/docs/", "/empty-splat", "/dup", "/", "", "/nope"} {
+			var want Rule
+			var wantOK bool
+			for _, r := range rules {
+				if r.MatchAndExpandPlaceholders(path) {
+					want, wantOK = r, true
+					break
+				}
+			}
+
+			got, ok := s.Match(path)
+			require.Equal(t, wantOK, ok, path)
+			require.Equal(t, want, got, path)
+		}
+	})
+
+	t.Run("does not modify rules", func(t *testing.T) {
+		_, ok := s.Match("/posts/hello")
+		require.True(t, ok)
+		require.Equal(t, rules, s.Rules())
+	})
+}
+
+func TestRuleSetParallelMatch(t *testing.T) {
+	rules := benchmarkRuleSlice(4000)
+	sequential := Compile(rules)
+	parallel := Compile(rules, WithParallelThreshold(1))
+	// make sure the shards are exercised on single CPU machines too
+	parallel.shards = shard(parallel.dynamic, 4)
+	require.Len(t, parallel.shards, 4)
+
+	for _, path := range []string{
+		"/old/page-0", "/old/page-3996", "/blog-1/2022/hello", "/blog-3997/2022/hello",
+		"/docs-2002/a/b", "/app-2999/x", "/app-3999/x", "/not/found",
+	} {
+		want, wantOK := sequential.Match(path)
+		got, ok := parallel.Match(path)
+		require.Equal(t, wantOK, ok, path)
+		require.Equal(t, want, got, path)
+	}
+
+	t.Run("below threshold", func(t *testing.T) {
+		require.Nil(t, Compile(rules, WithParallelThreshold(len(rules))).shards)
+	})
+}
+
+// benchmarkRuleSlice returns n rules from benchmarkRules without enforcing
+// MaxFileSizeInBytes.
+func benchmarkRuleSlice(n int) []Rule {
+	var rules []Rule
+	for _, line := range strings.Split(benchmarkRules(n), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, Must(ParseString(line))...)
+	}
+	return rules
+}
+
+func BenchmarkRuleSetMatch(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		rules := benchmarkRuleSlice(n)
+		s := Compile(rules)
+		parallel := Compile(rules, WithParallelThreshold(1))
+
+		for _, path := range []string{"/old/page-0", "/old/page-996", "/blog-1/2022/hello", "/not/found"} {
+			b.Run(fmt.Sprintf("rules=%d/path=%s", n, path), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					s.Match(path)
+				}
+			})
+			b.Run(fmt.Sprintf("rules=%d/parallel/path=%s", n, path), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					parallel.Match(path)
+				}
+			})
+		}
+	}
+}
+
+func TestRuleSetControlCharacters(t *testing.T) {
+	set := Compile(Must(ParseString(`
+	/a/:x      /b/:x
+	/splat/*   /c/:splat  302
+	/proxy/*   https://example.com/:splat  200
+	/ignored/* /d
+	`)))
+
+	for _, path := range []string{"/a/x\r\nSet-Cookie: a=b", "/splat/x/\ny", "/proxy/\x00", "/a/\x7f"} {
+		_, ok := set.Match(path)
+		require.False(t, ok, "%q", path)
+
+		_, ok, err := set.Resolve(path)
+		require.False(t, ok)
+		var unsafe *UnsafeDestinationError
+		require.ErrorAs(t, err, &unsafe)
+		require.Equal(t, path, unsafe.Path)
+	}
+
+	_, _, err := set.Resolve("/a/x\ny")
+	require.EqualError(t, err, `rule 1 expands "/a/x\ny" into a destination with control characters`)
+
+	// values that don't make it into To are harmless
+	rule, ok, err := set.Resolve("/ignored/\r\n")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "/d", rule.To)
+
+	rule, ok, err = set.Resolve("/a/%0D%0A")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "/b/%0D%0A", rule.To)
+
+	r := Rule{From: "/a/:x", To: "/b/:x"}
+	require.False(t, r.MatchAndExpandPlaceholders("/a/\r\n"))
+	require.Equal(t, "/b/:x", r.To)
+}
+
+func TestRuleSetProtocolRelativeDestinations(t *testing.T) {
+	set := Compile(Must(ParseString(`
+	/go/*      /:splat  301
+	/proxy/*   https://example.com/:splat  200
+	`)))
+
+	for _, path := range []string{"/go//example.com", "/go/\\example.com/a", "/go//"} {
+		_, ok := set.Match(path)
+		require.False(t, ok, "%q", path)
+
+		_, ok, err := set.Resolve(path)
+		require.False(t, ok)
+		var unsafe *UnsafeDestinationError
+		require.ErrorAs(t, err, &unsafe)
+		require.True(t, unsafe.OtherHost)
+	}
+
+	_, _, err := set.Resolve("/go//example.com")
+	require.EqualError(t, err, `rule 1 expands "/go//example.com" into a destination on another host`)
+
+	rule, ok, err := set.Resolve("/go/example.com")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "/example.com", rule.To)
+
+	// absolute URLs are allowed extra slashes in their path
+	rule, ok, err = set.Resolve("/proxy//a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "https://example.com//a", rule.To)
+
+	r := Rule{From: "/go/*", To: "/:splat"}
+	require.False(t, r.MatchAndExpandPlaceholders("/go//example.com"))
+	require.Equal(t, "/:splat", r.To)
+}
+
+func TestRuleSetLinearMatching(t *testing.T) {
+	// a To repeating a placeholder can't multiply the length of the path
+	s := Compile(Must(ParseString("/a/:x /b/" + strings.Repeat(":x", 32) + "\n/a/* /fallback")))
+	rule, ok := s.Match("/a/" + strings.Repeat("y", 1000))
+	require.True(t, ok)
+	require.Len(t, rule.To, 3+32*1000)
+
+	rule, ok = s.Match("/a/" + strings.Repeat("y", 4096))
+	require.True(t, ok)
+	require.Equal(t, "/fallback", rule.To)
+
+	// paths with more slashes than the rules have segments
+	s = Compile(Must(ParseString("/a/:x/* /b/:x/:splat\n/a/:x /c/:x")))
+	rule, ok = s.Match("/a/1/" + strings.Repeat("c/", 1000))
+	require.True(t, ok)
+	require.Equal(t, "/b/1/"+strings.Repeat("c/", 1000), rule.To)
+	_, ok = s.Match("/a/1/")
+	require.True(t, ok)
+	rule, ok = s.Match("/a/" + strings.Repeat("y", 1<<15))
+	require.True(t, ok)
+	require.Equal(t, "/c/"+strings.Repeat("y", 1<<15), rule.To)
+}
+
+func TestRuleSetMaxSplatLength(t *testing.T) {
+	rules := Must(ParseString("/a/* /b/:splat 200\n/a/:x /c\n/a/* /d"))
+	s := Compile(rules, WithMaxSplatLength(8))
+
+	rule, ok := s.Match("/a/12345678")
+	require.True(t, ok)
+	require.Equal(t, "/b/12345678", rule.To)
+
+	// later rules still match
+	rule, ok = s.Match("/a/123456789")
+	require.True(t, ok)
+	require.Equal(t, "/c", rule.To)
+	_, ok = s.Match("/a/1234/56789")
+	require.False(t, ok)
+
+	rule, ok = Compile(rules, WithLimits(PublicGatewayLimits())).Match("/a/" + strings.Repeat("b/", 4<<10))
+	require.False(t, ok, rule.To)
+}
+
+func TestMatchPath(t *testing.T) {
+	for _, from := range []string{"", "/", "/a", "/a/:b", "/:a/:b/c", "/a/*", "/*", "/:a/*", "/a/b:c"} {
+		for _, path := range []string{"", "/", "/a", "/a/", "/a/b", "/a/b/", "/a/b/c", "/x/y/c", "/a/b:c", "//"} {
+			p := urlpath.New(from)
+			want, wantOK := p.Match(path)
+
+			captures, trailing, ok := matchPath(&p, path, nil)
+			require.Equal(t, wantOK, ok, "from=%q path=%q", from, path)
+			if !ok {
+				continue
+			}
+			require.Equal(t, want.Trailing, trailing, "from=%q path=%q", from, path)
+
+			var slot int
+			for _, seg := range p.Segments {
+				if seg.IsParam {
+					require.Equal(t, want.Params[seg.Param], captures[slot])
+					slot++
+				}
+			}
+			require.Len(t, captures, slot)
+		}
+	}
+}
+
+func TestRuleSetMatchAllocations(t *testing.T) {
+	s := Compile(Must(ParseString("/posts/:year/:slug /articles/:year/:slug\n/static /target")))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		s.Match("/posts/2022/hello")
+	})
+	// only the expanded destination is allocated
+	require.Equal(t, 1.0, allocs)
+
+	allocs = testing.AllocsPerRun(100, func() {
+		s.Match("/static")
+		s.Match("/missing")
+	})
+	require.Zero(t, allocs)
+}
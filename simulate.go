@@ -0,0 +1,64 @@
+package redirects
+
+// A SimRequest is a request evaluated by Simulate.
+type SimRequest struct {
+	// Path is the request path, without query. Rules don't match queries.
+	Path string
+}
+
+// A SimResult is the outcome of evaluating a SimRequest.
+type SimResult struct {
+	Request SimRequest
+
+	// Matched is true if a rule matched the request.
+	Matched bool
+
+	// Rule is the index of the matched rule, or -1.
+	Rule int
+
+	// Destination is the matched rule's To with placeholders expanded.
+	Destination string
+
+	// Status is the matched rule's status.
+	Status int
+
+	// Captures holds the values captured by the matched rule's From, keyed
+	// by placeholder name, the splat under "splat".
+	Captures map[string]string
+}
+
+// Simulate evaluates requests against rules like a gateway would for paths
+// without content, for CLI test commands and CI assertions.
+func Simulate(rules Rules, requests []SimRequest) []SimResult {
+	set := Compile(rules)
+
+	results := make([]SimResult, len(requests))
+	for n, req := range requests {
+		res := SimResult{Request: req, Rule: -1}
+		if i, rule, ok := set.match(req.Path); ok {
+			res.Matched = true
+			res.Rule = i
+			res.Destination = rule.To
+			res.Status = rule.Status
+			res.Captures = set.captures(i, req.Path)
+		}
+		results[n] = res
+	}
+	return results
+}
+
+// captures returns the values captured by the i-th rule's From when matching
+// urlPath, keyed by placeholder name, with the splat under "splat".
+func (s *RuleSet) captures(i int, urlPath string) map[string]string {
+	p := s.patterns[i]
+	m, ok := p.Match(urlPath)
+	if !ok {
+		return nil
+	}
+
+	captures := m.Params
+	if _, ok := captures["splat"]; p.Trailing && !ok {
+		captures["splat"] = m.Trailing
+	}
+	return captures
+}
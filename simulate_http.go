@@ -0,0 +1,71 @@
+//go:build !tinygo
+
+package redirects
+
+import (
+	"net/http"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// A SimResponse is the response a gateway produces for a request, according
+// to SimulateRequest.
+type SimResponse struct {
+	// Status is the status of the response.
+	Status int
+
+	// Location is the Location header of redirects.
+	Location string
+
+	// Path is the path of the content served, for rewrites and 4xx rules
+	// with a relative To.
+	Path string
+
+	// ProxyURL is the URL proxied, for rewrites with an absolute To.
+	ProxyURL string
+
+	// Rule is the index of the applied rule, or -1.
+	Rule int
+}
+
+// SimulateRequest returns the response a gateway produces for req according
+// to rules, for a path without content: redirects respond with the rule's
+// status and Location, rewrites and 4xx rules serve the content at Path, or
+// proxy ProxyURL, with the rule's status, and requests no rule matches
+// respond 404 Not Found. Unsafe destinations, see Resolve, respond 400 Bad
+// Request. It has no side effects, for tests and preview tooling.
+//
+// SimulateRequest isn't available with TinyGo, whose net/http is incomplete.
+func SimulateRequest(rules Rules, req *http.Request) SimResponse {
+	return Compile(rules).SimulateRequest(req)
+}
+
+// SimulateRequest is like the SimulateRequest function, with the rules of s
+// compiled once for many requests.
+func (s *RuleSet) SimulateRequest(req *http.Request) SimResponse {
+	i, rule, ok, err := s.resolve(req.URL.Path)
+	switch {
+	case err != nil:
+		return SimResponse{Status: http.StatusBadRequest, Rule: i}
+	case !ok:
+		return SimResponse{Status: http.StatusNotFound, Rule: -1}
+	}
+	res := simulateRule(rule)
+	res.Rule = i
+	return res
+}
+
+// simulateRule returns the response of a gateway applying rule, with its
+// placeholders expanded, to a request.
+func simulateRule(rule Rule) SimResponse {
+	res := SimResponse{Status: rule.Status, Rule: -1}
+	switch {
+	case rule.Status >= 300 && rule.Status < 400:
+		res.Location = rule.To
+	case urlpattern.IsRelative(rule.To):
+		res.Path = destinationPath(rule.To)
+	default:
+		res.ProxyURL = rule.To
+	}
+	return res
+}
@@ -0,0 +1,35 @@
+//go:build !tinygo
+
+package redirects
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulateRequest(t *testing.T) {
+	rules := Must(ParseString(`
+	/old/*    /new/:splat                 302
+	/api/*    https://api.example.com/:splat 200
+	/gone     /410.html?lang=en           410
+	/app/*    /app/index.html             200
+	`))
+
+	for _, tc := range []struct {
+		target string
+		res    SimResponse
+	}{
+		{"/old/a/b?x=1", SimResponse{Status: 302, Location: "/new/a/b", Rule: 0}},
+		{"/api/users", SimResponse{Status: 200, ProxyURL: "https://api.example.com/users", Rule: 1}},
+		{"/gone", SimResponse{Status: 410, Path: "/410.html", Rule: 2}},
+		{"/app/settings", SimResponse{Status: 200, Path: "/app/index.html", Rule: 3}},
+		{"/missing", SimResponse{Status: 404, Rule: -1}},
+		{"/old/%0d%0aSet-Cookie:%20a=b", SimResponse{Status: 400, Rule: 0}},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		require.Equal(t, tc.res, SimulateRequest(rules, req), tc.target)
+	}
+}
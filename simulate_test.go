@@ -0,0 +1,63 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulate(t *testing.T) {
+	rules := Must(ParseString(`
+	/posts/:year/:slug  /articles/:year/:slug  302
+	/docs/*             /documentation/:splat
+	/static             /target
+	/*                  /index.html            200
+	`))
+
+	results := Simulate(rules, []SimRequest{
+		{Path: "/posts/2022/hello"},
+		{Path: "/docs/a/b"},
+		{Path: "/static"},
+		{Path: "/anything"},
+		{Path: ""},
+	})
+
+	require.Equal(t, []SimResult{
+		{
+			Request:     SimRequest{Path: "/posts/2022/hello"},
+			Matched:     true,
+			Rule:        0,
+			Destination: "/articles/2022/hello",
+			Status:      302,
+			Captures:    map[string]string{"year": "2022", "slug": "hello"},
+		},
+		{
+			Request:     SimRequest{Path: "/docs/a/b"},
+			Matched:     true,
+			Rule:        1,
+			Destination: "/documentation/a/b",
+			Status:      301,
+			Captures:    map[string]string{"splat": "a/b"},
+		},
+		{
+			Request:     SimRequest{Path: "/static"},
+			Matched:     true,
+			Rule:        2,
+			Destination: "/target",
+			Status:      301,
+			Captures:    map[string]string{},
+		},
+		{
+			Request:     SimRequest{Path: "/anything"},
+			Matched:     true,
+			Rule:        3,
+			Destination: "/index.html",
+			Status:      200,
+			Captures:    map[string]string{"splat": "anything"},
+		},
+		{
+			Request: SimRequest{Path: ""},
+			Rule:    -1,
+		},
+	}, results)
+}
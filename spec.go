@@ -0,0 +1,116 @@
+package redirects
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// WithGatewaySpec makes Parse only accept what the _redirects specification
+// of IPFS gateways allows, https://specs.ipfs.tech/http-gateways/web-redirects-file/,
+// rejecting the extensions Compliance reports, for authors targeting every
+// gateway. The specification has no conditions on rules, which Parse never
+// accepts. It overrides WithAllowForced and WithDynamicProxyHosts.
+//
+// Rules compiled with it apply like on gateways following the specification,
+// none of them forced.
+func WithGatewaySpec() Option {
+	return func(c *config) {
+		c.gatewaySpec = true
+	}
+}
+
+// A ComplianceReport lists the extensions to the _redirects specification of
+// IPFS gateways a file uses, which some gateways reject or apply
+// differently.
+type ComplianceReport struct {
+	// Extensions holds a warning for each use of an extension, with code
+	// CodeNonStandard, in the order of the file.
+	Extensions []Diagnostic
+}
+
+// Compliant reports whether the file only uses what the specification
+// allows.
+func (r ComplianceReport) Compliant() bool {
+	return len(r.Extensions) == 0
+}
+
+// Compliance parses src, accepting the extensions of the package that are
+// opt-in like forced rules, and reports the extensions it uses:
+//
+//   - forced rules, like "/a /b 200!"
+//   - statuses not spelled as three digits, like "0301"
+//   - rewrites and 4xx rules with a URL as To, which proxy requests
+//   - ipfs:// and ipns:// destinations
+//   - placeholders in the host of To
+//   - "::" for a literal colon in To
+//
+// It returns the error of Parse if src doesn't parse even so.
+func Compliance(src []byte) (ComplianceReport, error) {
+	statuses := make(map[int]Diagnostic)
+	rules, err := ParseBytes(src, WithAllowForced(), WithDynamicProxyHosts(), WithDeprecationWarnings(func(d Diagnostic) {
+		d.Severity = SeverityWarning
+		d.Code = CodeNonStandard
+		d.Message = fmt.Sprintf("statuses not spelled as three digits aren't part of the specification, use %s", d.Replacement)
+		statuses[d.Rule] = d
+	}))
+	if err != nil {
+		return ComplianceReport{}, err
+	}
+
+	var report ComplianceReport
+	for i, rule := range rules {
+		report.Extensions = append(report.Extensions, specExtensions(rule, i)...)
+		if d, ok := statuses[i]; ok {
+			report.Extensions = append(report.Extensions, d)
+		}
+	}
+	locate(report.Extensions, rules, src)
+	return report, nil
+}
+
+// specExtensions returns warnings for the extensions to the specification
+// the i-th rule uses, but for the spelling of its status.
+func specExtensions(rule Rule, i int) []Diagnostic {
+	var diags []Diagnostic
+	add := func(field int, token, format string, args ...any) {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     CodeNonStandard,
+			Rule:     i,
+			Line:     rule.Line,
+			Related:  -1,
+			Message:  fmt.Sprintf(format, args...),
+			field:    field,
+			token:    token,
+		})
+	}
+
+	if rule.Force {
+		add(fieldStatus, "!", "forced rules aren't part of the specification")
+	}
+	if _, dynamic := fillHostPlaceholders([]byte(rule.To), rule.From); dynamic {
+		add(fieldTo, "", "placeholders in the host of 'to' aren't part of the specification")
+	}
+	switch {
+	case isIPFSURL(rule.To):
+		add(fieldTo, "", "ipfs:// and ipns:// destinations aren't part of the specification")
+	case !urlpattern.IsRelative(rule.To) && (rule.Status == 200 || rule.Status >= 400):
+		add(fieldTo, "", "proxying to URLs isn't part of the specification, status %d with a URL proxies the request", rule.Status)
+	}
+	if strings.Contains(rule.To[urlpattern.EscapesStart(rule.To):], "::") {
+		add(fieldTo, "::", `"::" for a literal colon isn't part of the specification`)
+	}
+	return diags
+}
+
+// checkSpec rejects the extensions to the specification rule uses.
+func checkSpec(rule Rule) (field int, err error) {
+	diags := specExtensions(rule, 0)
+	if len(diags) == 0 {
+		return fieldNone, nil
+	}
+	return diags[0].field, errors.New(diags[0].Message)
+}
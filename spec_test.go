@@ -0,0 +1,69 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompliance(t *testing.T) {
+	src := []byte("/a /b\n/c /d 0302\n/e /f 200!\n/api/* https://api.example.com/:splat 200\n/g ipfs://bafkqaaa\n/:tenant https://:tenant.example.com/ 302\n/talks/:id /schedule/10::am/:id\n")
+	report, err := Compliance(src)
+	require.NoError(t, err)
+	require.False(t, report.Compliant())
+
+	var got [][3]any
+	for _, d := range report.Extensions {
+		require.Equal(t, CodeNonStandard, d.Code)
+		require.Equal(t, SeverityWarning, d.Severity)
+		got = append(got, [3]any{d.Line, d.Column, d.EndColumn})
+	}
+	require.Equal(t, [][3]any{
+		{2, 7, 11},
+		{3, 10, 11},
+		{4, 8, 38},
+		{5, 4, 19},
+		{6, 10, 38},
+		{7, 24, 26},
+	}, got)
+	require.Equal(t, "line 3: forced rules aren't part of the specification", report.Extensions[1].String())
+
+	report, err = Compliance([]byte("/a /b\n/c /d 302\n/e /f 404\n/* /index.html 200\n/g https://example.com/ 301"))
+	require.NoError(t, err)
+	require.True(t, report.Compliant())
+
+	_, err = Compliance([]byte("/a"))
+	require.Error(t, err)
+}
+
+func TestParseGatewaySpec(t *testing.T) {
+	for _, tc := range []struct{ file, err, code string }{
+		{"/a /b 0302", `line 1: parsing status "0302": status must be three digits`, CodeInvalidStatus},
+		{"/a /b 200!", `line 1: parsing status "200!": forced redirects (or "shadowing") are not allowed`, CodeInvalidStatus},
+		{"/api/* https://api.example.com/:splat 200", "line 1: proxying to URLs isn't part of the specification, status 200 with a URL proxies the request", CodeNonStandard},
+		{"/a ipfs://bafkqaaa", "line 1: ipfs:// and ipns:// destinations aren't part of the specification", CodeNonStandard},
+		{"/talks/:id /schedule/10::am/:id", `line 1: "::" for a literal colon isn't part of the specification`, CodeNonStandard},
+		{"/:tenant https://:tenant.example.com/ 302", "line 1: parsing 'to': placeholders are not allowed in the host", CodeDisallowedTo},
+	} {
+		_, err := ParseString(tc.file, WithGatewaySpec(), WithAllowForced(), WithDynamicProxyHosts())
+		require.EqualError(t, err, tc.err, tc.file)
+		require.Equal(t, tc.code, ErrorDiagnostic(err).Code, tc.file)
+	}
+
+	rules, err := ParseString("/a /b\n/c /d 302\n/* /index.html 200", WithGatewaySpec())
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+}
+
+func TestCompileGatewaySpec(t *testing.T) {
+	rules := Must(ParseString("/a /b 200!", WithAllowForced()))
+
+	rule, ok := Compile(rules).Match("/a")
+	require.True(t, ok)
+	require.True(t, rule.Force)
+
+	rule, ok = Compile(rules, WithGatewaySpec()).Match("/a")
+	require.True(t, ok)
+	require.False(t, rule.Force)
+	require.True(t, rules[0].Force, "the rules aren't modified")
+}
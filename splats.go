@@ -0,0 +1,190 @@
+package redirects
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+	"github.com/ucarion/urlpath"
+)
+
+// A SplatProposal proposes replacing consecutive rules for single paths that
+// only differ in one segment, like "/a/1 /b/1" and "/a/2 /b/2", with a rule
+// capturing it, like "/a/:x /b/:x".
+type SplatProposal struct {
+	// First is the index of the first rule replaced, and Count the number
+	// of rules replaced.
+	First, Count int
+
+	// Rule is the rule replacing them.
+	Rule Rule
+
+	// Changes holds sample requests whose outcome would change: the rule
+	// also matches paths none of the rules replaced matches, which it then
+	// redirects or rewrites, shown with the placeholder as the segment,
+	// and paths of the rules after them it matches first. A proposal
+	// without changes doesn't change what any path does.
+	Changes []DryRunChange
+}
+
+// ProposeSplats returns the proposals to replace groups of at least two
+// consecutive rules for single paths with a rule with a placeholder, in the
+// order of rules. The rules of a group have the same status, Force and
+// segments but one, whose value is in To between the same text, and the rule
+// replacing them does the same for their paths. Unlike Optimize, applying
+// them with ApplySplats can change what other paths do, which the proposals
+// report.
+func ProposeSplats(rules Rules) []SplatProposal {
+	var proposals []SplatProposal
+	for i := 0; i < len(rules)-1; {
+		proposal, ok := proposeSplat(rules, i)
+		if !ok {
+			i++
+			continue
+		}
+		requests := []SimRequest{{Path: samplePath(urlpattern.Compile(proposal.Rule.From))}}
+		p := urlpattern.Compile(proposal.Rule.From)
+		for _, rule := range rules[i+proposal.Count:] {
+			if path, ok := overlapPath(p, urlpattern.Compile(rule.From)); ok && path != requests[0].Path {
+				requests = append(requests, SimRequest{Path: path})
+			}
+		}
+		proposal.Changes = DryRun(rules, ApplySplats(rules, []SplatProposal{proposal}), requests).Changes
+		proposals = append(proposals, proposal)
+		i += proposal.Count
+	}
+	return proposals
+}
+
+// ApplySplats returns rules with the rules of proposals, proposals of
+// ProposeSplats for rules, replaced.
+func ApplySplats(rules Rules, proposals []SplatProposal) Rules {
+	applied := make(Rules, 0, len(rules))
+	next := 0
+	for _, proposal := range proposals {
+		applied = append(applied, rules[next:proposal.First]...)
+		applied = append(applied, proposal.Rule)
+		next = proposal.First + proposal.Count
+	}
+	return append(applied, rules[next:]...)
+}
+
+// splatPlaceholder is the name of the placeholders of proposals.
+const splatPlaceholder = "x"
+
+// proposeSplat returns the proposal to replace the group of rules starting
+// with the i-th, if there's one.
+func proposeSplat(rules Rules, i int) (SplatProposal, bool) {
+	first, second := rules[i], rules[i+1]
+	a, ok := staticSegments(first)
+	if !ok || first.Status != second.Status || first.Force != second.Force {
+		return SplatProposal{}, false
+	}
+	b, ok := staticSegments(second)
+	if !ok || len(a) != len(b) {
+		return SplatProposal{}, false
+	}
+	k := -1
+	for n := range a {
+		if a[n] == b[n] {
+			continue
+		}
+		if k >= 0 {
+			return SplatProposal{}, false
+		}
+		k = n
+	}
+	if k < 0 || a[k] == "" || b[k] == "" {
+		return SplatProposal{}, false
+	}
+
+	// the value of the segment is in To between the same prefix and suffix
+	var prefix, suffix string
+	found := false
+	for idx := 0; idx <= len(first.To); idx++ {
+		j := strings.Index(first.To[idx:], a[k])
+		if j < 0 {
+			break
+		}
+		idx += j
+		prefix, suffix = first.To[:idx], first.To[idx+len(a[k]):]
+		if second.To == prefix+b[k]+suffix {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return SplatProposal{}, false
+	}
+
+	segments := append([]string(nil), a...)
+	segments[k] = ":" + splatPlaceholder
+	rule := Rule{
+		From:   strings.Join(segments, "/"),
+		To:     prefix + ":" + splatPlaceholder + suffix,
+		Status: first.Status,
+		Force:  first.Force,
+		Line:   first.Line,
+	}
+	if _, err := ParseString(rule.From + " " + rule.To + " " + fmt.Sprint(rule.Status)); err != nil {
+		return SplatProposal{}, false
+	}
+
+	// the group goes on while the rules fit and the rule does the same for
+	// their paths
+	set := Compile([]Rule{rule})
+	count := 0
+	for _, next := range rules[i:] {
+		s, ok := staticSegments(next)
+		if !ok || len(s) != len(a) || s[k] == "" || next.Status != rule.Status || next.Force != rule.Force {
+			break
+		}
+		if m, ok := set.Match(strings.Join(s, "/")); !ok || m.To != next.To {
+			break
+		}
+		count++
+	}
+	if count < 2 {
+		return SplatProposal{}, false
+	}
+	return SplatProposal{First: i, Count: count, Rule: rule}, true
+}
+
+// staticSegments returns the segments of the path the rule matches, if it
+// matches a single one.
+func staticSegments(rule Rule) ([]string, bool) {
+	path, ok := urlpattern.Static(urlpattern.Compile(rule.From))
+	if !ok {
+		return nil, false
+	}
+	return strings.Split(path, "/"), true
+}
+
+// overlapPath returns a path both p, a pattern without splat, and q match,
+// with ":name" as the value of the parameters of both, if there's one.
+func overlapPath(p, q *urlpath.Path) (string, bool) {
+	k := len(p.Segments)
+	if q.Trailing && len(q.Segments) >= k || !q.Trailing && len(q.Segments) != k {
+		return "", false
+	}
+	parts := make([]string, k)
+	for n, seg := range p.Segments {
+		var other urlpath.Segment
+		if n < len(q.Segments) {
+			other = q.Segments[n]
+		} else {
+			other = urlpath.Segment{IsParam: true}
+		}
+		switch {
+		case !seg.IsParam && !other.IsParam && seg.Const != other.Const:
+			return "", false
+		case !seg.IsParam:
+			parts[n] = seg.Const
+		case !other.IsParam:
+			parts[n] = other.Const
+		default:
+			parts[n] = ":" + seg.Param
+		}
+	}
+	return strings.Join(parts, "/"), true
+}
@@ -0,0 +1,75 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProposeSplats(t *testing.T) {
+	rules := Must(ParseString(`
+/a/1             /b/1
+/a/2             /b/2
+/a/3             /b/3
+/posts/x/edit    /editor?post=x         302
+/posts/y/edit    /editor?post=y         302
+/posts/z/edit    /editor?post=z         301
+/c               /d
+/a/3/more        /e
+/a/special       /f
+/*               /index.html            200
+`))
+
+	proposals := ProposeSplats(rules)
+	require.Len(t, proposals, 2)
+
+	require.Equal(t, 0, proposals[0].First)
+	require.Equal(t, 3, proposals[0].Count)
+	require.Equal(t, Rule{From: "/a/:x", To: "/b/:x", Status: 301, Line: 2}, proposals[0].Rule)
+	var changes []string
+	for _, c := range proposals[0].Changes {
+		changes = append(changes, c.Before.Request.Path+": "+describeOutcome(c.Before)+" -> "+describeOutcome(c.After))
+	}
+	require.Equal(t, []string{
+		"/a/:x: 200 /index.html -> 301 /b/:x",
+		"/a/special: 301 /f -> 301 /b/special",
+	}, changes)
+
+	require.Equal(t, 3, proposals[1].First)
+	require.Equal(t, 2, proposals[1].Count)
+	require.Equal(t, Rule{From: "/posts/:x/edit", To: "/editor?post=:x", Status: 302, Line: 5}, proposals[1].Rule)
+	require.Len(t, proposals[1].Changes, 2)
+	require.Equal(t, "/posts/z/edit", proposals[1].Changes[1].Before.Request.Path)
+	require.Equal(t, 301, proposals[1].Changes[1].Before.Status)
+	require.Equal(t, 302, proposals[1].Changes[1].After.Status)
+
+	applied := ApplySplats(rules, proposals)
+	var froms []string
+	for _, rule := range applied {
+		froms = append(froms, rule.From)
+	}
+	require.Equal(t, []string{"/a/:x", "/posts/:x/edit", "/posts/z/edit", "/c", "/a/3/more", "/a/special", "/*"}, froms)
+
+	// the paths of the rules replaced do the same
+	before, after := Compile(rules), Compile(applied)
+	for _, path := range []string{"/a/1", "/a/2", "/a/3", "/posts/x/edit", "/posts/y/edit", "/a/3/more"} {
+		want, _ := before.Match(path)
+		got, _ := after.Match(path)
+		require.Equal(t, want.To, got.To, path)
+		require.Equal(t, want.Status, got.Status, path)
+	}
+}
+
+func TestProposeSplatsNone(t *testing.T) {
+	for _, file := range []string{
+		"/a/1 /b/1\n/a/2 /b/2 302",    // different statuses
+		"/a/1 /b/1\n/a/2/c /b/2",      // different segments
+		"/a/1 /b/1\n/c/2 /d/2",        // more than one segment differs
+		"/a/1 /b/1\n/a/2 /c/2",        // the values aren't in To alike
+		"/a/1 /b/1\n/x /y\n/a/2 /b/2", // not consecutive
+		"/a/:id /b\n/a/2 /b",          // not for single paths
+		"/a/1 /b/1",
+	} {
+		require.Empty(t, ProposeSplats(Must(ParseString(file))), file)
+	}
+}
@@ -0,0 +1,115 @@
+package redirects
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// WithoutPrivateProxies makes Parse reject rules whose To is an http, https
+// or protocol-relative URL with a loopback, private, link-local or otherwise
+// non-public IP address as host, like http://169.254.169.254/, or a localhost
+// name, so gateways honoring proxy rules can't be made to reach their own
+// network.
+//
+// Hosts given by name can still resolve to such addresses, gateways should
+// also check the addresses they connect to with IsPublicAddr.
+func WithoutPrivateProxies() Option {
+	return func(c *config) {
+		c.publicProxiesOnly = true
+	}
+}
+
+// cgnat is the shared address space of carrier-grade NAT, RFC 6598.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddr reports whether addr is a public unicast address, and not a
+// loopback, private, link-local, multicast, unspecified or carrier-grade NAT
+// address, which proxied requests must not reach.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	switch {
+	case !addr.IsValid(),
+		addr.IsUnspecified(),
+		addr.IsLoopback(),
+		addr.IsPrivate(),
+		addr.IsLinkLocalUnicast(),
+		addr.IsLinkLocalMulticast(),
+		addr.IsInterfaceLocalMulticast(),
+		addr.IsMulticast(),
+		cgnat.Contains(addr),
+		addr.Is4() && addr.As4()[0] == 0:
+		return false
+	}
+	return true
+}
+
+// checkPublicProxy makes sure the host of the http or https URL to isn't a
+// non-public IP address or a localhost name.
+func checkPublicProxy(to string) error {
+	u, err := url.Parse(to)
+	if err != nil || !isWebURL(u) {
+		return nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("host %q is not public", u.Hostname())
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		var ok bool
+		if addr, ok = parseLegacyIPv4(host); !ok {
+			return nil
+		}
+	}
+	if !IsPublicAddr(addr) {
+		return fmt.Errorf("host %q is not a public address", u.Hostname())
+	}
+	return nil
+}
+
+// parseLegacyIPv4 parses the IPv4 address forms inet_aton accepts besides
+// dotted decimal, like 2852039166 or 0xa9.0376.43518, which some HTTP clients
+// still resolve.
+func parseLegacyIPv4(s string) (netip.Addr, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+
+	var values []uint64
+	for _, part := range parts {
+		base := 10
+		switch {
+		case len(part) > 2 && (part[:2] == "0x" || part[:2] == "0X"):
+			part, base = part[2:], 16
+		case len(part) > 1 && part[0] == '0':
+			part, base = part[1:], 8
+		}
+		v, err := strconv.ParseUint(part, base, 32)
+		if err != nil || strings.ContainsAny(part, "+-_") {
+			return netip.Addr{}, false
+		}
+		values = append(values, v)
+	}
+
+	// all but the last part are a byte each, the last part fills the rest
+	var ip uint64
+	for _, v := range values[:len(values)-1] {
+		if v > 0xff {
+			return netip.Addr{}, false
+		}
+		ip = ip<<8 | v
+	}
+	rest := 4 - (len(values) - 1)
+	last := values[len(values)-1]
+	if last >= 1<<(8*rest) {
+		return netip.Addr{}, false
+	}
+	ip = ip<<(8*rest) | last
+
+	return netip.AddrFrom4([4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}), true
+}
@@ -0,0 +1,82 @@
+package redirects
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"1.1.1.1":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"0.1.2.3":         false,
+		"224.0.0.1":       false,
+		"::1":             false,
+		"::":              false,
+		"fe80::1":         false,
+		"fc00::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		require.Equal(t, want, IsPublicAddr(netip.MustParseAddr(addr)), addr)
+	}
+	require.False(t, IsPublicAddr(netip.Addr{}))
+}
+
+func TestParseLegacyIPv4(t *testing.T) {
+	for s, want := range map[string]string{
+		"2852039166":          "169.254.169.254",
+		"0xa9fea9fe":          "169.254.169.254",
+		"0251.0376.0251.0376": "169.254.169.254",
+		"169.254.43518":       "169.254.169.254",
+		"0xa9.0376.43518":     "169.254.169.254",
+		"127.1":               "127.0.0.1",
+	} {
+		addr, ok := parseLegacyIPv4(s)
+		require.True(t, ok, s)
+		require.Equal(t, want, addr.String(), s)
+	}
+
+	for _, s := range []string{"example.com", "0b101", "1.2.3.4.5", "256.1.1.1", "1.16777216", "08", "1_0", "+1", ""} {
+		_, ok := parseLegacyIPv4(s)
+		require.False(t, ok, s)
+	}
+}
+
+func TestParseWithoutPrivateProxies(t *testing.T) {
+	for _, to := range []string{
+		"https://example.com/a",
+		"http://1.1.1.1/",
+		"http://[2606:4700::1111]/",
+		"/relative",
+		"ipfs://bafy",
+	} {
+		_, err := ParseString("/a "+to, WithoutPrivateProxies())
+		require.NoError(t, err, to)
+	}
+
+	for _, to := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://127.0.0.1:8080/",
+		"http://2130706433/",
+		"http://0x7f.1/",
+		"http://[::1]/",
+		"http://[::ffff:127.0.0.1]/",
+		"http://localhost/",
+		"http://api.LOCALHOST./",
+		"http://10.0.0.1./",
+		"//169.254.169.254/",
+	} {
+		_, err := ParseString("/a "+to, WithoutPrivateProxies())
+		require.ErrorContains(t, err, "is not", to)
+		require.Equal(t, CodeDisallowedTo, ErrorDiagnostic(err).Code)
+	}
+}
@@ -0,0 +1,22 @@
+package redirects
+
+import "sync/atomic"
+
+// A Store holds the current RuleSet of a site and lets it be replaced while
+// other goroutines match against it, for example when the site's root CID
+// changes. Loading never blocks. The zero value is an empty Store.
+type Store struct {
+	current atomic.Pointer[RuleSet]
+}
+
+// Load returns the current RuleSet, or nil if none was stored. A nil RuleSet
+// matches nothing.
+func (s *Store) Load() *RuleSet {
+	return s.current.Load()
+}
+
+// Swap compiles rules with opts, makes them the current RuleSet and returns
+// the previous one, or nil if none was stored.
+func (s *Store) Swap(rules Rules, opts ...Option) *RuleSet {
+	return s.current.Swap(Compile(rules, opts...))
+}
@@ -0,0 +1,57 @@
+package redirects
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	var s Store
+
+	t.Run("empty", func(t *testing.T) {
+		require.Nil(t, s.Load())
+		_, ok := s.Load().Match("/a")
+		require.False(t, ok)
+		require.Zero(t, s.Load().Len())
+	})
+
+	t.Run("swap", func(t *testing.T) {
+		first := Must(ParseString("/a /b"))
+		require.Nil(t, s.Swap(first))
+
+		rule, ok := s.Load().Match("/a")
+		require.True(t, ok)
+		require.Equal(t, "/b", rule.To)
+
+		old := s.Swap(Must(ParseString("/a /c")))
+		require.Equal(t, first, old.Rules())
+
+		rule, ok = s.Load().Match("/a")
+		require.True(t, ok)
+		require.Equal(t, "/c", rule.To)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					s.Swap(Must(ParseString("/a /d")))
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					_, ok := s.Load().Match("/a")
+					assert.True(t, ok)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
@@ -0,0 +1,103 @@
+package redirects
+
+import (
+	"errors"
+	"fmt"
+)
+
+// An UnsupportedValueError reports a status or URL scheme of a rule that
+// isn't supported, along with the supported value the author likely meant.
+type UnsupportedValueError struct {
+	// Field is "status" or "scheme".
+	Field string
+
+	// Value is the unsupported value.
+	Value string
+
+	// Suggestion is the closest supported value, or empty if none is close
+	// enough to be a typo.
+	Suggestion string
+
+	err error
+}
+
+func (e *UnsupportedValueError) Error() string {
+	if e.Suggestion == "" {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("%v, did you mean %q?", e.err, e.Suggestion)
+}
+
+func (e *UnsupportedValueError) Unwrap() error {
+	return e.err
+}
+
+// supportedStatuses lists the supported statuses, most common first so they
+// win ties.
+var supportedStatuses = []string{"301", "302", "200", "404", "307", "308", "303", "410", "451"}
+
+// supportedSchemes lists the schemes allowed in absolute destinations.
+var supportedSchemes = []string{"https", "http", "ipfs", "ipns"}
+
+func unsupportedStatus(s string, err error) error {
+	return &UnsupportedValueError{
+		Field:      "status",
+		Value:      s,
+		Suggestion: suggest(s, supportedStatuses, 1),
+		err:        err,
+	}
+}
+
+func unsupportedScheme(scheme string) error {
+	return &UnsupportedValueError{
+		Field:      "scheme",
+		Value:      scheme,
+		Suggestion: suggest(scheme, supportedSchemes, max(1, len(scheme)/3)),
+		err:        errors.New("invalid URL scheme"),
+	}
+}
+
+// suggest returns the first of candidates closest to s, if it's within
+// maxDistance edits of s.
+func suggest(s string, candidates []string, maxDistance int) string {
+	if s == "" {
+		return ""
+	}
+	best, bestDistance := "", maxDistance+1
+	for _, c := range candidates {
+		if d := editDistance(s, c); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the optimal string alignment distance between a and
+// b: the number of byte insertions, deletions, substitutions and
+// transpositions of adjacent bytes turning a into b, where no substring is
+// edited more than once.
+func editDistance(a, b string) int {
+	// rows i-2, i-1 and i of the distance matrix
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
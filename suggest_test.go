@@ -0,0 +1,50 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"301", "301", 0},
+		{"3o1", "301", 1},
+		{"310", "301", 1},
+		{"htps", "https", 1},
+		{"htp", "https", 2},
+		{"", "http", 4},
+		{"ca", "abc", 3},
+	} {
+		require.Equal(t, tc.want, editDistance(tc.a, tc.b), "%q %q", tc.a, tc.b)
+		require.Equal(t, tc.want, editDistance(tc.b, tc.a), "%q %q", tc.b, tc.a)
+	}
+}
+
+func TestParseSuggestions(t *testing.T) {
+	for _, tc := range []struct {
+		rule, err, suggestion string
+	}{
+		{"/a /b 3o1", `line 1: parsing status "3o1": status code must be a number, did you mean "301"?`, "301"},
+		{"/a /b 310", `line 1: parsing status "310": status code 310 is not supported, did you mean "301"?`, "301"},
+		{"/a /b 2000", `line 1: parsing status "2000": status code 2000 is not supported, did you mean "200"?`, "200"},
+		{"/a /b 42", `line 1: parsing status "42": status code 42 is not supported`, ""},
+		{"/a htps://example.com", `line 1: parsing 'to': invalid URL scheme, did you mean "https"?`, "https"},
+		{"/a ipgs://bafy", `line 1: parsing 'to': invalid URL scheme, did you mean "ipfs"?`, "ipfs"},
+		{"/a ftp://example.com", `line 1: parsing 'to': invalid URL scheme`, ""},
+		{"/a b.html", `line 1: parsing 'to': invalid URL scheme`, ""},
+	} {
+		t.Run(tc.rule, func(t *testing.T) {
+			_, err := ParseString(tc.rule)
+			require.EqualError(t, err, tc.err)
+
+			var unsupported *UnsupportedValueError
+			require.ErrorAs(t, err, &unsupported)
+			require.Equal(t, tc.suggestion, unsupported.Suggestion)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package redirects
+
+import (
+	"strings"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+	"github.com/ucarion/urlpath"
+)
+
+// A toTemplate is a rule's To split into literal chunks and placeholder
+// slots, so it can be expanded in a single pass.
+type toTemplate struct {
+	parts []templatePart
+
+	// literalLen is the combined length of the literal parts.
+	literalLen int
+}
+
+type templatePart struct {
+	// literal is the text of a literal part.
+	literal string
+
+	// slot is the index of the capture filling a placeholder part, or
+	// splatSlot. It's ignored for literal parts.
+	slot int
+
+	placeholder bool
+}
+
+// compileTemplate splits to around the placeholders defined by p and the
+// splat. Placeholders refer to p's parameters by their position, as captured
+// by matchPath. When placeholder names overlap (":a" and ":ab") the longest
+// one wins, and substituted values are never expanded again. Past the host,
+// "::" stands for a literal colon.
+func compileTemplate(to string, p *urlpath.Path) toTemplate {
+	names := urlpattern.Placeholders(p)
+	escapes := urlpattern.EscapesStart(to)
+
+	var t toTemplate
+	literal := 0
+	for i := 0; i < len(to); i++ {
+		if to[i] != ':' {
+			continue
+		}
+		if i >= escapes && i+1 < len(to) && to[i+1] == ':' {
+			// keep the first colon, drop the second
+			t.addLiteral(to[literal : i+1])
+			i++
+			literal = i + 1
+			continue
+		}
+		ph, ok := urlpattern.At(to[i+1:], names)
+		if !ok {
+			continue
+		}
+
+		t.addLiteral(to[literal:i])
+		t.parts = append(t.parts, templatePart{slot: ph.Slot, placeholder: true})
+		i += len(ph.Name)
+		literal = i + 1
+	}
+	t.addLiteral(to[literal:])
+	return t
+}
+
+func (t *toTemplate) addLiteral(s string) {
+	if s == "" {
+		return
+	}
+	t.parts = append(t.parts, templatePart{literal: s})
+	t.literalLen += len(s)
+}
+
+// hasPlaceholders reports whether the template has any placeholder.
+func (t toTemplate) hasPlaceholders() bool {
+	for _, part := range t.parts {
+		if part.placeholder {
+			return true
+		}
+	}
+	return false
+}
+
+// uses reports whether the template has a placeholder filled by slot.
+func (t toTemplate) uses(slot int) bool {
+	for _, part := range t.parts {
+		if part.placeholder && part.slot == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// expandedLen returns the length of the template expanded with captures and
+// trailing.
+func (t toTemplate) expandedLen(captures []string, trailing string) int {
+	size := t.literalLen
+	for _, part := range t.parts {
+		switch {
+		case !part.placeholder:
+		case part.slot == urlpattern.SplatSlot:
+			size += len(trailing)
+		default:
+			size += len(captures[part.slot])
+		}
+	}
+	return size
+}
+
+// expand fills the placeholders with captures and the splat with trailing.
+func (t toTemplate) expand(captures []string, trailing string) string {
+	if len(t.parts) == 1 && !t.parts[0].placeholder {
+		return t.parts[0].literal
+	}
+
+	var b strings.Builder
+	b.Grow(t.expandedLen(captures, trailing))
+	for _, part := range t.parts {
+		switch {
+		case !part.placeholder:
+			b.WriteString(part.literal)
+		case part.slot == urlpattern.SplatSlot:
+			b.WriteString(trailing)
+		default:
+			b.WriteString(captures[part.slot])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ucarion/urlpath"
+)
+
+func TestToTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		from, to, path, want string
+	}{
+		{"/a", "/b", "/a", "/b"},
+		{"/posts/:year/:slug", "/articles/:year/:slug", "/posts/2022/hello", "/articles/2022/hello"},
+		{"/posts/:year/:slug", "/:slug/:slug", "/posts/2022/hello", "/hello/hello"},
+		{"/docs/*", "/documentation/:splat", "/docs/a/b", "/documentation/a/b"},
+		{"/docs/*", "https://example.com/:splat?x=:splat", "/docs/a", "https://example.com/a?x=a"},
+		{"/static", "/target/:splat", "/static", "/target/"},
+		{"/:a/:ab", "/:ab/:a", "/1/2", "/2/1"},
+		{"/:a", "/:unknown/:a:b", "/1", "/:unknown/1:b"},
+		{"/:splat/*", "/:splat", "/1/2", "/1"},
+		{"/:x", "/:x", "/:x", "/:x"},
+		{"/:x/:x", "/:x", "/1/2", "/2"},
+		{"/time", "/schedule/10:30", "/time", "/schedule/10:30"},
+		{"/talks/:id", "/schedule/10::am/:id", "/talks/1", "/schedule/10:am/1"},
+		{"/talks/:id", "/a::id::::b:", "/talks/1", "/a:id::b:"},
+		{"/:id", "http://[::1]:8080/::id/:id", "/1", "http://[::1]:8080/:id/1"},
+	} {
+		t.Run(tc.from+" "+tc.to, func(t *testing.T) {
+			p := urlpath.New(tc.from)
+			captures, trailing, ok := matchPath(&p, tc.path, nil)
+			require.True(t, ok)
+			require.Equal(t, tc.want, compileTemplate(tc.to, &p).expand(captures, trailing))
+		})
+	}
+}
+
+func TestMatchAndExpandEscapedColons(t *testing.T) {
+	r := Rule{From: "/talks/:id/*", To: "http://[::1]/schedule/10::am/:id::splat/:splat"}
+	require.True(t, r.MatchAndExpandPlaceholders("/talks/1/a/b"))
+	require.Equal(t, "http://[::1]/schedule/10:am/1:splat/a/b", r.To)
+}
+
+func BenchmarkToTemplate(b *testing.B) {
+	p := urlpath.New("/:a/:b/:c/:d/*")
+	m, _ := p.Match("/1/2/3/4/rest/of/path")
+	captures, trailing, _ := matchPath(&p, "/1/2/3/4/rest/of/path", nil)
+	to := "https://example.com/:d/:c/:b/:a/:splat?a=:a&b=:b"
+
+	b.Run("template", func(b *testing.B) {
+		tmpl := compileTemplate(to, &p)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			tmpl.expand(captures, trailing)
+		}
+	})
+
+	b.Run("replace", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			expandPlaceholders(to, m)
+		}
+	})
+}
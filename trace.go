@@ -0,0 +1,44 @@
+package redirects
+
+import "context"
+
+// A Tracer starts the spans of ParseContext and RuleSet.ResolveContext, so
+// gateways can see the time spent on redirects in their traces. The package
+// doesn't depend on a tracing implementation, with OpenTelemetry a Tracer
+// can wrap a trace.Tracer:
+//
+//	func (t otelTracer) StartSpan(ctx context.Context, name string) (context.Context, redirects.Span) {
+//		ctx, span := t.tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+// with otelSpan setting attributes with attribute.Int and attribute.String.
+type Tracer interface {
+	// StartSpan starts a span named name, a child of the span of ctx if
+	// any, and returns the context holding it.
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// A Span is a span started by a Tracer. Its attributes are:
+//
+//   - for redirects.Parse spans, "redirects.rules", the number of rules
+//     parsed, and "redirects.error", the error parsing the file if any
+//   - for redirects.Resolve spans, "redirects.rule", the index of the
+//     matching rule or -1, "redirects.placeholders", the number of values
+//     captured by its From, "redirects.outcome", "match", "no match" or
+//     "error", and "redirects.error", the error if any
+type Span interface {
+	// SetAttribute sets the attribute key to value, an int or a string.
+	SetAttribute(key string, value any)
+
+	// End ends the span.
+	End()
+}
+
+// WithTracer makes ParseContext and the ResolveContext method of RuleSets
+// compiled with it trace their work with t.
+func WithTracer(t Tracer) Option {
+	return func(c *config) {
+		c.tracer = t
+	}
+}
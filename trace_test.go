@@ -0,0 +1,69 @@
+package redirects
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTracer records the spans it starts.
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name       string
+	attributes map[string]any
+	ended      bool
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordedSpan{name: name, attributes: make(map[string]any)}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func (s *recordedSpan) SetAttribute(key string, value any) {
+	s.attributes[key] = value
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	ctx := context.Background()
+
+	rules, err := ParseContext(ctx, strings.NewReader("/blog/:year/:slug /posts/:year/:slug 302\n/old /new\n"), WithTracer(tracer))
+	require.NoError(t, err)
+	_, parseErr := ParseContext(ctx, strings.NewReader("/a /b 999\n"), WithTracer(tracer))
+	require.Error(t, parseErr)
+
+	set := Compile(rules, WithTracer(tracer))
+	_, ok, err := set.ResolveContext(ctx, "/blog/2024/hello")
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, ok, err = set.ResolveContext(ctx, "/missing")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.Equal(t, []*recordedSpan{
+		{name: "redirects.Parse", ended: true, attributes: map[string]any{"redirects.rules": 2}},
+		{name: "redirects.Parse", ended: true, attributes: map[string]any{
+			"redirects.rules": 0,
+			"redirects.error": parseErr.Error(),
+		}},
+		{name: "redirects.Resolve", ended: true, attributes: map[string]any{
+			"redirects.rule":         0,
+			"redirects.outcome":      "match",
+			"redirects.placeholders": 2,
+		}},
+		{name: "redirects.Resolve", ended: true, attributes: map[string]any{
+			"redirects.rule":    -1,
+			"redirects.outcome": "no match",
+		}},
+	}, tracer.spans)
+}
@@ -0,0 +1,61 @@
+package redirects
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/ipfs/go-ipfs-redirects-file/internal/urlpattern"
+)
+
+// ValidateDestinations reports rules with a relative To pointing at content
+// that doesn't exist according to exists, which is called with unescaped
+// absolute paths of the site, starting with "/" like "/docs/index.html", e.g.
+// backed by the site's UnixFS DAG.
+//
+// Rewrites and 4xx rules serve the content at To, so it must exist. Redirects
+// may also point at a path another rule redirects or rewrites. Destinations with
+// placeholders can't be checked and are skipped. With WithStrict problems are
+// reported as errors instead of warnings.
+func ValidateDestinations(rules Rules, exists func(path string) bool, opts ...Option) []Diagnostic {
+	c := newConfig(opts)
+	set := Compile(rules)
+
+	var diags []Diagnostic
+	for i, rule := range rules {
+		if !urlpattern.IsRelative(rule.To) {
+			continue
+		}
+		p := urlpattern.Compile(rule.From)
+		if tmpl := compileTemplate(rule.To, p); tmpl.hasPlaceholders() {
+			continue
+		}
+
+		path, err := url.PathUnescape(destinationPath(rule.To))
+		if err != nil || exists(path) {
+			continue
+		}
+
+		// a catch-all 404 rule doesn't make a redirect target exist
+		isRedirect := rule.Status >= 300 && rule.Status < 400
+		if _, next, ok := set.match(destinationPath(rule.To)); isRedirect && ok && next.Status < 400 {
+			continue
+		}
+
+		d := Diagnostic{
+			Code:    CodeMissingDestination,
+			Rule:    i,
+			Line:    rule.Line,
+			Related: -1,
+			Message: fmt.Sprintf("destination %q does not exist", path),
+			field:   fieldTo,
+		}
+		if c.strict {
+			d.Severity = SeverityError
+		}
+		diags = append(diags, d)
+	}
+	diags = suppress(diags, c)
+	locate(diags, rules, c.source)
+	redactDiagnostics(diags, c)
+	return diags
+}
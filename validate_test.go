@@ -0,0 +1,42 @@
+package redirects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDestinations(t *testing.T) {
+	files := map[string]bool{
+		"/index.html":      true,
+		"/404.html":        true,
+		"/new page.html":   true,
+		"/docs/intro.html": true,
+	}
+	exists := func(path string) bool {
+		return files[path]
+	}
+
+	rules := Must(ParseString(`
+	/old             /new%20page.html
+	/gone            /missing.html
+	/spa/*           /app.html        200
+	/docs/*          /docs/:splat     200
+	/chain           /old             302
+	/proxy           https://example.com/missing
+	/query           /index.html?x=1  301
+	/*               /404.html        404
+	/not-found/*     /custom-404.html 404
+	`))
+
+	diags := ValidateDestinations(rules, exists)
+	require.Equal(t, []Diagnostic{
+		{Code: CodeMissingDestination, Rule: 1, Line: 3, Related: -1, Message: `destination "/missing.html" does not exist`},
+		{Code: CodeMissingDestination, Rule: 2, Line: 4, Related: -1, Message: `destination "/app.html" does not exist`},
+		{Code: CodeMissingDestination, Rule: 8, Line: 10, Related: -1, Message: `destination "/custom-404.html" does not exist`},
+	}, diags)
+
+	for _, d := range ValidateDestinations(rules, exists, WithStrict()) {
+		require.Equal(t, SeverityError, d.Severity)
+	}
+}
@@ -0,0 +1,46 @@
+package redirects
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrUnverified is wrapped by the error Parse returns when the verifier given
+// with WithVerifier rejects a file.
+var ErrUnverified = errors.New("file failed verification")
+
+// WithVerifier makes Parse call verify with the raw content of a file, before
+// parsing it, and fail with an error wrapping ErrUnverified and the error
+// verify returns, if any. Deployments can require that only signed _redirects
+// files are honored, with verify checking a detached signature published next
+// to the file:
+//
+//	func(file []byte) error {
+//		if !ed25519.Verify(publicKey, file, signature) {
+//			return errors.New("invalid signature")
+//		}
+//		return nil
+//	}
+func WithVerifier(verify func(file []byte) error) Option {
+	return func(c *config) {
+		c.verify = verify
+	}
+}
+
+// verifyFile reads the file from r, up to the maximum file size of c, and
+// returns a reader for its content if c's verifier accepts it.
+func verifyFile(r io.Reader, c *config) (io.Reader, error) {
+	b, err := io.ReadAll(io.LimitReader(r, int64(c.maxFileSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > c.maxFileSize {
+		return nil, fileTooLarge(c.maxFileSize)
+	}
+	if err := c.verify(b); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnverified, err)
+	}
+	return bytes.NewReader(b), nil
+}
@@ -0,0 +1,40 @@
+package redirects
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWithVerifier(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	file := []byte("/a /b\n/c /d 302\n")
+	signature := ed25519.Sign(privateKey, file)
+	verify := func(file []byte) error {
+		if !ed25519.Verify(publicKey, file, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	rules, err := ParseBytes(file, WithVerifier(verify))
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	_, err = ParseString("/a /b\n/c /e 302\n", WithVerifier(verify))
+	require.EqualError(t, err, "file failed verification: invalid signature")
+	require.ErrorIs(t, err, ErrUnverified)
+	require.Equal(t, CodeUnverified, ErrorDiagnostic(err).Code)
+
+	// oversized files aren't read beyond the limit to be verified
+	_, err = ParseString(strings.Repeat("/a /b\n", 10), WithMaxFileSize(20), WithVerifier(func([]byte) error {
+		t.Fatal("verifier called")
+		return nil
+	}))
+	require.ErrorIs(t, err, ErrFileTooLarge)
+}
@@ -0,0 +1,104 @@
+package redirects
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// pollInterval is how often Watch checks files for changes without events.
+var pollInterval = time.Second
+
+// Reload parses the _redirects file at name with opts and, if it parses,
+// compiles it with opts and makes it the current RuleSet. Otherwise the
+// current RuleSet is kept and the error returned. A missing file has no
+// rules, like on a gateway.
+func (s *Store) Reload(name string, opts ...Option) error {
+	b, err := os.ReadFile(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		b = nil
+	case err != nil:
+		return err
+	}
+	rules, err := ParseBytes(b, opts...)
+	if err != nil {
+		return err
+	}
+	s.Swap(rules, opts...)
+	return nil
+}
+
+// Watch reloads the _redirects file at name with opts, then reloads it when
+// it changes, until ctx is done, for development servers to apply edits
+// without restarting. Changes are received from changes, like the events of
+// a file system notification library, or found by checking the size and
+// modification time of the file every second if changes is nil.
+//
+// Errors reading or parsing the file are passed to report, if not nil, the
+// last rules that parsed stay current until the file is fixed.
+func (s *Store) Watch(ctx context.Context, name string, changes <-chan struct{}, report func(error), opts ...Option) {
+	reload := func() {
+		if err := s.Reload(name, opts...); err != nil && report != nil {
+			report(err)
+		}
+	}
+	// the state is taken first, so edits during the first reload are seen
+	last := stat(name)
+	reload()
+
+	if changes == nil {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		poll := make(chan struct{})
+		go func() {
+			defer close(poll)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				if current := stat(name); current != last {
+					last = current
+					select {
+					case poll <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+		changes = poll
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+			reload()
+		}
+	}
+}
+
+// fileState is the size and modification time of a file, zero if it
+// doesn't exist.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// stat returns the state of the file at name.
+func stat(name string) fileState {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{size: fi.Size(), modTime: fi.ModTime()}
+}
@@ -0,0 +1,70 @@
+package redirects
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "_redirects")
+	var s Store
+
+	require.NoError(t, s.Reload(name))
+	require.Zero(t, s.Load().Len())
+
+	require.NoError(t, os.WriteFile(name, []byte("/a /b\n"), 0o644))
+	require.NoError(t, s.Reload(name))
+	require.Equal(t, 1, s.Load().Len())
+
+	// the last rules that parsed stay current
+	require.NoError(t, os.WriteFile(name, []byte("/a\n"), 0o644))
+	require.Error(t, s.Reload(name))
+	require.Equal(t, 1, s.Load().Len())
+}
+
+func TestWatch(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "_redirects")
+	require.NoError(t, os.WriteFile(name, []byte("/a /b\n"), 0o644))
+
+	t.Run("events", func(t *testing.T) {
+		var s Store
+		ctx, cancel := context.WithCancel(context.Background())
+		changes := make(chan struct{})
+		errs := make(chan error, 1)
+		done := make(chan struct{})
+		go func() {
+			s.Watch(ctx, name, changes, func(err error) { errs <- err })
+			close(done)
+		}()
+
+		require.NoError(t, os.WriteFile(name, []byte("/a /b\n/c /d\n"), 0o644))
+		changes <- struct{}{}
+		require.NoError(t, os.WriteFile(name, []byte("/a\n"), 0o644))
+		changes <- struct{}{}
+		require.Error(t, <-errs)
+		require.Equal(t, 2, s.Load().Len())
+
+		cancel()
+		<-done
+	})
+
+	t.Run("polling", func(t *testing.T) {
+		defer func(interval time.Duration) { pollInterval = interval }(pollInterval)
+		pollInterval = time.Millisecond
+		require.NoError(t, os.WriteFile(name, []byte("/a /b\n"), 0o644))
+
+		var s Store
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go s.Watch(ctx, name, nil, nil)
+
+		require.Eventually(t, func() bool { return s.Load().Len() == 1 }, time.Second, time.Millisecond)
+		require.NoError(t, os.WriteFile(name, []byte("/a /b\n/c /d\n/e /f\n"), 0o644))
+		require.Eventually(t, func() bool { return s.Load().Len() == 3 }, time.Second, time.Millisecond)
+	})
+}